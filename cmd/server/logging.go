@@ -0,0 +1,50 @@
+package main
+
+import (
+	"io"
+	"log/slog"
+	"regexp"
+	"strings"
+
+	"project/cmd/config"
+)
+
+// sensitiveKeys are attribute keys whose values are never logged.
+var sensitiveKeys = []string{"token", "authorization", "password", "secret", "otp", "code_hash", "email"}
+
+var emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+
+const redacted = "[REDACTED]"
+
+// newLogger builds the process logger from cfg. Values under sensitive keys
+// and email addresses inside messages and errors are redacted, so wrapped
+// database and mail errors can be logged as they are.
+func newLogger(cfg *config.Config, w io.Writer) *slog.Logger {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(cfg.LogLevel)); err != nil {
+		level = slog.LevelInfo
+	}
+	opts := &slog.HandlerOptions{Level: level, ReplaceAttr: redactAttr}
+
+	if strings.EqualFold(cfg.LogFormat, "json") {
+		return slog.New(slog.NewJSONHandler(w, opts))
+	}
+	return slog.New(slog.NewTextHandler(w, opts))
+}
+
+func redactAttr(groups []string, a slog.Attr) slog.Attr {
+	key := strings.ToLower(a.Key)
+	for _, sensitive := range sensitiveKeys {
+		if strings.Contains(key, sensitive) {
+			return slog.String(a.Key, redacted)
+		}
+	}
+
+	switch v := a.Value.Any().(type) {
+	case string:
+		return slog.String(a.Key, emailPattern.ReplaceAllString(v, redacted))
+	case error:
+		return slog.String(a.Key, emailPattern.ReplaceAllString(v.Error(), redacted))
+	}
+	return a
+}
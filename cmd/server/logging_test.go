@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"project/cmd/config"
+)
+
+func TestLoggerRedactsSensitiveValues(t *testing.T) {
+	tests := []struct {
+		name  string
+		key   string
+		value any
+		want  string
+	}{
+		{"token", "token", "abc123", redacted},
+		{"key containing a sensitive word", "Authorization", "Bearer abc123", redacted},
+		{"password", "new_password", "hunter2", redacted},
+		{"webhook secret", "webhook_secret", "whsec_1", redacted},
+		{"email key", "email", "alice@example.com", redacted},
+		{"email inside a message", "detail", "sent to alice@example.com twice", "sent to " + redacted + " twice"},
+		{"email inside an error", "error", fmt.Errorf("send mail: %w", errors.New("bob.smith+x@mail.example.org bounced")), "send mail: " + redacted + " bounced"},
+		{"plain string", "method", "/api.API/Health", "/api.API/Health"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger := newLogger(&config.Config{LogFormat: "json", LogLevel: "info"}, &buf)
+			logger.Info("test", tt.key, tt.value)
+
+			var entry map[string]any
+			if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+				t.Fatalf("decode %q: %v", buf.String(), err)
+			}
+			if got := entry[tt.key]; got != tt.want {
+				t.Errorf("%s = %v, want %q", tt.key, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoggerKeepsNonStringValues(t *testing.T) {
+	var buf bytes.Buffer
+	logger := newLogger(&config.Config{LogFormat: "text", LogLevel: "debug"}, &buf)
+	logger.Debug("access", "user_id", 42, "code", "OK")
+
+	if out := buf.String(); !strings.Contains(out, "user_id=42") || !strings.Contains(out, "code=OK") {
+		t.Errorf("log line = %q", out)
+	}
+}
+
+func TestLoggerRedactsEmailsInMessages(t *testing.T) {
+	var buf bytes.Buffer
+	logger := newLogger(&config.Config{LogFormat: "text", LogLevel: "info"}, &buf)
+	logger.Info("Invitation for carol@example.com expired")
+
+	if out := buf.String(); strings.Contains(out, "carol@example.com") || !strings.Contains(out, redacted) {
+		t.Errorf("log line = %q", out)
+	}
+}
@@ -2,12 +2,14 @@ package main
 
 import (
 	"context"
-	"log"
+	"fmt"
+	"log/slog"
 	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/golang-migrate/migrate/v4"
 	_ "github.com/golang-migrate/migrate/v4/database/postgres"
@@ -24,48 +26,90 @@ import (
 	"project/service"
 )
 
+// shutdownTimeout bounds how long open requests may take to finish on
+// shutdown before they are cut off.
+const shutdownTimeout = 15 * time.Second
+
 func main() {
 	cfg := config.Load()
+	slog.SetDefault(newLogger(cfg, os.Stderr))
 
 	// Run migrations
 	if err := runMigrations(cfg.DatabaseURL); err != nil {
-		log.Printf("Warning: migration failed: %v", err)
+		slog.Warn("Migration failed", "error", err)
 	}
 
 	// Connect to database
 	pool, err := pgxpool.New(context.Background(), cfg.DatabaseURL)
 	if err != nil {
-		log.Fatalf("Failed to open database: %v", err)
+		fatal("Failed to open database", err)
 	}
 	defer pool.Close()
 
 	// Verify database connection
 	if err := pool.Ping(context.Background()); err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
+		fatal("Failed to connect to database", err)
 	}
 
 	// Initialize layers
 	queries := compiled.New(pool)
-	authService := service.NewAuthService(queries, cfg.ResendAPIKey)
-	companyService := service.NewCompanyService(queries)
-	h := handler.NewHandler(authService, companyService, queries)
+	mailer := service.NewMailer(cfg.ResendAPIKey)
+	payments, err := newPaymentProvider(cfg.PaymentProvider)
+	if err != nil {
+		fatal("Invalid payment provider", err)
+	}
+	authService := service.NewAuthService(pool, queries, mailer)
+	companyService := service.NewCompanyService(pool, queries, mailer, payments, net.DefaultResolver, cfg.AppURL, cfg.InvitationTTL, cfg.RestoreWindow)
+	exportService := service.NewExportService(queries, companyService, mailer, cfg.APIURL, cfg.ExportLinkTTL)
+	accountService := service.NewAccountService(pool, queries, cfg.AnonymizeAfter, cfg.RetentionPeriod, cfg.AuditRetention)
+	webhookService := service.NewWebhookService(pool, queries, companyService)
+	auditExportService := service.NewAuditExportService(queries, companyService, cfg.AuditExportDir)
+	notificationService := service.NewNotificationService(pool, queries, mailer, cfg.AppURL)
+	realtimeHub := service.NewRealtimeHub(pool, queries)
+
+	// Side effects of domain events run as bus subscribers
+	events := service.NewEventBus(queries)
+	service.SubscribeAuditLog(events, queries)
+	companyService.Subscribe(events)
+	webhookService.Subscribe(events)
+	notificationService.Subscribe(events)
+	realtimeHub.Subscribe(events)
+	trustedProxies, err := handler.ParseTrustedProxies(cfg.TrustedProxies)
+	if err != nil {
+		fatal("Invalid trusted proxies", err)
+	}
+	h := handler.NewHandler(authService, companyService, exportService, accountService, webhookService, auditExportService, notificationService, realtimeHub, queries, trustedProxies)
 	h.LoadTokenCache(context.Background())
 
-	// Start gRPC server with auth interceptor
+	// Start gRPC server. Request IDs come first so that the access log and
+	// recovered panics can be traced, the access log sees the status of
+	// recovered panics, and recovery wraps everything that runs handler code.
 	grpcServer := grpc.NewServer(
-		grpc.UnaryInterceptor(h.AuthInterceptor()),
+		grpc.ChainUnaryInterceptor(
+			handler.RequestIDInterceptor(),
+			handler.AccessLogInterceptor(),
+			handler.RecoveryInterceptor(),
+			handler.DeadlineInterceptor(cfg.RequestTimeout),
+			h.AuthInterceptor(),
+		),
+		grpc.ChainStreamInterceptor(
+			handler.StreamRequestIDInterceptor(),
+			handler.StreamAccessLogInterceptor(),
+			handler.StreamRecoveryInterceptor(),
+			h.StreamAuthInterceptor(),
+		),
 	)
 	compiled.RegisterAPIServer(grpcServer, h)
 
 	grpcListener, err := net.Listen("tcp", ":"+cfg.GRPCPort)
 	if err != nil {
-		log.Fatalf("Failed to listen on gRPC port: %v", err)
+		fatal("Failed to listen on gRPC port", err)
 	}
 
 	go func() {
-		log.Printf("gRPC server listening on :%s", cfg.GRPCPort)
+		slog.Info("gRPC server listening", "port", cfg.GRPCPort)
 		if err := grpcServer.Serve(grpcListener); err != nil {
-			log.Fatalf("Failed to serve gRPC: %v", err)
+			fatal("Failed to serve gRPC", err)
 		}
 	}()
 
@@ -73,11 +117,30 @@ func main() {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	mux := runtime.NewServeMux()
-	opts := []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}
+	// Dispatch domain events, build data exports, deliver webhooks and apply
+	// data retention in the background
+	go events.Run(ctx)
+	go exportService.Run(ctx)
+	go webhookService.Run(ctx)
+	go auditExportService.Run(ctx)
+	go realtimeHub.Run(ctx)
+	go accountService.Run(ctx)
+
+	mux := runtime.NewServeMux(
+		runtime.WithIncomingHeaderMatcher(handler.IncomingHeaderMatcher),
+		runtime.WithOutgoingHeaderMatcher(handler.OutgoingHeaderMatcher),
+	)
+	conn, err := grpc.NewClient("localhost:"+cfg.GRPCPort, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		fatal("Failed to dial gRPC server", err)
+	}
+	defer conn.Close()
 
-	if err := compiled.RegisterAPIHandlerFromEndpoint(ctx, mux, "localhost:"+cfg.GRPCPort, opts); err != nil {
-		log.Fatalf("Failed to register gateway: %v", err)
+	if err := compiled.RegisterAPIHandler(ctx, mux, conn); err != nil {
+		fatal("Failed to register gateway", err)
+	}
+	if err := handler.RegisterGatewayRoutes(mux, compiled.NewAPIClient(conn), exportService); err != nil {
+		fatal("Failed to register gateway routes", err)
 	}
 
 	httpServer := &http.Server{
@@ -86,9 +149,9 @@ func main() {
 	}
 
 	go func() {
-		log.Printf("HTTP gateway listening on :%s", cfg.HTTPPort)
+		slog.Info("HTTP gateway listening", "port", cfg.HTTPPort)
 		if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("Failed to serve HTTP: %v", err)
+			fatal("Failed to serve HTTP", err)
 		}
 	}()
 
@@ -97,16 +160,56 @@ func main() {
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 	<-sigChan
 
-	log.Println("Shutting down...")
-	grpcServer.GracefulStop()
-	httpServer.Shutdown(ctx)
+	// Cancelling first stops the background workers and closes realtime
+	// watchers, which ends open WatchEvents streams and SSE connections
+	slog.Info("Shutting down")
+	cancel()
+
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancelShutdown()
+	if err := httpServer.Shutdown(shutdownCtx); err != nil {
+		slog.Warn("HTTP gateway did not shut down cleanly", "error", err)
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		grpcServer.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-shutdownCtx.Done():
+		slog.Warn("gRPC server did not drain in time, closing open calls")
+		grpcServer.Stop()
+	}
+}
+
+// newPaymentProvider returns nil when billing is not configured, which
+// leaves companies on free plans only.
+func newPaymentProvider(name string) (service.PaymentProvider, error) {
+	switch name {
+	case "":
+		slog.Warn("No payment provider configured, paid plans are disabled")
+		return nil, nil
+	case "fake":
+		slog.Warn("Using the fake payment provider, every charge is accepted")
+		return service.NewFakePaymentProvider(), nil
+	default:
+		return nil, fmt.Errorf("unknown payment provider %q", name)
+	}
+}
+
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
 }
 
 func corsHandler(h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Company-Id, X-Request-Id")
+		w.Header().Set("Access-Control-Expose-Headers", "X-Request-Id")
 
 		if r.Method == http.MethodOptions {
 			w.WriteHeader(http.StatusNoContent)
@@ -132,6 +235,6 @@ func runMigrations(databaseURL string) error {
 		return err
 	}
 
-	log.Println("Migrations completed successfully")
+	slog.Info("Migrations completed successfully")
 	return nil
 }
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_invitations_pending_email;
+DROP TABLE IF EXISTS invitations;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_company_join_links_company_id;
+DROP TABLE IF EXISTS company_join_links;
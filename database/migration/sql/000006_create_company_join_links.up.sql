@@ -0,0 +1,16 @@
+CREATE TABLE company_join_links (
+    id SERIAL PRIMARY KEY,
+    company_id INTEGER NOT NULL REFERENCES companies(id),
+    token VARCHAR(64) UNIQUE NOT NULL,
+    role VARCHAR(20) NOT NULL CHECK (role IN ('admin', 'member')),
+    max_uses INTEGER CHECK (max_uses > 0),
+    use_count INTEGER NOT NULL DEFAULT 0,
+    email_domain VARCHAR(255),
+    expires_at TIMESTAMP,
+    created_by INTEGER NOT NULL REFERENCES users(id),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    disabled_at TIMESTAMP,
+    CHECK (max_uses IS NULL OR use_count <= max_uses)
+);
+
+CREATE INDEX idx_company_join_links_company_id ON company_join_links(company_id);
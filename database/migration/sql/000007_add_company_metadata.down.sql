@@ -0,0 +1,4 @@
+ALTER TABLE companies DROP COLUMN updated_at;
+ALTER TABLE companies DROP COLUMN archived_at;
+ALTER TABLE companies DROP COLUMN website;
+ALTER TABLE companies DROP COLUMN description;
@@ -0,0 +1,4 @@
+ALTER TABLE companies ADD COLUMN description TEXT NOT NULL DEFAULT '';
+ALTER TABLE companies ADD COLUMN website VARCHAR(255) NOT NULL DEFAULT '';
+ALTER TABLE companies ADD COLUMN archived_at TIMESTAMP;
+ALTER TABLE companies ADD COLUMN updated_at TIMESTAMP;
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_team_members_user_id;
+DROP INDEX IF EXISTS idx_teams_parent_team_id;
+DROP INDEX IF EXISTS idx_teams_company_name;
+DROP TABLE IF EXISTS team_members;
+DROP TABLE IF EXISTS teams;
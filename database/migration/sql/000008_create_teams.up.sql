@@ -0,0 +1,20 @@
+CREATE TABLE teams (
+    id SERIAL PRIMARY KEY,
+    company_id INTEGER NOT NULL REFERENCES companies(id),
+    parent_team_id INTEGER REFERENCES teams(id),
+    name VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+CREATE TABLE team_members (
+    id SERIAL PRIMARY KEY,
+    team_id INTEGER NOT NULL REFERENCES teams(id),
+    user_id INTEGER NOT NULL REFERENCES users(id),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(team_id, user_id)
+);
+
+CREATE UNIQUE INDEX idx_teams_company_name ON teams(company_id, LOWER(name)) WHERE deleted_at IS NULL;
+CREATE INDEX idx_teams_parent_team_id ON teams(parent_team_id) WHERE deleted_at IS NULL;
+CREATE INDEX idx_team_members_user_id ON team_members(user_id);
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_users_lower_email_id;
+DROP INDEX IF EXISTS idx_users_lower_name_id;
+DROP INDEX IF EXISTS idx_company_users_company_role;
+DROP INDEX IF EXISTS idx_company_users_company_joined;
+DROP INDEX IF EXISTS idx_users_email_trgm;
+DROP INDEX IF EXISTS idx_users_name_trgm;
@@ -0,0 +1,13 @@
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+-- Case-insensitive substring search over member names and emails
+CREATE INDEX idx_users_name_trgm ON users USING gin (name gin_trgm_ops);
+CREATE INDEX idx_users_email_trgm ON users USING gin (email gin_trgm_ops);
+
+-- Keyset pagination by join date and role filtering within a company
+CREATE INDEX idx_company_users_company_joined ON company_users(company_id, created_at, user_id) WHERE deleted_at IS NULL;
+CREATE INDEX idx_company_users_company_role ON company_users(company_id, role) WHERE deleted_at IS NULL;
+
+-- Keyset pagination by name and email
+CREATE INDEX idx_users_lower_name_id ON users(LOWER(name), id) WHERE deleted_at IS NULL;
+CREATE INDEX idx_users_lower_email_id ON users(LOWER(email), id) WHERE deleted_at IS NULL;
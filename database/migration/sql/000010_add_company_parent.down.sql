@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_companies_parent_company_id;
+ALTER TABLE companies DROP CONSTRAINT IF EXISTS companies_parent_not_self;
+ALTER TABLE companies DROP COLUMN parent_company_id;
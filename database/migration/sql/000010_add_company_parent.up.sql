@@ -0,0 +1,4 @@
+ALTER TABLE companies ADD COLUMN parent_company_id INTEGER REFERENCES companies(id);
+ALTER TABLE companies ADD CONSTRAINT companies_parent_not_self CHECK (parent_company_id <> id);
+
+CREATE INDEX idx_companies_parent_company_id ON companies(parent_company_id) WHERE deleted_at IS NULL;
@@ -0,0 +1,29 @@
+CREATE TABLE plans (
+    id SERIAL PRIMARY KEY,
+    code VARCHAR(50) UNIQUE NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    -- NULL means unlimited seats
+    seat_limit INTEGER CHECK (seat_limit > 0),
+    features TEXT[] NOT NULL DEFAULT '{}',
+    price_cents INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE subscriptions (
+    id SERIAL PRIMARY KEY,
+    company_id INTEGER UNIQUE NOT NULL REFERENCES companies(id),
+    plan_id INTEGER NOT NULL REFERENCES plans(id),
+    status VARCHAR(20) NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'past_due', 'canceled')),
+    provider_customer_id VARCHAR(255),
+    provider_subscription_id VARCHAR(255),
+    current_period_end TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Companies without a subscription row are on the free plan
+INSERT INTO plans (code, name, seat_limit, features, price_cents) VALUES
+    ('free', 'Free', 5, '{}', 0),
+    ('team', 'Team', 25, '{teams,join_links,bulk_invite}', 4900),
+    ('business', 'Business', 100, '{teams,join_links,bulk_invite,company_hierarchy}', 19900),
+    ('enterprise', 'Enterprise', NULL, '{teams,join_links,bulk_invite,company_hierarchy}', 99900);
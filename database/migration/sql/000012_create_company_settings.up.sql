@@ -0,0 +1,8 @@
+CREATE TABLE company_settings (
+    company_id INTEGER PRIMARY KEY REFERENCES companies(id),
+    -- Only settings that were explicitly changed are stored; the rest use
+    -- the defaults defined in code.
+    settings JSONB NOT NULL DEFAULT '{}',
+    updated_by INTEGER REFERENCES users(id),
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
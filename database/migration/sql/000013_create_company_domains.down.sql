@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_company_domains_verified_domain;
+DROP INDEX IF EXISTS idx_company_domains_company_domain;
+DROP TABLE IF EXISTS company_domains;
@@ -0,0 +1,15 @@
+CREATE TABLE company_domains (
+    id SERIAL PRIMARY KEY,
+    company_id INTEGER NOT NULL REFERENCES companies(id),
+    domain VARCHAR(255) NOT NULL,
+    verification_token VARCHAR(64) NOT NULL,
+    join_role VARCHAR(20) NOT NULL DEFAULT 'member' CHECK (join_role IN ('admin', 'member')),
+    created_by INTEGER NOT NULL REFERENCES users(id),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    verified_at TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+-- A company claims a domain once; a verified domain belongs to one company
+CREATE UNIQUE INDEX idx_company_domains_company_domain ON company_domains(company_id, LOWER(domain)) WHERE deleted_at IS NULL;
+CREATE UNIQUE INDEX idx_company_domains_verified_domain ON company_domains(LOWER(domain)) WHERE verified_at IS NOT NULL AND deleted_at IS NULL;
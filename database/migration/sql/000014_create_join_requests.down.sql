@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_join_requests_pending;
+DROP TABLE IF EXISTS join_requests;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_data_exports_pending;
+DROP TABLE IF EXISTS data_exports;
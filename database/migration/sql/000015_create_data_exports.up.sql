@@ -0,0 +1,17 @@
+CREATE TABLE data_exports (
+    id SERIAL PRIMARY KEY,
+    kind VARCHAR(20) NOT NULL CHECK (kind IN ('user', 'company')),
+    requested_by INTEGER NOT NULL REFERENCES users(id),
+    company_id INTEGER REFERENCES companies(id),
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'running', 'ready', 'failed')),
+    archive BYTEA,
+    download_token VARCHAR(64) UNIQUE,
+    expires_at TIMESTAMP,
+    error TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    started_at TIMESTAMP,
+    completed_at TIMESTAMP,
+    CHECK (kind = 'user' OR company_id IS NOT NULL)
+);
+
+CREATE INDEX idx_data_exports_pending ON data_exports(id) WHERE status IN ('pending', 'running');
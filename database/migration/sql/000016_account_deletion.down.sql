@@ -0,0 +1,75 @@
+ALTER TABLE data_exports
+    DROP CONSTRAINT data_exports_requested_by_fkey,
+    ADD CONSTRAINT data_exports_requested_by_fkey FOREIGN KEY (requested_by) REFERENCES users(id),
+    DROP CONSTRAINT data_exports_company_id_fkey,
+    ADD CONSTRAINT data_exports_company_id_fkey FOREIGN KEY (company_id) REFERENCES companies(id);
+
+ALTER TABLE join_requests
+    DROP CONSTRAINT join_requests_reviewed_by_fkey,
+    ADD CONSTRAINT join_requests_reviewed_by_fkey FOREIGN KEY (reviewed_by) REFERENCES users(id),
+    DROP CONSTRAINT join_requests_user_id_fkey,
+    ADD CONSTRAINT join_requests_user_id_fkey FOREIGN KEY (user_id) REFERENCES users(id),
+    DROP CONSTRAINT join_requests_company_id_fkey,
+    ADD CONSTRAINT join_requests_company_id_fkey FOREIGN KEY (company_id) REFERENCES companies(id);
+
+ALTER TABLE company_domains
+    DROP CONSTRAINT company_domains_created_by_fkey,
+    ADD CONSTRAINT company_domains_created_by_fkey FOREIGN KEY (created_by) REFERENCES users(id),
+    DROP CONSTRAINT company_domains_company_id_fkey,
+    ADD CONSTRAINT company_domains_company_id_fkey FOREIGN KEY (company_id) REFERENCES companies(id),
+    ALTER COLUMN created_by SET NOT NULL;
+
+ALTER TABLE company_settings
+    DROP CONSTRAINT company_settings_updated_by_fkey,
+    ADD CONSTRAINT company_settings_updated_by_fkey FOREIGN KEY (updated_by) REFERENCES users(id),
+    DROP CONSTRAINT company_settings_company_id_fkey,
+    ADD CONSTRAINT company_settings_company_id_fkey FOREIGN KEY (company_id) REFERENCES companies(id);
+
+ALTER TABLE subscriptions
+    DROP CONSTRAINT subscriptions_company_id_fkey,
+    ADD CONSTRAINT subscriptions_company_id_fkey FOREIGN KEY (company_id) REFERENCES companies(id);
+
+ALTER TABLE team_members
+    DROP CONSTRAINT team_members_user_id_fkey,
+    ADD CONSTRAINT team_members_user_id_fkey FOREIGN KEY (user_id) REFERENCES users(id),
+    DROP CONSTRAINT team_members_team_id_fkey,
+    ADD CONSTRAINT team_members_team_id_fkey FOREIGN KEY (team_id) REFERENCES teams(id);
+
+ALTER TABLE teams
+    DROP CONSTRAINT teams_parent_team_id_fkey,
+    ADD CONSTRAINT teams_parent_team_id_fkey FOREIGN KEY (parent_team_id) REFERENCES teams(id),
+    DROP CONSTRAINT teams_company_id_fkey,
+    ADD CONSTRAINT teams_company_id_fkey FOREIGN KEY (company_id) REFERENCES companies(id);
+
+ALTER TABLE company_join_links
+    DROP CONSTRAINT company_join_links_created_by_fkey,
+    ADD CONSTRAINT company_join_links_created_by_fkey FOREIGN KEY (created_by) REFERENCES users(id),
+    DROP CONSTRAINT company_join_links_company_id_fkey,
+    ADD CONSTRAINT company_join_links_company_id_fkey FOREIGN KEY (company_id) REFERENCES companies(id),
+    ALTER COLUMN created_by SET NOT NULL;
+
+ALTER TABLE invitations
+    DROP CONSTRAINT invitations_invited_by_fkey,
+    ADD CONSTRAINT invitations_invited_by_fkey FOREIGN KEY (invited_by) REFERENCES users(id),
+    DROP CONSTRAINT invitations_company_id_fkey,
+    ADD CONSTRAINT invitations_company_id_fkey FOREIGN KEY (company_id) REFERENCES companies(id),
+    ALTER COLUMN invited_by SET NOT NULL;
+
+ALTER TABLE companies
+    DROP CONSTRAINT companies_parent_company_id_fkey,
+    ADD CONSTRAINT companies_parent_company_id_fkey FOREIGN KEY (parent_company_id) REFERENCES companies(id);
+
+ALTER TABLE users
+    DROP CONSTRAINT users_selected_company_id_fkey,
+    ADD CONSTRAINT users_selected_company_id_fkey FOREIGN KEY (selected_company_id) REFERENCES companies(id);
+
+ALTER TABLE company_users
+    DROP CONSTRAINT company_users_user_id_fkey,
+    ADD CONSTRAINT company_users_user_id_fkey FOREIGN KEY (user_id) REFERENCES users(id),
+    DROP CONSTRAINT company_users_company_id_fkey,
+    ADD CONSTRAINT company_users_company_id_fkey FOREIGN KEY (company_id) REFERENCES companies(id);
+
+DROP INDEX IF EXISTS idx_companies_deleted_at;
+DROP INDEX IF EXISTS idx_users_deleted_at;
+
+ALTER TABLE users DROP COLUMN anonymized_at;
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_webhook_deliveries_endpoint;
+DROP INDEX IF EXISTS idx_webhook_deliveries_due;
+DROP TABLE IF EXISTS webhook_deliveries;
+DROP INDEX IF EXISTS idx_webhook_endpoints_company;
+DROP TABLE IF EXISTS webhook_endpoints;
@@ -0,0 +1,7 @@
+DROP TRIGGER IF EXISTS audit_events_no_update ON audit_events;
+DROP FUNCTION IF EXISTS audit_events_append_only();
+DROP INDEX IF EXISTS idx_audit_events_created;
+DROP INDEX IF EXISTS idx_audit_events_target;
+DROP INDEX IF EXISTS idx_audit_events_actor;
+DROP INDEX IF EXISTS idx_audit_events_company;
+DROP TABLE IF EXISTS audit_events;
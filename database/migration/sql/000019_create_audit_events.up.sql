@@ -0,0 +1,29 @@
+-- Audit events outlive the users and companies they mention, so the IDs
+-- carry no foreign keys
+CREATE TABLE audit_events (
+    id BIGSERIAL PRIMARY KEY,
+    action VARCHAR(100) NOT NULL,
+    actor_id INTEGER,
+    target_user_id INTEGER,
+    company_id INTEGER,
+    ip_address VARCHAR(64),
+    user_agent TEXT,
+    metadata JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_audit_events_company ON audit_events(company_id, id);
+CREATE INDEX idx_audit_events_actor ON audit_events(actor_id, id);
+CREATE INDEX idx_audit_events_target ON audit_events(target_user_id, id);
+CREATE INDEX idx_audit_events_created ON audit_events(created_at);
+
+-- Rows are never changed once written; only the retention job deletes them
+CREATE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_events_no_update
+    BEFORE UPDATE ON audit_events
+    FOR EACH ROW EXECUTE FUNCTION audit_events_append_only();
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_audit_sinks_due;
+DROP INDEX IF EXISTS idx_audit_sinks_company;
+DROP TABLE IF EXISTS audit_sinks;
@@ -0,0 +1,24 @@
+CREATE TABLE audit_sinks (
+    id SERIAL PRIMARY KEY,
+    company_id INTEGER NOT NULL REFERENCES companies(id) ON DELETE CASCADE,
+    kind VARCHAR(20) NOT NULL CHECK (kind IN ('file', 'syslog', 'http')),
+    -- File name, host:port or URL depending on kind
+    destination TEXT NOT NULL,
+    -- Syslog transport
+    protocol VARCHAR(10) CHECK (protocol IN ('tcp', 'udp')),
+    -- Signs HTTP batches like webhook payloads
+    secret VARCHAR(64) NOT NULL,
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    -- ID of the last audit event delivered
+    checkpoint_id BIGINT NOT NULL DEFAULT 0,
+    next_run_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_exported_at TIMESTAMP,
+    last_error TEXT,
+    created_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CHECK (kind <> 'syslog' OR protocol IS NOT NULL)
+);
+
+CREATE INDEX idx_audit_sinks_company ON audit_sinks(company_id);
+CREATE INDEX idx_audit_sinks_due ON audit_sinks(next_run_at) WHERE enabled;
@@ -0,0 +1,31 @@
+CREATE TABLE notifications (
+    id BIGSERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    type VARCHAR(50) NOT NULL,
+    company_id INTEGER REFERENCES companies(id) ON DELETE CASCADE,
+    -- Outbox event the notification was created from; redelivered events
+    -- do not notify twice
+    source_event_id BIGINT NOT NULL,
+    title TEXT NOT NULL,
+    body TEXT NOT NULL,
+    data JSONB NOT NULL DEFAULT '{}',
+    -- FALSE when the user only wants this type by email
+    in_app BOOLEAN NOT NULL,
+    emailed_at TIMESTAMP,
+    read_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (user_id, type, source_event_id)
+);
+
+CREATE INDEX idx_notifications_inbox ON notifications(user_id, id DESC) WHERE in_app;
+CREATE INDEX idx_notifications_unread ON notifications(user_id) WHERE in_app AND read_at IS NULL;
+CREATE INDEX idx_notifications_created ON notifications(created_at);
+
+CREATE TABLE notification_preferences (
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    type VARCHAR(50) NOT NULL,
+    in_app BOOLEAN NOT NULL,
+    email BOOLEAN NOT NULL,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, type)
+);
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_audit_events_source_event;
+ALTER TABLE audit_events DROP COLUMN IF EXISTS recorded_at;
+ALTER TABLE audit_events DROP COLUMN IF EXISTS source_event_id;
+ALTER TABLE outbox_events DROP COLUMN IF EXISTS user_agent;
+ALTER TABLE outbox_events DROP COLUMN IF EXISTS ip_address;
@@ -0,0 +1,12 @@
+-- Audit rows are written by a bus subscriber, so events carry the client
+-- details of the request that recorded them
+ALTER TABLE outbox_events ADD COLUMN ip_address VARCHAR(64);
+ALTER TABLE outbox_events ADD COLUMN user_agent TEXT;
+
+-- created_at is when the change happened; recorded_at is when the row was
+-- written, which sinks use to hold back rows still being committed
+ALTER TABLE audit_events ADD COLUMN source_event_id BIGINT;
+ALTER TABLE audit_events ADD COLUMN recorded_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP;
+
+-- One audit row per outbox event, so redelivered events are not logged twice
+CREATE UNIQUE INDEX idx_audit_events_source_event ON audit_events(source_event_id) WHERE source_event_id IS NOT NULL;
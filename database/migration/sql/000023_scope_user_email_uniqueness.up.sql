@@ -0,0 +1,4 @@
+-- Deleted accounts keep their row until anonymized, so only active accounts
+-- claim an email; addresses are compared case-insensitively
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_email_key;
+CREATE UNIQUE INDEX idx_users_email_active ON users (LOWER(email)) WHERE deleted_at IS NULL;
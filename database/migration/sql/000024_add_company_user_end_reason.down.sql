@@ -0,0 +1 @@
+ALTER TABLE company_users DROP COLUMN IF EXISTS end_reason;
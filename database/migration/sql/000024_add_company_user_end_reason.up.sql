@@ -0,0 +1,3 @@
+-- Why a membership ended: removed, left or account_deleted. Only removed
+-- members can be restored by an admin. Earlier rows stay NULL (unknown).
+ALTER TABLE company_users ADD COLUMN end_reason VARCHAR(20);
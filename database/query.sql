@@ -1,7 +1,12 @@
 -- name: FindUserByEmail :one
 SELECT id, email, name, token, otp, otp_expires_at, created_at
 FROM users
-WHERE email = $1 AND deleted_at IS NULL;
+WHERE LOWER(email) = LOWER($1) AND deleted_at IS NULL;
+
+-- name: GetUserByID :one
+SELECT id, email, name
+FROM users
+WHERE id = $1 AND deleted_at IS NULL;
 
 -- name: FindUserByToken :one
 SELECT id, email, name, selected_company_id, created_at
@@ -29,10 +34,32 @@ VALUES ($1, $2)
 RETURNING id, company_name, owner_id, created_at;
 
 -- name: GetCompanyByID :one
-SELECT id, company_name, owner_id, created_at
+SELECT id, company_name, owner_id, created_at, description, website, archived_at, parent_company_id
 FROM companies
 WHERE id = $1 AND deleted_at IS NULL;
 
+-- name: UpdateCompany :one
+UPDATE companies SET
+    company_name = COALESCE(sqlc.narg(company_name), company_name),
+    description = COALESCE(sqlc.narg(description), description),
+    website = COALESCE(sqlc.narg(website), website),
+    updated_at = NOW()
+WHERE id = sqlc.arg(id) AND deleted_at IS NULL
+RETURNING id, company_name, owner_id, created_at, description, website, archived_at, parent_company_id;
+
+-- name: SetCompanyArchived :exec
+UPDATE companies SET archived_at = $1, updated_at = NOW()
+WHERE id = $2 AND deleted_at IS NULL;
+
+-- name: SoftDeleteCompany :execrows
+UPDATE companies SET deleted_at = NOW()
+WHERE id = $1 AND deleted_at IS NULL;
+
+-- name: ClearSelectedCompany :many
+UPDATE users SET selected_company_id = NULL
+WHERE selected_company_id = $1
+RETURNING id;
+
 -- name: IsUserCompanyOwner :one
 SELECT EXISTS(
     SELECT 1 FROM companies
@@ -41,8 +68,16 @@ SELECT EXISTS(
 
 -- Company users queries
 -- name: AddUserToCompany :one
+-- Re-adding a removed member revives their soft-deleted row as a fresh
+-- membership. Returns no row if the user is already an active member.
 INSERT INTO company_users (company_id, user_id, role)
 VALUES ($1, $2, $3)
+ON CONFLICT (company_id, user_id) DO UPDATE SET
+    role = EXCLUDED.role,
+    created_at = NOW(),
+    deleted_at = NULL,
+    end_reason = NULL
+WHERE company_users.deleted_at IS NOT NULL
 RETURNING id, company_id, user_id, role, created_at;
 
 -- name: GetCompanyUser :one
@@ -51,20 +86,99 @@ FROM company_users
 WHERE company_id = $1 AND user_id = $2 AND deleted_at IS NULL;
 
 -- name: GetUserCompanies :many
-SELECT c.id, c.company_name, c.owner_id, c.created_at, cu.role
+SELECT c.id, c.company_name, c.owner_id, c.created_at, c.archived_at, c.parent_company_id, cu.role
 FROM companies c
 JOIN company_users cu ON cu.company_id = c.id
 WHERE cu.user_id = $1 AND cu.deleted_at IS NULL AND c.deleted_at IS NULL;
 
+-- Company hierarchy queries
+-- name: SetCompanyParent :exec
+UPDATE companies SET parent_company_id = $1, updated_at = NOW()
+WHERE id = $2 AND deleted_at IS NULL;
+
+-- name: DetachChildCompanies :exec
+UPDATE companies SET parent_company_id = NULL, updated_at = NOW()
+WHERE parent_company_id = $1;
+
+-- name: LockCompanies :many
+SELECT id FROM companies
+WHERE id = ANY(sqlc.arg(ids)::int[])
+ORDER BY id
+FOR UPDATE;
+
+-- name: LockCompanyHierarchy :exec
+-- Serializes changes to parent links across all companies until the
+-- transaction ends. Locking only the companies involved is not enough: two
+-- attaches in different parts of a tree can close a longer cycle together.
+SELECT pg_advisory_xact_lock(hashtextextended('company_hierarchy', 0));
+
+-- name: GetCompanyAncestorIDs :many
+-- Includes the company itself. UNION guards against looping on a cycle.
+WITH RECURSIVE ancestors AS (
+    SELECT c.id, c.parent_company_id FROM companies c WHERE c.id = $1
+    UNION
+    SELECT p.id, p.parent_company_id FROM companies p
+    JOIN ancestors a ON p.id = a.parent_company_id
+    WHERE p.deleted_at IS NULL
+)
+SELECT id FROM ancestors;
+
+-- name: IsUserAdminOfAncestorCompany :one
+WITH RECURSIVE ancestors AS (
+    SELECT c.parent_company_id AS id FROM companies c
+    WHERE c.id = sqlc.arg(company_id) AND c.deleted_at IS NULL
+    UNION
+    SELECT p.parent_company_id FROM companies p
+    JOIN ancestors a ON p.id = a.id
+    WHERE p.deleted_at IS NULL
+)
+SELECT EXISTS(
+    SELECT 1 FROM company_users cu
+    JOIN ancestors a ON cu.company_id = a.id
+    JOIN companies c ON c.id = cu.company_id
+    WHERE cu.user_id = sqlc.arg(user_id) AND cu.role = 'admin'
+      AND cu.deleted_at IS NULL AND c.deleted_at IS NULL
+) AS is_admin;
+
+-- name: GetInheritedCompanies :many
+-- Descendants of companies the user administers, excluding companies the
+-- user already belongs to directly.
+WITH RECURSIVE descendants AS (
+    SELECT c.id FROM companies c
+    JOIN company_users cu ON cu.company_id = c.id
+    WHERE cu.user_id = sqlc.arg(user_id) AND cu.role = 'admin'
+      AND cu.deleted_at IS NULL AND c.deleted_at IS NULL
+    UNION
+    SELECT ch.id FROM companies ch
+    JOIN descendants d ON ch.parent_company_id = d.id
+    WHERE ch.deleted_at IS NULL
+)
+SELECT c.id, c.company_name, c.owner_id, c.created_at, c.archived_at, c.parent_company_id
+FROM companies c
+JOIN descendants d ON d.id = c.id
+WHERE NOT EXISTS (
+    SELECT 1 FROM company_users cu
+    WHERE cu.company_id = c.id AND cu.user_id = sqlc.arg(user_id) AND cu.deleted_at IS NULL
+)
+ORDER BY c.company_name;
+
 -- name: IsUserMemberOfCompany :one
 SELECT EXISTS(
     SELECT 1 FROM company_users
     WHERE company_id = $1 AND user_id = $2 AND deleted_at IS NULL
 ) AS is_member;
 
+-- name: HasCompanyMembershipHistory :one
+-- True for current members and for users who were removed or left.
+SELECT EXISTS(
+    SELECT 1 FROM company_users
+    WHERE company_id = $1 AND user_id = $2
+) AS has_history;
+
 -- name: GetCompanyUserRole :one
-SELECT role FROM company_users
-WHERE company_id = $1 AND user_id = $2 AND deleted_at IS NULL;
+SELECT cu.role FROM company_users cu
+JOIN companies c ON c.id = cu.company_id
+WHERE cu.company_id = $1 AND cu.user_id = $2 AND cu.deleted_at IS NULL AND c.deleted_at IS NULL;
 
 -- name: GetCompanyMembers :many
 SELECT u.id, u.name, u.email, cu.role
@@ -72,10 +186,82 @@ FROM company_users cu
 JOIN users u ON cu.user_id = u.id
 WHERE cu.company_id = $1 AND cu.deleted_at IS NULL AND u.deleted_at IS NULL;
 
+-- Paginated member listing: one query per supported ordering so each can
+-- use keyset pagination on its own index.
+
+-- name: ListCompanyMembersByName :many
+SELECT u.id, u.name, u.email, cu.role, cu.created_at AS joined_at, LOWER(u.name)::text AS sort_key
+FROM company_users cu
+JOIN users u ON cu.user_id = u.id
+WHERE cu.company_id = sqlc.arg(company_id)
+  AND cu.deleted_at IS NULL AND u.deleted_at IS NULL
+  AND (sqlc.narg(role)::text IS NULL OR cu.role = sqlc.narg(role)::text)
+  AND (sqlc.narg(search)::text IS NULL
+    OR u.name ILIKE '%' || sqlc.narg(search)::text || '%'
+    OR u.email ILIKE '%' || sqlc.narg(search)::text || '%')
+  AND (sqlc.narg(after_key)::text IS NULL OR (LOWER(u.name), u.id) > (sqlc.narg(after_key)::text, sqlc.arg(after_id)::int))
+ORDER BY LOWER(u.name), u.id
+LIMIT sqlc.arg(page_limit);
+
+-- name: ListCompanyMembersByEmail :many
+SELECT u.id, u.name, u.email, cu.role, cu.created_at AS joined_at, LOWER(u.email)::text AS sort_key
+FROM company_users cu
+JOIN users u ON cu.user_id = u.id
+WHERE cu.company_id = sqlc.arg(company_id)
+  AND cu.deleted_at IS NULL AND u.deleted_at IS NULL
+  AND (sqlc.narg(role)::text IS NULL OR cu.role = sqlc.narg(role)::text)
+  AND (sqlc.narg(search)::text IS NULL
+    OR u.name ILIKE '%' || sqlc.narg(search)::text || '%'
+    OR u.email ILIKE '%' || sqlc.narg(search)::text || '%')
+  AND (sqlc.narg(after_key)::text IS NULL OR (LOWER(u.email), u.id) > (sqlc.narg(after_key)::text, sqlc.arg(after_id)::int))
+ORDER BY LOWER(u.email), u.id
+LIMIT sqlc.arg(page_limit);
+
+-- name: ListCompanyMembersByJoined :many
+SELECT u.id, u.name, u.email, cu.role, cu.created_at AS joined_at
+FROM company_users cu
+JOIN users u ON cu.user_id = u.id
+WHERE cu.company_id = sqlc.arg(company_id)
+  AND cu.deleted_at IS NULL AND u.deleted_at IS NULL
+  AND (sqlc.narg(role)::text IS NULL OR cu.role = sqlc.narg(role)::text)
+  AND (sqlc.narg(search)::text IS NULL
+    OR u.name ILIKE '%' || sqlc.narg(search)::text || '%'
+    OR u.email ILIKE '%' || sqlc.narg(search)::text || '%')
+  AND (sqlc.narg(after_joined)::timestamp IS NULL OR (cu.created_at, u.id) > (sqlc.narg(after_joined)::timestamp, sqlc.arg(after_id)::int))
+ORDER BY cu.created_at, u.id
+LIMIT sqlc.arg(page_limit);
+
+-- name: ListCompanyMembersByJoinedDesc :many
+SELECT u.id, u.name, u.email, cu.role, cu.created_at AS joined_at
+FROM company_users cu
+JOIN users u ON cu.user_id = u.id
+WHERE cu.company_id = sqlc.arg(company_id)
+  AND cu.deleted_at IS NULL AND u.deleted_at IS NULL
+  AND (sqlc.narg(role)::text IS NULL OR cu.role = sqlc.narg(role)::text)
+  AND (sqlc.narg(search)::text IS NULL
+    OR u.name ILIKE '%' || sqlc.narg(search)::text || '%'
+    OR u.email ILIKE '%' || sqlc.narg(search)::text || '%')
+  AND (sqlc.narg(after_joined)::timestamp IS NULL OR (cu.created_at, u.id) < (sqlc.narg(after_joined)::timestamp, sqlc.arg(after_id)::int))
+ORDER BY cu.created_at DESC, u.id DESC
+LIMIT sqlc.arg(page_limit);
+
 -- name: RemoveUserFromCompany :exec
-UPDATE company_users SET deleted_at = NOW()
+UPDATE company_users SET deleted_at = NOW(), end_reason = sqlc.arg(end_reason)
 WHERE company_id = $1 AND user_id = $2 AND deleted_at IS NULL;
 
+-- name: UpdateCompanyMemberRole :execrows
+UPDATE company_users SET role = $3
+WHERE company_id = $1 AND user_id = $2 AND deleted_at IS NULL;
+
+-- name: LockCompanyAdmins :many
+SELECT user_id FROM company_users
+WHERE company_id = $1 AND role = 'admin' AND deleted_at IS NULL
+FOR UPDATE;
+
+-- name: ClearUserSelectedCompanyIfMatches :exec
+UPDATE users SET selected_company_id = NULL
+WHERE id = $1 AND selected_company_id = $2;
+
 -- name: UpdateUserOTP :exec
 UPDATE users SET otp = $1, otp_expires_at = $2 WHERE id = $3;
 
@@ -83,3 +269,703 @@ UPDATE users SET otp = $1, otp_expires_at = $2 WHERE id = $3;
 SELECT id, email, name, token, selected_company_id, created_at
 FROM users
 WHERE token IS NOT NULL AND deleted_at IS NULL;
+
+-- Invitation queries
+-- name: CreateInvitation :one
+INSERT INTO invitations (company_id, email, name, role, token, invited_by, expires_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+RETURNING id, company_id, email, name, role, token, status, invited_by, expires_at, responded_at, created_at;
+
+-- name: GetInvitationByID :one
+SELECT id, company_id, email, name, role, token, status, invited_by, expires_at, responded_at, created_at
+FROM invitations
+WHERE id = $1 AND company_id = $2;
+
+-- name: GetInvitationByTokenForUpdate :one
+SELECT id, company_id, email, name, role, token, status, invited_by, expires_at, responded_at, created_at
+FROM invitations
+WHERE token = $1
+FOR UPDATE;
+
+-- name: GetPendingInvitationByEmail :one
+SELECT id, company_id, email, name, role, token, status, invited_by, expires_at, responded_at, created_at
+FROM invitations
+WHERE company_id = $1 AND email = $2 AND status = 'pending';
+
+-- name: ListCompanyInvitations :many
+SELECT id, company_id, email, name, role, token, status, invited_by, expires_at, responded_at, created_at
+FROM invitations
+WHERE company_id = $1
+ORDER BY created_at DESC;
+
+-- name: ExpireCompanyInvitations :exec
+UPDATE invitations SET status = 'expired'
+WHERE company_id = $1 AND status = 'pending' AND expires_at <= NOW();
+
+-- name: RevokeCompanyInvitations :exec
+UPDATE invitations SET status = 'revoked', responded_at = NOW()
+WHERE company_id = $1 AND status = 'pending';
+
+-- name: UpdateInvitationStatus :exec
+UPDATE invitations SET status = $1, responded_at = NOW() WHERE id = $2;
+
+-- name: RefreshInvitationToken :one
+UPDATE invitations SET token = $1, expires_at = $2, status = 'pending'
+WHERE id = $3 AND company_id = $4 AND status IN ('pending', 'expired')
+RETURNING id, company_id, email, name, role, token, status, invited_by, expires_at, responded_at, created_at;
+
+-- Join link queries
+-- name: CreateJoinLink :one
+INSERT INTO company_join_links (company_id, token, role, max_uses, email_domain, expires_at, created_by)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+RETURNING id, company_id, token, role, max_uses, use_count, email_domain, expires_at, created_by, created_at, disabled_at;
+
+-- name: ListCompanyJoinLinks :many
+SELECT id, company_id, token, role, max_uses, use_count, email_domain, expires_at, created_by, created_at, disabled_at
+FROM company_join_links
+WHERE company_id = $1
+ORDER BY created_at DESC;
+
+-- name: GetJoinLinkByToken :one
+SELECT id, company_id, token, role, max_uses, use_count, email_domain, expires_at, created_by, created_at, disabled_at
+FROM company_join_links
+WHERE token = $1;
+
+-- name: DisableJoinLink :execrows
+UPDATE company_join_links SET disabled_at = NOW()
+WHERE id = $1 AND company_id = $2 AND disabled_at IS NULL;
+
+-- name: ClaimJoinLinkUse :one
+-- Atomically consumes one use; the row lock serializes concurrent joins so
+-- max_uses is never exceeded.
+UPDATE company_join_links SET use_count = use_count + 1
+WHERE id = $1
+  AND disabled_at IS NULL
+  AND (expires_at IS NULL OR expires_at > NOW())
+  AND (max_uses IS NULL OR use_count < max_uses)
+RETURNING id, company_id, token, role, max_uses, use_count, email_domain, expires_at, created_by, created_at, disabled_at;
+
+-- Team queries
+-- name: CreateTeam :one
+INSERT INTO teams (company_id, parent_team_id, name)
+VALUES ($1, $2, $3)
+RETURNING id, company_id, parent_team_id, name, created_at, deleted_at;
+
+-- name: GetTeam :one
+SELECT id, company_id, parent_team_id, name, created_at, deleted_at
+FROM teams
+WHERE id = $1 AND company_id = $2 AND deleted_at IS NULL;
+
+-- name: ListCompanyTeams :many
+SELECT t.id, t.company_id, t.parent_team_id, t.name, t.created_at,
+    (SELECT COUNT(*) FROM team_members tm WHERE tm.team_id = t.id) AS member_count
+FROM teams t
+WHERE t.company_id = $1 AND t.deleted_at IS NULL
+ORDER BY t.name;
+
+-- name: UpdateTeam :one
+UPDATE teams SET name = $1, parent_team_id = $2
+WHERE id = $3 AND company_id = $4 AND deleted_at IS NULL
+RETURNING id, company_id, parent_team_id, name, created_at, deleted_at;
+
+-- name: SoftDeleteTeam :exec
+UPDATE teams SET deleted_at = NOW() WHERE id = $1;
+
+-- name: CountChildTeams :one
+SELECT COUNT(*) FROM teams
+WHERE parent_team_id = $1 AND deleted_at IS NULL;
+
+-- name: GetTeamAncestorIDs :many
+-- Includes the team itself. UNION guards against looping on a cycle.
+WITH RECURSIVE ancestors AS (
+    SELECT t.id, t.parent_team_id FROM teams t WHERE t.id = $1
+    UNION
+    SELECT p.id, p.parent_team_id FROM teams p JOIN ancestors a ON p.id = a.parent_team_id
+)
+SELECT id FROM ancestors;
+
+-- name: AddTeamMember :exec
+INSERT INTO team_members (team_id, user_id)
+VALUES ($1, $2)
+ON CONFLICT (team_id, user_id) DO NOTHING;
+
+-- name: RemoveTeamMember :execrows
+DELETE FROM team_members WHERE team_id = $1 AND user_id = $2;
+
+-- name: DeleteTeamMembers :exec
+DELETE FROM team_members WHERE team_id = $1;
+
+-- name: RemoveUserFromCompanyTeams :exec
+DELETE FROM team_members
+WHERE user_id = $1 AND team_id IN (SELECT id FROM teams WHERE company_id = $2);
+
+-- name: ListUserTeams :many
+SELECT t.id, t.company_id, t.parent_team_id, t.name, t.created_at
+FROM teams t
+JOIN team_members tm ON tm.team_id = t.id
+WHERE tm.user_id = $1 AND t.company_id = $2 AND t.deleted_at IS NULL
+ORDER BY t.name;
+
+-- name: GetCompanyTeamMemberships :many
+SELECT tm.user_id, t.id AS team_id, t.name AS team_name
+FROM team_members tm
+JOIN teams t ON t.id = tm.team_id
+WHERE t.company_id = $1 AND t.deleted_at IS NULL
+ORDER BY t.name;
+
+-- name: GetTeamMembershipsForUsers :many
+SELECT tm.user_id, t.id AS team_id, t.name AS team_name
+FROM team_members tm
+JOIN teams t ON t.id = tm.team_id
+WHERE t.company_id = $1 AND t.deleted_at IS NULL AND tm.user_id = ANY(sqlc.arg(user_ids)::int[])
+ORDER BY t.name;
+
+-- name: IsUserInTeamTree :one
+-- Members of a nested team count as members of every ancestor team. UNION
+-- guards against looping on a cycle.
+WITH RECURSIVE tree AS (
+    SELECT t.id FROM teams t WHERE t.id = sqlc.arg(team_id) AND t.deleted_at IS NULL
+    UNION
+    SELECT c.id FROM teams c JOIN tree ON c.parent_team_id = tree.id WHERE c.deleted_at IS NULL
+)
+SELECT EXISTS(
+    SELECT 1 FROM team_members tm JOIN tree ON tm.team_id = tree.id
+    WHERE tm.user_id = sqlc.arg(user_id)
+) AS is_member;
+
+-- Plan and subscription queries
+-- name: ListPlans :many
+SELECT id, code, name, seat_limit, features, price_cents, created_at
+FROM plans
+ORDER BY price_cents, id;
+
+-- name: GetPlanByCode :one
+SELECT id, code, name, seat_limit, features, price_cents, created_at
+FROM plans
+WHERE code = $1;
+
+-- name: GetCompanySubscription :one
+SELECT id, company_id, plan_id, status, provider_customer_id, provider_subscription_id, current_period_end, created_at, updated_at
+FROM subscriptions
+WHERE company_id = $1;
+
+-- name: GetPlanByID :one
+SELECT id, code, name, seat_limit, features, price_cents, created_at
+FROM plans
+WHERE id = $1;
+
+-- name: UpsertCompanySubscription :one
+INSERT INTO subscriptions (company_id, plan_id, status, provider_customer_id, provider_subscription_id, current_period_end)
+VALUES ($1, $2, $3, $4, $5, $6)
+ON CONFLICT (company_id) DO UPDATE SET
+    plan_id = EXCLUDED.plan_id,
+    status = EXCLUDED.status,
+    provider_customer_id = EXCLUDED.provider_customer_id,
+    provider_subscription_id = EXCLUDED.provider_subscription_id,
+    current_period_end = EXCLUDED.current_period_end,
+    updated_at = NOW()
+RETURNING id, company_id, plan_id, status, provider_customer_id, provider_subscription_id, current_period_end, created_at, updated_at;
+
+-- name: CountCompanySeatsUsed :one
+-- Pending invitations hold a seat until they are accepted or lapse.
+SELECT
+    (SELECT COUNT(*) FROM company_users cu
+     WHERE cu.company_id = $1 AND cu.deleted_at IS NULL)
+  + (SELECT COUNT(*) FROM invitations i
+     WHERE i.company_id = $1 AND i.status = 'pending' AND i.expires_at > NOW())
+  AS seats_used;
+
+-- Company settings queries
+-- name: GetCompanySettings :one
+SELECT settings FROM company_settings WHERE company_id = $1;
+
+-- name: UpsertCompanySettings :exec
+INSERT INTO company_settings (company_id, settings, updated_by)
+VALUES ($1, $2, $3)
+ON CONFLICT (company_id) DO UPDATE SET
+    settings = EXCLUDED.settings,
+    updated_by = EXCLUDED.updated_by,
+    updated_at = NOW();
+
+-- Company domain queries
+-- name: CreateCompanyDomain :one
+INSERT INTO company_domains (company_id, domain, verification_token, join_role, created_by)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING id, company_id, domain, verification_token, join_role, created_by, created_at, verified_at, deleted_at;
+
+-- name: GetCompanyDomain :one
+SELECT id, company_id, domain, verification_token, join_role, created_by, created_at, verified_at, deleted_at
+FROM company_domains
+WHERE id = $1 AND company_id = $2 AND deleted_at IS NULL;
+
+-- name: ListCompanyDomains :many
+SELECT id, company_id, domain, verification_token, join_role, created_by, created_at, verified_at, deleted_at
+FROM company_domains
+WHERE company_id = $1 AND deleted_at IS NULL
+ORDER BY domain;
+
+-- name: MarkCompanyDomainVerified :one
+UPDATE company_domains SET verified_at = NOW()
+WHERE id = $1 AND deleted_at IS NULL
+RETURNING id, company_id, domain, verification_token, join_role, created_by, created_at, verified_at, deleted_at;
+
+-- name: SoftDeleteCompanyDomain :execrows
+UPDATE company_domains SET deleted_at = NOW()
+WHERE id = $1 AND company_id = $2 AND deleted_at IS NULL;
+
+-- name: GetVerifiedDomainCompanies :many
+-- Active companies whose verified domain matches an email domain.
+SELECT d.company_id, d.join_role
+FROM company_domains d
+JOIN companies c ON c.id = d.company_id
+WHERE LOWER(d.domain) = LOWER($1) AND d.verified_at IS NOT NULL AND d.deleted_at IS NULL
+  AND c.deleted_at IS NULL AND c.archived_at IS NULL;
+
+-- Join request queries
+-- name: CreateJoinRequest :one
+INSERT INTO join_requests (company_id, user_id, message)
+VALUES ($1, $2, $3)
+RETURNING id, company_id, user_id, message, status, reviewed_by, reviewed_at, created_at;
+
+-- name: GetLastJoinRequestRejection :one
+SELECT reviewed_at
+FROM join_requests
+WHERE company_id = $1 AND user_id = $2 AND status = 'rejected'
+ORDER BY reviewed_at DESC
+LIMIT 1;
+
+-- name: ListPendingJoinRequests :many
+SELECT jr.id, jr.company_id, jr.user_id, jr.message, jr.status, jr.created_at, u.name, u.email
+FROM join_requests jr
+JOIN users u ON u.id = jr.user_id
+WHERE jr.company_id = $1 AND jr.status = 'pending'
+ORDER BY jr.created_at, jr.id;
+
+-- name: GetJoinRequestForUpdate :one
+SELECT id, company_id, user_id, message, status, reviewed_by, reviewed_at, created_at
+FROM join_requests
+WHERE id = $1 AND company_id = $2
+FOR UPDATE;
+
+-- name: ReviewJoinRequest :exec
+UPDATE join_requests SET status = $1, reviewed_by = $2, reviewed_at = NOW()
+WHERE id = $3;
+
+-- name: ListCompanyAdmins :many
+SELECT u.id, u.email, u.name
+FROM company_users cu
+JOIN users u ON u.id = cu.user_id
+WHERE cu.company_id = $1 AND cu.role = 'admin' AND cu.deleted_at IS NULL AND u.deleted_at IS NULL;
+
+-- Data export queries
+-- name: CreateDataExport :one
+INSERT INTO data_exports (kind, requested_by, company_id)
+VALUES ($1, $2, $3)
+RETURNING id, kind, requested_by, company_id, status, expires_at, error, created_at, completed_at;
+
+-- name: GetDataExport :one
+SELECT id, kind, requested_by, company_id, status, download_token, expires_at, error, created_at, completed_at
+FROM data_exports
+WHERE id = $1 AND requested_by = $2;
+
+-- name: ClaimDataExport :one
+-- Picks the oldest pending export, or one whose worker died mid-run.
+UPDATE data_exports SET status = 'running', started_at = NOW()
+WHERE id = (
+    SELECT id FROM data_exports
+    WHERE status = 'pending'
+       OR (status = 'running' AND started_at < NOW() - INTERVAL '15 minutes')
+    ORDER BY id
+    FOR UPDATE SKIP LOCKED
+    LIMIT 1
+)
+RETURNING id, kind, requested_by, company_id;
+
+-- name: CompleteDataExport :exec
+UPDATE data_exports SET status = 'ready', archive = $1, download_token = $2, expires_at = $3, completed_at = NOW()
+WHERE id = $4;
+
+-- name: FailDataExport :exec
+UPDATE data_exports SET status = 'failed', error = $1, completed_at = NOW()
+WHERE id = $2;
+
+-- name: GetDataExportArchive :one
+SELECT id, kind, company_id, archive, expires_at
+FROM data_exports
+WHERE download_token = $1 AND status = 'ready' AND expires_at > NOW();
+
+-- name: PurgeExpiredDataExports :exec
+UPDATE data_exports SET archive = NULL, download_token = NULL
+WHERE expires_at <= NOW() AND archive IS NOT NULL;
+
+-- name: GetUserExportProfile :one
+SELECT id, email, name, selected_company_id, created_at, (token IS NOT NULL)::boolean AS has_session
+FROM users
+WHERE id = $1 AND deleted_at IS NULL;
+
+-- name: ListUserInvitations :many
+SELECT i.id, i.company_id, c.company_name, i.role, i.status, i.expires_at, i.responded_at, i.created_at
+FROM invitations i
+JOIN companies c ON c.id = i.company_id
+WHERE LOWER(i.email) = LOWER($1)
+ORDER BY i.created_at;
+
+-- name: ListUserJoinRequests :many
+SELECT jr.id, jr.company_id, c.company_name, jr.message, jr.status, jr.reviewed_at, jr.created_at
+FROM join_requests jr
+JOIN companies c ON c.id = jr.company_id
+WHERE jr.user_id = $1
+ORDER BY jr.created_at;
+
+-- name: ListCompanyJoinRequests :many
+SELECT id, company_id, user_id, message, status, reviewed_by, reviewed_at, created_at
+FROM join_requests
+WHERE company_id = $1
+ORDER BY created_at;
+
+-- Account deletion and retention queries
+-- name: SoftDeleteUser :execrows
+UPDATE users SET deleted_at = NOW(), token = NULL, otp = NULL, otp_expires_at = NULL, selected_company_id = NULL
+WHERE id = $1 AND deleted_at IS NULL;
+
+-- name: SoftDeleteUserMemberships :many
+UPDATE company_users SET deleted_at = NOW(), end_reason = 'account_deleted'
+WHERE user_id = $1 AND deleted_at IS NULL
+RETURNING company_id, role;
+
+-- name: RemoveUserFromAllTeams :exec
+DELETE FROM team_members WHERE user_id = $1;
+
+-- name: DeletePendingJoinRequestsByUser :exec
+DELETE FROM join_requests WHERE user_id = $1 AND status = 'pending';
+
+-- name: AnonymizeDeletedUsers :execrows
+-- Replaces personal data of users deleted before the cutoff, including
+-- copies held in invitations, join requests and export archives.
+WITH targets AS (
+    SELECT du.id, du.email FROM users du
+    WHERE du.deleted_at <= sqlc.arg(cutoff)::timestamp AND du.anonymized_at IS NULL
+    FOR UPDATE
+), scrubbed_invitations AS (
+    UPDATE invitations i SET email = 'deleted-' || t.id || '@deleted.invalid', name = 'Deleted user'
+    FROM targets t
+    WHERE LOWER(i.email) = LOWER(t.email)
+), scrubbed_requests AS (
+    UPDATE join_requests jr SET message = ''
+    FROM targets t
+    WHERE jr.user_id = t.id
+), scrubbed_exports AS (
+    UPDATE data_exports de SET archive = NULL, download_token = NULL
+    FROM targets t
+    WHERE de.requested_by = t.id
+)
+UPDATE users u SET
+    email = 'deleted-' || t.id || '@deleted.invalid',
+    name = 'Deleted user',
+    anonymized_at = NOW()
+FROM targets t
+WHERE u.id = t.id;
+
+-- name: PurgeDeletedCompanies :execrows
+DELETE FROM companies WHERE deleted_at <= sqlc.arg(cutoff)::timestamp;
+
+-- name: PurgeDeletedMemberships :execrows
+DELETE FROM company_users WHERE deleted_at <= sqlc.arg(cutoff)::timestamp;
+
+-- name: PurgeDeletedUsers :execrows
+-- Users still recorded as owner of a company wait until that company is
+-- purged.
+DELETE FROM users u
+WHERE u.deleted_at <= sqlc.arg(cutoff)::timestamp
+  AND NOT EXISTS (SELECT 1 FROM companies c WHERE c.owner_id = u.id);
+
+-- Restore queries
+-- name: ListRemovedCompanyMembers :many
+-- Members who left on their own are not listed; they rejoin by invitation.
+SELECT u.id, u.name, u.email, cu.role, cu.deleted_at AS removed_at
+FROM company_users cu
+JOIN users u ON u.id = cu.user_id
+WHERE cu.company_id = $1 AND cu.deleted_at > sqlc.arg(since)::timestamp AND cu.end_reason = 'removed'
+  AND u.deleted_at IS NULL
+ORDER BY cu.deleted_at DESC;
+
+-- name: RestoreCompanyMember :one
+-- Keeps the original role and join date.
+UPDATE company_users cu SET deleted_at = NULL, end_reason = NULL
+WHERE cu.company_id = $1 AND cu.user_id = $2 AND cu.deleted_at > sqlc.arg(since)::timestamp
+  AND cu.end_reason = 'removed'
+  AND EXISTS (SELECT 1 FROM users u WHERE u.id = cu.user_id AND u.deleted_at IS NULL)
+RETURNING cu.role;
+
+-- name: ListDeletedOwnedCompanies :many
+SELECT id, company_name, created_at, deleted_at
+FROM companies
+WHERE owner_id = $1 AND deleted_at > sqlc.arg(since)::timestamp
+ORDER BY deleted_at DESC;
+
+-- name: RestoreCompany :execrows
+UPDATE companies SET deleted_at = NULL, updated_at = NOW()
+WHERE id = $1 AND owner_id = $2 AND deleted_at > sqlc.arg(since)::timestamp;
+
+-- Webhook queries
+-- name: CreateWebhookEndpoint :one
+INSERT INTO webhook_endpoints (company_id, url, secret, event_types, created_by)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING *;
+
+-- name: ListWebhookEndpoints :many
+SELECT * FROM webhook_endpoints WHERE company_id = $1 ORDER BY id;
+
+-- name: GetWebhookEndpoint :one
+SELECT * FROM webhook_endpoints WHERE id = $1 AND company_id = $2;
+
+-- name: UpdateWebhookEndpoint :one
+UPDATE webhook_endpoints SET url = $3, event_types = $4, enabled = $5, updated_at = NOW()
+WHERE id = $1 AND company_id = $2
+RETURNING *;
+
+-- name: DeleteWebhookEndpoint :execrows
+DELETE FROM webhook_endpoints WHERE id = $1 AND company_id = $2;
+
+-- name: FailPendingWebhookDeliveries :exec
+UPDATE webhook_deliveries SET status = 'failed', last_error = sqlc.arg(reason)::text, completed_at = NOW()
+WHERE endpoint_id = $1 AND status = 'pending';
+
+-- name: EnqueueWebhookEvent :execrows
+-- Queues one delivery per enabled endpoint of the company subscribed to the
+-- event type.
+INSERT INTO webhook_deliveries (endpoint_id, event_id, event_type, payload)
+SELECT e.id, sqlc.arg(event_id)::text, sqlc.arg(event_type)::text, sqlc.arg(payload)::jsonb
+FROM webhook_endpoints e
+WHERE e.company_id = sqlc.arg(company_id) AND e.enabled
+  AND (cardinality(e.event_types) = 0 OR sqlc.arg(event_type)::text = ANY(e.event_types));
+
+-- name: CreateWebhookDelivery :one
+-- Inserts a delivery the caller attempts itself, leased like a claimed one
+-- so the delivery worker does not pick it up at the same time.
+INSERT INTO webhook_deliveries (endpoint_id, event_id, event_type, payload, next_attempt_at)
+VALUES ($1, $2, $3, $4, NOW() + INTERVAL '5 minutes')
+RETURNING *;
+
+-- name: ClaimWebhookDeliveries :many
+-- Leases due deliveries by pushing their next attempt out, so a worker that
+-- dies mid-request does not hold them for longer than the lease.
+UPDATE webhook_deliveries d SET next_attempt_at = NOW() + INTERVAL '5 minutes'
+FROM webhook_endpoints e
+WHERE e.id = d.endpoint_id AND d.id IN (
+    SELECT id FROM webhook_deliveries
+    WHERE status = 'pending' AND next_attempt_at <= NOW()
+    ORDER BY next_attempt_at
+    FOR UPDATE SKIP LOCKED
+    LIMIT sqlc.arg(batch_size)
+)
+RETURNING d.id, d.event_id, d.event_type, d.payload, d.attempts, e.url, e.secret;
+
+-- name: RecordWebhookAttempt :one
+UPDATE webhook_deliveries SET
+    status = sqlc.arg(status),
+    attempts = attempts + 1,
+    last_attempt_at = NOW(),
+    response_status = sqlc.narg(response_status),
+    last_error = sqlc.narg(last_error),
+    next_attempt_at = NOW() + sqlc.arg(retry_after)::interval,
+    completed_at = CASE WHEN sqlc.arg(status) = 'pending' THEN NULL ELSE NOW() END
+WHERE id = sqlc.arg(id)
+RETURNING *;
+
+-- name: PurgeWebhookDeliveries :exec
+DELETE FROM webhook_deliveries
+WHERE status <> 'pending' AND completed_at < NOW() - sqlc.arg(max_age)::interval;
+
+-- name: ListWebhookDeliveries :many
+SELECT * FROM webhook_deliveries
+WHERE endpoint_id = $1
+ORDER BY id DESC
+LIMIT $2;
+
+-- Outbox queries
+-- name: InsertOutboxEvent :exec
+INSERT INTO outbox_events (event_type, payload, ip_address, user_agent) VALUES ($1, $2, $3, $4);
+
+-- name: ClaimOutboxEvents :many
+-- Leases due events like ClaimWebhookDeliveries does.
+UPDATE outbox_events SET next_attempt_at = NOW() + INTERVAL '5 minutes'
+WHERE id IN (
+    SELECT id FROM outbox_events
+    WHERE status = 'pending' AND next_attempt_at <= NOW()
+    ORDER BY id
+    FOR UPDATE SKIP LOCKED
+    LIMIT sqlc.arg(batch_size)
+)
+RETURNING id, event_type, payload, handled_by, attempts, ip_address, user_agent, created_at;
+
+-- name: CompleteOutboxEvent :exec
+UPDATE outbox_events SET status = 'dispatched', handled_by = $2, last_error = NULL, dispatched_at = NOW()
+WHERE id = $1;
+
+-- name: RetryOutboxEvent :exec
+UPDATE outbox_events SET
+    status = sqlc.arg(status),
+    handled_by = sqlc.arg(handled_by),
+    attempts = attempts + 1,
+    last_error = sqlc.arg(last_error),
+    next_attempt_at = NOW() + sqlc.arg(retry_after)::interval
+WHERE id = sqlc.arg(id);
+
+-- name: PurgeDispatchedOutboxEvents :exec
+DELETE FROM outbox_events
+WHERE status = 'dispatched' AND dispatched_at < NOW() - sqlc.arg(max_age)::interval;
+
+-- Audit queries
+-- name: InsertAuditEvent :exec
+-- Does nothing when the source event was already logged. Inserts hold a
+-- lock until they commit, so IDs become visible in order and sinks reading
+-- by ID never pass over a row that is still being committed.
+WITH ordered AS (
+    SELECT pg_advisory_xact_lock(hashtextextended('audit_events_insert', 0))
+)
+INSERT INTO audit_events (action, actor_id, target_user_id, company_id, ip_address, user_agent, metadata, source_event_id, created_at)
+SELECT $1, $2, $3, $4, $5, $6, $7, $8, $9 FROM ordered
+ON CONFLICT (source_event_id) WHERE source_event_id IS NOT NULL DO NOTHING;
+
+-- name: ListAuditEvents :many
+-- Company events plus account events (logins, account deletion) of the
+-- company's current members since they joined, newest first.
+SELECT a.id, a.action, a.actor_id, actor.email AS actor_email, a.target_user_id, target.email AS target_email,
+       a.company_id, a.ip_address, a.user_agent, a.metadata, a.created_at
+FROM audit_events a
+LEFT JOIN users actor ON actor.id = a.actor_id
+LEFT JOIN users target ON target.id = a.target_user_id
+WHERE (a.company_id = sqlc.arg(company_id)::integer OR (a.company_id IS NULL AND EXISTS (
+        SELECT 1 FROM company_users cu
+        WHERE cu.company_id = sqlc.arg(company_id)::integer AND cu.deleted_at IS NULL
+          AND cu.user_id IN (a.actor_id, a.target_user_id)
+          AND a.created_at >= cu.created_at
+    )))
+  AND (sqlc.narg(since)::timestamp IS NULL OR a.created_at >= sqlc.narg(since)::timestamp)
+  AND (sqlc.narg(until)::timestamp IS NULL OR a.created_at < sqlc.narg(until)::timestamp)
+  AND (cardinality(sqlc.arg(actions)::text[]) = 0 OR a.action = ANY(sqlc.arg(actions)::text[]))
+  AND (sqlc.narg(before_id)::bigint IS NULL OR a.id < sqlc.narg(before_id)::bigint)
+ORDER BY a.id DESC
+LIMIT sqlc.arg(page_limit);
+
+-- name: ListUserAuditEvents :many
+SELECT id, action, actor_id, target_user_id, company_id, ip_address, user_agent, metadata, created_at
+FROM audit_events
+WHERE actor_id = $1 OR target_user_id = $1
+ORDER BY id;
+
+-- name: PurgeAuditEvents :execrows
+DELETE FROM audit_events WHERE created_at <= sqlc.arg(cutoff)::timestamp;
+
+-- Audit sink queries
+-- name: CreateAuditSink :one
+-- Without backfill the sink starts after the newest existing event.
+INSERT INTO audit_sinks (company_id, kind, destination, protocol, secret, created_by, checkpoint_id)
+VALUES ($1, $2, $3, $4, $5, $6,
+    CASE WHEN sqlc.arg(backfill)::boolean THEN 0 ELSE (SELECT COALESCE(MAX(id), 0) FROM audit_events) END)
+RETURNING *;
+
+-- name: ListAuditSinks :many
+SELECT * FROM audit_sinks WHERE company_id = $1 ORDER BY id;
+
+-- name: GetAuditSink :one
+SELECT * FROM audit_sinks WHERE id = $1 AND company_id = $2;
+
+-- name: UpdateAuditSink :one
+UPDATE audit_sinks SET destination = $3, protocol = $4, enabled = $5, last_error = NULL, next_run_at = NOW(), updated_at = NOW()
+WHERE id = $1 AND company_id = $2
+RETURNING *;
+
+-- name: DeleteAuditSink :execrows
+DELETE FROM audit_sinks WHERE id = $1 AND company_id = $2;
+
+-- name: ClaimAuditSink :one
+-- Leases a due sink by pushing its next run out, like ClaimWebhookDeliveries.
+UPDATE audit_sinks SET next_run_at = NOW() + INTERVAL '2 minutes'
+WHERE id = (
+    SELECT id FROM audit_sinks
+    WHERE enabled AND next_run_at <= NOW()
+    ORDER BY next_run_at
+    FOR UPDATE SKIP LOCKED
+    LIMIT 1
+)
+RETURNING *;
+
+-- name: AdvanceAuditSinkCheckpoint :exec
+UPDATE audit_sinks SET checkpoint_id = $2, last_exported_at = NOW()
+WHERE id = $1 AND checkpoint_id < $2;
+
+-- name: FinishAuditSinkRun :exec
+UPDATE audit_sinks SET last_error = sqlc.narg(last_error), next_run_at = NOW() + sqlc.arg(run_after)::interval
+WHERE id = sqlc.arg(id);
+
+-- name: ListAuditEventsAfter :many
+-- Same scope as ListAuditEvents, oldest first. InsertAuditEvent commits IDs
+-- in order, so a checkpoint never skips a later-committing row.
+SELECT a.id, a.action, a.actor_id, actor.email AS actor_email, a.target_user_id, target.email AS target_email,
+       a.company_id, a.ip_address, a.user_agent, a.metadata, a.created_at
+FROM audit_events a
+LEFT JOIN users actor ON actor.id = a.actor_id
+LEFT JOIN users target ON target.id = a.target_user_id
+WHERE (a.company_id = sqlc.arg(company_id)::integer OR (a.company_id IS NULL AND EXISTS (
+        SELECT 1 FROM company_users cu
+        WHERE cu.company_id = sqlc.arg(company_id)::integer AND cu.deleted_at IS NULL
+          AND cu.user_id IN (a.actor_id, a.target_user_id)
+          AND a.created_at >= cu.created_at
+    )))
+  AND a.id > sqlc.arg(after_id)::bigint
+ORDER BY a.id
+LIMIT sqlc.arg(page_limit);
+
+-- Notification queries
+-- name: CreateNotification :one
+-- Returns no row when the notification was already created from this event.
+INSERT INTO notifications (user_id, type, company_id, source_event_id, title, body, data, in_app)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+ON CONFLICT (user_id, type, source_event_id) DO NOTHING
+RETURNING id;
+
+-- name: MarkNotificationEmailed :exec
+UPDATE notifications SET emailed_at = NOW() WHERE id = $1;
+
+-- name: ListNotifications :many
+SELECT id, type, company_id, title, body, data, read_at, created_at
+FROM notifications
+WHERE user_id = sqlc.arg(user_id) AND in_app
+  AND (NOT sqlc.arg(unread_only)::boolean OR read_at IS NULL)
+  AND (sqlc.narg(before_id)::bigint IS NULL OR id < sqlc.narg(before_id)::bigint)
+ORDER BY id DESC
+LIMIT sqlc.arg(page_limit);
+
+-- name: CountUnreadNotifications :one
+SELECT COUNT(*) FROM notifications WHERE user_id = $1 AND in_app AND read_at IS NULL;
+
+-- name: MarkNotificationsRead :execrows
+UPDATE notifications SET read_at = NOW()
+WHERE user_id = sqlc.arg(user_id) AND in_app AND read_at IS NULL AND id = ANY(sqlc.arg(ids)::bigint[]);
+
+-- name: MarkAllNotificationsRead :execrows
+UPDATE notifications SET read_at = NOW()
+WHERE user_id = $1 AND in_app AND read_at IS NULL;
+
+-- name: DeleteUserNotifications :exec
+DELETE FROM notifications WHERE user_id = $1;
+
+-- name: PurgeNotifications :execrows
+DELETE FROM notifications WHERE created_at < sqlc.arg(cutoff)::timestamp;
+
+-- name: ListNotificationPreferences :many
+SELECT type, in_app, email FROM notification_preferences WHERE user_id = $1;
+
+-- name: UpsertNotificationPreference :exec
+INSERT INTO notification_preferences (user_id, type, in_app, email)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (user_id, type) DO UPDATE SET in_app = EXCLUDED.in_app, email = EXCLUDED.email, updated_at = NOW();
+
+-- Realtime queries
+-- name: NotifyRealtimeEvent :exec
+SELECT pg_notify('realtime_events', sqlc.arg(payload)::text);
+
+-- name: ListCompanyMemberIDs :many
+SELECT user_id FROM company_users WHERE company_id = $1 AND deleted_at IS NULL;
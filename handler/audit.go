@@ -0,0 +1,84 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"project/compiled"
+	"project/service"
+)
+
+func (h *Handler) ListAuditEvents(ctx context.Context, req *compiled.ListAuditEventsRequest) (*compiled.ListAuditEventsResponse, error) {
+	user, ok := UserFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "unauthorized")
+	}
+	scope, ok := CompanyFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.FailedPrecondition, "no company selected")
+	}
+
+	opts := service.AuditListOptions{
+		PageSize:  req.PageSize,
+		PageToken: req.PageToken,
+		Actions:   req.Actions,
+	}
+	var err error
+	if req.StartTime != "" {
+		if opts.Since, err = time.Parse(time.RFC3339, req.StartTime); err != nil {
+			return nil, status.Error(codes.InvalidArgument, "start_time must be an RFC 3339 timestamp")
+		}
+	}
+	if req.EndTime != "" {
+		if opts.Until, err = time.Parse(time.RFC3339, req.EndTime); err != nil {
+			return nil, status.Error(codes.InvalidArgument, "end_time must be an RFC 3339 timestamp")
+		}
+	}
+
+	page, err := h.companyService.ListAuditEvents(ctx, user.ID, scope.ID, opts)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrNotAdmin):
+			return nil, status.Error(codes.PermissionDenied, "only admins can view the audit log")
+		case errors.Is(err, service.ErrInvalidAuditAction),
+			errors.Is(err, service.ErrInvalidTimeRange),
+			errors.Is(err, service.ErrInvalidPageToken),
+			errors.Is(err, service.ErrInvalidPageSize):
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		default:
+			return nil, internalError(err, "failed to list audit events")
+		}
+	}
+
+	events := make([]*compiled.AuditEventInfo, 0, len(page.Events))
+	for _, e := range page.Events {
+		metadata := &structpb.Struct{}
+		if err := metadata.UnmarshalJSON(e.Metadata); err != nil {
+			return nil, internalError(err, "failed to list audit events")
+		}
+
+		events = append(events, &compiled.AuditEventInfo{
+			Id:           e.ID,
+			Action:       e.Action,
+			ActorId:      int64(e.ActorID.Int32),
+			ActorEmail:   e.ActorEmail.String,
+			TargetUserId: int64(e.TargetUserID.Int32),
+			TargetEmail:  e.TargetEmail.String,
+			CompanyId:    int64(e.CompanyID.Int32),
+			IpAddress:    e.IpAddress.String,
+			UserAgent:    e.UserAgent.String,
+			Metadata:     metadata,
+			CreatedAt:    e.CreatedAt.Time.Format("2006-01-02T15:04:05Z"),
+		})
+	}
+
+	return &compiled.ListAuditEventsResponse{
+		Events:        events,
+		NextPageToken: page.NextPageToken,
+	}, nil
+}
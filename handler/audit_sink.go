@@ -0,0 +1,141 @@
+package handler
+
+import (
+	"context"
+	"errors"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"project/compiled"
+	"project/service"
+)
+
+func (h *Handler) CreateAuditSink(ctx context.Context, req *compiled.CreateAuditSinkRequest) (*compiled.CreateAuditSinkResponse, error) {
+	user, ok := UserFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "unauthorized")
+	}
+	scope, ok := CompanyFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.FailedPrecondition, "no company selected")
+	}
+	if req.Kind == "" || req.Destination == "" {
+		return nil, status.Error(codes.InvalidArgument, "kind and destination are required")
+	}
+
+	sink, err := h.auditExportService.CreateAuditSink(ctx, user.ID, scope.ID, req.Kind, req.Destination, req.Protocol, req.Backfill)
+	if err != nil {
+		return nil, auditSinkError(err, "failed to create audit sink")
+	}
+
+	return &compiled.CreateAuditSinkResponse{
+		Sink:   auditSinkToProto(sink),
+		Secret: sink.Secret,
+	}, nil
+}
+
+func (h *Handler) ListAuditSinks(ctx context.Context, req *compiled.ListAuditSinksRequest) (*compiled.ListAuditSinksResponse, error) {
+	user, ok := UserFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "unauthorized")
+	}
+	scope, ok := CompanyFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.FailedPrecondition, "no company selected")
+	}
+
+	sinks, err := h.auditExportService.ListAuditSinks(ctx, user.ID, scope.ID)
+	if err != nil {
+		return nil, auditSinkError(err, "failed to list audit sinks")
+	}
+
+	result := make([]*compiled.AuditSinkInfo, 0, len(sinks))
+	for i := range sinks {
+		result = append(result, auditSinkToProto(&sinks[i]))
+	}
+
+	return &compiled.ListAuditSinksResponse{Sinks: result}, nil
+}
+
+func (h *Handler) UpdateAuditSink(ctx context.Context, req *compiled.UpdateAuditSinkRequest) (*compiled.UpdateAuditSinkResponse, error) {
+	user, ok := UserFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "unauthorized")
+	}
+	scope, ok := CompanyFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.FailedPrecondition, "no company selected")
+	}
+	if req.SinkId == 0 {
+		return nil, status.Error(codes.InvalidArgument, "sink_id is required")
+	}
+	if req.Destination == "" {
+		return nil, status.Error(codes.InvalidArgument, "destination is required")
+	}
+
+	sink, err := h.auditExportService.UpdateAuditSink(ctx, user.ID, scope.ID, int32(req.SinkId), req.Destination, req.Protocol, req.Enabled)
+	if err != nil {
+		return nil, auditSinkError(err, "failed to update audit sink")
+	}
+
+	return &compiled.UpdateAuditSinkResponse{Sink: auditSinkToProto(sink)}, nil
+}
+
+func (h *Handler) DeleteAuditSink(ctx context.Context, req *compiled.DeleteAuditSinkRequest) (*compiled.DeleteAuditSinkResponse, error) {
+	user, ok := UserFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "unauthorized")
+	}
+	scope, ok := CompanyFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.FailedPrecondition, "no company selected")
+	}
+	if req.SinkId == 0 {
+		return nil, status.Error(codes.InvalidArgument, "sink_id is required")
+	}
+
+	if err := h.auditExportService.DeleteAuditSink(ctx, user.ID, scope.ID, int32(req.SinkId)); err != nil {
+		return nil, auditSinkError(err, "failed to delete audit sink")
+	}
+
+	return &compiled.DeleteAuditSinkResponse{Success: true}, nil
+}
+
+func auditSinkError(err error, internalMsg string) error {
+	switch {
+	case errors.Is(err, service.ErrNotAdmin):
+		return status.Error(codes.PermissionDenied, "only admins can manage audit sinks")
+	case errors.Is(err, service.ErrAuditSinkNotFound):
+		return status.Error(codes.NotFound, "audit sink not found")
+	case errors.Is(err, service.ErrAuditSinkKindInvalid),
+		errors.Is(err, service.ErrAuditSinkDestinationInvalid),
+		errors.Is(err, service.ErrAuditSinkProtocolInvalid),
+		errors.Is(err, service.ErrAuditSinkNotPublic):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, service.ErrFileAuditSinksDisabled):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, service.ErrCompanyArchived):
+		return status.Error(codes.FailedPrecondition, "company is archived")
+	default:
+		return internalError(err, internalMsg)
+	}
+}
+
+func auditSinkToProto(sink *compiled.AuditSink) *compiled.AuditSinkInfo {
+	info := &compiled.AuditSinkInfo{
+		Id:           int64(sink.ID),
+		Kind:         sink.Kind,
+		Destination:  sink.Destination,
+		Protocol:     sink.Protocol.String,
+		Enabled:      sink.Enabled,
+		CheckpointId: sink.CheckpointID,
+		LastError:    sink.LastError.String,
+		CreatedAt:    sink.CreatedAt.Time.Format("2006-01-02T15:04:05Z"),
+		UpdatedAt:    sink.UpdatedAt.Time.Format("2006-01-02T15:04:05Z"),
+	}
+	if sink.LastExportedAt.Valid {
+		info.LastExportedAt = sink.LastExportedAt.Time.Format("2006-01-02T15:04:05Z")
+	}
+	return info
+}
@@ -2,6 +2,7 @@ package handler
 
 import (
 	"context"
+	"log/slog"
 
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
@@ -12,7 +13,7 @@ import (
 
 func (h *Handler) RequestLoginOTP(ctx context.Context, req *compiled.RequestLoginOTPRequest) (*compiled.RequestLoginOTPResponse, error) {
 	if err := h.authService.RequestOTP(ctx, req.Email); err != nil {
-		return nil, status.Error(codes.Internal, "internal error")
+		return nil, internalError(err, "internal error")
 	}
 	return &compiled.RequestLoginOTPResponse{Success: true}, nil
 }
@@ -26,7 +27,7 @@ func (h *Handler) Login(ctx context.Context, req *compiled.LoginRequest) (*compi
 		case service.ErrUserNotFound:
 			return nil, status.Error(codes.NotFound, "user not found")
 		default:
-			return nil, status.Error(codes.Internal, "internal error")
+			return nil, internalError(err, "internal error")
 		}
 	}
 
@@ -38,6 +39,18 @@ func (h *Handler) Login(ctx context.Context, req *compiled.LoginRequest) (*compi
 		if row.SelectedCompanyID.Valid {
 			selectedCompanyID = row.SelectedCompanyID.Int32
 		}
+
+		// Join companies that verified the user's email domain. Login
+		// still succeeds if this fails.
+		joined, err := h.companyService.JoinVerifiedDomainCompanies(ctx, row.ID, row.Email)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to join verified domain companies", "user_id", row.ID, "error", err)
+		}
+		if selectedCompanyID == 0 && len(joined) > 0 {
+			if _, err := h.companyService.SelectCompany(ctx, row.ID, joined[0]); err == nil {
+				selectedCompanyID = joined[0]
+			}
+		}
 		h.cacheSetToken(token, &AuthenticatedUser{
 			ID:                row.ID,
 			Email:             row.Email,
@@ -3,6 +3,7 @@ package handler
 import (
 	"context"
 	"errors"
+	"strings"
 
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
@@ -23,7 +24,7 @@ func (h *Handler) CreateCompany(ctx context.Context, req *compiled.CreateCompany
 
 	company, err := h.companyService.CreateCompany(ctx, user.ID, req.CompanyName)
 	if err != nil {
-		return nil, status.Error(codes.Internal, "failed to create company")
+		return nil, internalError(err, "failed to create company")
 	}
 
 	user.SelectedCompanyID = company.ID
@@ -51,7 +52,10 @@ func (h *Handler) SelectCompany(ctx context.Context, req *compiled.SelectCompany
 		if errors.Is(err, service.ErrNotCompanyMember) {
 			return nil, status.Error(codes.PermissionDenied, "user is not a member of this company")
 		}
-		return nil, status.Error(codes.Internal, "failed to select company")
+		if errors.Is(err, service.ErrCompanyNotFound) {
+			return nil, status.Error(codes.NotFound, "company not found")
+		}
+		return nil, internalError(err, "failed to select company")
 	}
 
 	user.SelectedCompanyID = company.ID
@@ -68,17 +72,107 @@ func (h *Handler) SelectCompany(ctx context.Context, req *compiled.SelectCompany
 			Role:      role,
 			IsOwner:   isOwner,
 			CreatedAt: company.CreatedAt.Time.Format("2006-01-02T15:04:05Z"),
+			Archived:  company.ArchivedAt.Valid,
 		},
 	}, nil
 }
 
+func (h *Handler) UpdateCompany(ctx context.Context, req *compiled.UpdateCompanyRequest) (*compiled.UpdateCompanyResponse, error) {
+	user, ok := UserFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "unauthorized")
+	}
+	scope, ok := CompanyFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.FailedPrecondition, "no company selected")
+	}
+	if req.CompanyName != nil && *req.CompanyName == "" {
+		return nil, status.Error(codes.InvalidArgument, "company_name must not be empty")
+	}
+
+	company, err := h.companyService.UpdateCompany(ctx, user.ID, scope.ID, service.CompanyUpdate{
+		CompanyName: req.CompanyName,
+		Description: req.Description,
+		Website:     req.Website,
+	})
+	if err != nil {
+		return nil, companyError(err, "failed to update company")
+	}
+
+	return &compiled.UpdateCompanyResponse{
+		Id:          int64(company.ID),
+		CompanyName: company.CompanyName,
+		Description: company.Description,
+		Website:     company.Website,
+		CreatedAt:   company.CreatedAt.Time.Format("2006-01-02T15:04:05Z"),
+	}, nil
+}
+
+func (h *Handler) ArchiveCompany(ctx context.Context, req *compiled.ArchiveCompanyRequest) (*compiled.ArchiveCompanyResponse, error) {
+	user, ok := UserFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "unauthorized")
+	}
+	scope, ok := CompanyFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.FailedPrecondition, "no company selected")
+	}
+
+	if err := h.companyService.SetCompanyArchived(ctx, user.ID, scope.ID, true); err != nil {
+		return nil, companyError(err, "failed to archive company")
+	}
+
+	return &compiled.ArchiveCompanyResponse{Success: true}, nil
+}
+
+func (h *Handler) UnarchiveCompany(ctx context.Context, req *compiled.UnarchiveCompanyRequest) (*compiled.UnarchiveCompanyResponse, error) {
+	user, ok := UserFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "unauthorized")
+	}
+	scope, ok := CompanyFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.FailedPrecondition, "no company selected")
+	}
+
+	if err := h.companyService.SetCompanyArchived(ctx, user.ID, scope.ID, false); err != nil {
+		return nil, companyError(err, "failed to unarchive company")
+	}
+
+	return &compiled.UnarchiveCompanyResponse{Success: true}, nil
+}
+
+func (h *Handler) DeleteCompany(ctx context.Context, req *compiled.DeleteCompanyRequest) (*compiled.DeleteCompanyResponse, error) {
+	user, ok := UserFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "unauthorized")
+	}
+	if req.CompanyId == 0 {
+		return nil, status.Error(codes.InvalidArgument, "company_id is required")
+	}
+
+	affectedUserIDs, err := h.companyService.DeleteCompany(ctx, user.ID, int32(req.CompanyId))
+	if err != nil {
+		return nil, companyError(err, "failed to delete company")
+	}
+
+	// Cached sessions still point at the deleted company; drop them so the
+	// next request reloads the cleared selection from the database.
+	for _, id := range affectedUserIDs {
+		h.cacheDeleteByUserID(id)
+	}
+
+	return &compiled.DeleteCompanyResponse{Success: true}, nil
+}
+
 func (h *Handler) InviteUser(ctx context.Context, req *compiled.InviteUserRequest) (*compiled.InviteUserResponse, error) {
 	user, ok := UserFromContext(ctx)
 	if !ok {
 		return nil, status.Error(codes.Unauthenticated, "unauthorized")
 	}
 
-	if user.SelectedCompanyID == 0 {
+	scope, ok := CompanyFromContext(ctx)
+	if !ok {
 		return nil, status.Error(codes.FailedPrecondition, "no company selected")
 	}
 
@@ -88,54 +182,151 @@ func (h *Handler) InviteUser(ctx context.Context, req *compiled.InviteUserReques
 	if req.Name == "" {
 		return nil, status.Error(codes.InvalidArgument, "name is required")
 	}
-	if req.Role != "admin" && req.Role != "member" {
+	if req.Role != "" && req.Role != "admin" && req.Role != "member" {
 		return nil, status.Error(codes.InvalidArgument, "role must be 'admin' or 'member'")
 	}
 
-	invitedUser, err := h.companyService.InviteUser(ctx, user.ID, user.SelectedCompanyID, req.Email, req.Name, req.Role)
+	invitation, err := h.companyService.InviteUser(ctx, user.ID, scope.ID, req.Email, req.Name, req.Role)
 	if err != nil {
 		if errors.Is(err, service.ErrNotAdmin) {
-			return nil, status.Error(codes.PermissionDenied, "only admins can invite users")
+			return nil, status.Error(codes.PermissionDenied, "only admins and inviter teams can invite users")
 		}
 		if errors.Is(err, service.ErrUserAlreadyMember) {
 			return nil, status.Error(codes.AlreadyExists, "user is already a member of this company")
 		}
-		return nil, status.Error(codes.Internal, "failed to invite user")
+		if errors.Is(err, service.ErrInvitationPending) {
+			return nil, status.Error(codes.AlreadyExists, "user already has a pending invitation")
+		}
+		if errors.Is(err, service.ErrEmailDomainBlocked) {
+			return nil, status.Error(codes.PermissionDenied, "email domain is not allowed by the company settings")
+		}
+		if errors.Is(err, service.ErrSeatLimitReached) {
+			return nil, status.Error(codes.FailedPrecondition, "the company has no seats left on its plan")
+		}
+		if errors.Is(err, service.ErrCompanyArchived) {
+			return nil, status.Error(codes.FailedPrecondition, "company is archived")
+		}
+		return nil, internalError(err, "failed to invite user")
 	}
 
 	return &compiled.InviteUserResponse{
-		UserId: int64(invitedUser.ID),
-		Email:  invitedUser.Email,
-		Name:   invitedUser.Name,
-		Role:   req.Role,
+		Email:        invitation.Email,
+		Name:         invitation.Name,
+		Role:         invitation.Role,
+		InvitationId: int64(invitation.ID),
+		ExpiresAt:    invitation.ExpiresAt.Time.Format("2006-01-02T15:04:05Z"),
 	}, nil
 }
 
-func (h *Handler) ListCompanyMembers(ctx context.Context, req *compiled.ListCompanyMembersRequest) (*compiled.ListCompanyMembersResponse, error) {
+func (h *Handler) BulkInviteUsers(ctx context.Context, req *compiled.BulkInviteUsersRequest) (*compiled.BulkInviteUsersResponse, error) {
 	user, ok := UserFromContext(ctx)
 	if !ok {
 		return nil, status.Error(codes.Unauthenticated, "unauthorized")
 	}
-	if user.SelectedCompanyID == 0 {
+	scope, ok := CompanyFromContext(ctx)
+	if !ok {
 		return nil, status.Error(codes.FailedPrecondition, "no company selected")
 	}
 
-	members, err := h.companyService.GetCompanyMembers(ctx, user.SelectedCompanyID)
+	rows, err := service.ParseBulkInviteCSV(strings.NewReader(req.Csv))
 	if err != nil {
-		return nil, status.Error(codes.Internal, "failed to get company members")
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	results, err := h.companyService.BulkInviteUsers(ctx, user.ID, scope.ID, rows)
+	if err != nil {
+		if errors.Is(err, service.ErrNotAdmin) {
+			return nil, status.Error(codes.PermissionDenied, "only admins can invite users")
+		}
+		if errors.Is(err, service.ErrCompanyArchived) {
+			return nil, status.Error(codes.FailedPrecondition, "company is archived")
+		}
+		if errors.Is(err, service.ErrFeatureNotInPlan) {
+			return nil, status.Error(codes.FailedPrecondition, err.Error())
+		}
+		return nil, internalError(err, "failed to invite users")
+	}
+
+	resp := &compiled.BulkInviteUsersResponse{
+		Results: make([]*compiled.BulkInviteResult, 0, len(results)),
+	}
+	for _, r := range results {
+		result := &compiled.BulkInviteResult{
+			Line:         int32(r.Line),
+			Email:        r.Email,
+			Success:      r.Err == nil,
+			InvitationId: int64(r.InvitationID),
+		}
+		if r.Err != nil {
+			result.Error = r.Err.Error()
+			resp.FailedCount++
+		} else {
+			resp.InvitedCount++
+		}
+		resp.Results = append(resp.Results, result)
+	}
+
+	return resp, nil
+}
+
+func (h *Handler) ListCompanyMembers(ctx context.Context, req *compiled.ListCompanyMembersRequest) (*compiled.ListCompanyMembersResponse, error) {
+	if _, ok := UserFromContext(ctx); !ok {
+		return nil, status.Error(codes.Unauthenticated, "unauthorized")
+	}
+	scope, ok := CompanyFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.FailedPrecondition, "no company selected")
+	}
+
+	if req.Role != "" && req.Role != "admin" && req.Role != "member" {
+		return nil, status.Error(codes.InvalidArgument, "role must be 'admin' or 'member'")
+	}
+
+	page, err := h.companyService.ListCompanyMembers(ctx, scope.ID, service.MemberListOptions{
+		PageSize:  req.PageSize,
+		PageToken: req.PageToken,
+		OrderBy:   req.OrderBy,
+		Role:      req.Role,
+		Query:     req.Query,
+	})
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidOrderBy) || errors.Is(err, service.ErrInvalidPageToken) || errors.Is(err, service.ErrInvalidPageSize) {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		return nil, internalError(err, "failed to get company members")
+	}
+
+	userIDs := make([]int32, 0, len(page.Members))
+	for _, m := range page.Members {
+		userIDs = append(userIDs, m.ID)
+	}
+	teams, err := h.companyService.GetTeamMembershipsForUsers(ctx, scope.ID, userIDs)
+	if err != nil {
+		return nil, internalError(err, "failed to get company members")
 	}
 
 	var result []*compiled.CompanyMember
-	for _, m := range members {
-		result = append(result, &compiled.CompanyMember{
-			UserId: int64(m.ID),
-			Name:   m.Name,
-			Email:  m.Email,
-			Role:   m.Role,
-		})
+	for _, m := range page.Members {
+		member := &compiled.CompanyMember{
+			UserId:   int64(m.ID),
+			Name:     m.Name,
+			Email:    m.Email,
+			Role:     m.Role,
+			JoinedAt: m.JoinedAt.Time.Format("2006-01-02T15:04:05Z"),
+		}
+		for _, t := range teams[m.ID] {
+			member.Teams = append(member.Teams, &compiled.TeamRef{
+				Id:   int64(t.TeamID),
+				Name: t.TeamName,
+			})
+		}
+		result = append(result, member)
 	}
 
-	return &compiled.ListCompanyMembersResponse{Members: result}, nil
+	return &compiled.ListCompanyMembersResponse{
+		Members:       result,
+		NextPageToken: page.NextPageToken,
+	}, nil
 }
 
 func (h *Handler) RemoveCompanyMember(ctx context.Context, req *compiled.RemoveCompanyMemberRequest) (*compiled.RemoveCompanyMemberResponse, error) {
@@ -143,14 +334,15 @@ func (h *Handler) RemoveCompanyMember(ctx context.Context, req *compiled.RemoveC
 	if !ok {
 		return nil, status.Error(codes.Unauthenticated, "unauthorized")
 	}
-	if user.SelectedCompanyID == 0 {
+	scope, ok := CompanyFromContext(ctx)
+	if !ok {
 		return nil, status.Error(codes.FailedPrecondition, "no company selected")
 	}
 	if req.UserId == 0 {
 		return nil, status.Error(codes.InvalidArgument, "user_id is required")
 	}
 
-	err := h.companyService.RemoveCompanyMember(ctx, user.ID, user.SelectedCompanyID, int32(req.UserId))
+	err := h.companyService.RemoveCompanyMember(ctx, user.ID, scope.ID, int32(req.UserId))
 	if err != nil {
 		if errors.Is(err, service.ErrCannotRemoveSelf) {
 			return nil, status.Error(codes.InvalidArgument, "cannot remove yourself from the company")
@@ -161,8 +353,155 @@ func (h *Handler) RemoveCompanyMember(ctx context.Context, req *compiled.RemoveC
 		if errors.Is(err, service.ErrNotCompanyMember) {
 			return nil, status.Error(codes.NotFound, "user is not a member of this company")
 		}
-		return nil, status.Error(codes.Internal, "failed to remove company member")
+		if errors.Is(err, service.ErrCompanyArchived) {
+			return nil, status.Error(codes.FailedPrecondition, "company is archived")
+		}
+		return nil, internalError(err, "failed to remove company member")
 	}
 
 	return &compiled.RemoveCompanyMemberResponse{Success: true}, nil
 }
+
+func (h *Handler) ChangeCompanyMemberRole(ctx context.Context, req *compiled.ChangeCompanyMemberRoleRequest) (*compiled.ChangeCompanyMemberRoleResponse, error) {
+	user, ok := UserFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "unauthorized")
+	}
+	scope, ok := CompanyFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.FailedPrecondition, "no company selected")
+	}
+	if req.UserId == 0 {
+		return nil, status.Error(codes.InvalidArgument, "user_id is required")
+	}
+
+	previous, err := h.companyService.ChangeMemberRole(ctx, user.ID, scope.ID, int32(req.UserId), req.Role)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidRole):
+			return nil, status.Error(codes.InvalidArgument, "role must be 'admin' or 'member'")
+		case errors.Is(err, service.ErrNotCompanyMember):
+			return nil, status.Error(codes.NotFound, "user is not a member of this company")
+		case errors.Is(err, service.ErrCannotChangeOwnerRole),
+			errors.Is(err, service.ErrSameRole),
+			errors.Is(err, service.ErrLastAdmin):
+			return nil, status.Error(codes.FailedPrecondition, err.Error())
+		default:
+			return nil, companyError(err, "failed to change member role")
+		}
+	}
+
+	return &compiled.ChangeCompanyMemberRoleResponse{Success: true, PreviousRole: previous}, nil
+}
+
+func (h *Handler) LeaveCompany(ctx context.Context, req *compiled.LeaveCompanyRequest) (*compiled.LeaveCompanyResponse, error) {
+	user, ok := UserFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "unauthorized")
+	}
+
+	companyID := int32(req.CompanyId)
+	if companyID == 0 {
+		if scope, ok := CompanyFromContext(ctx); ok {
+			companyID = scope.ID
+		}
+	}
+	if companyID == 0 {
+		return nil, status.Error(codes.InvalidArgument, "company_id is required")
+	}
+
+	err := h.companyService.LeaveCompany(ctx, user.ID, companyID)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrOwnerCannotLeave):
+			return nil, status.Error(codes.FailedPrecondition, "the owner cannot leave the company")
+		case errors.Is(err, service.ErrLastAdmin):
+			return nil, status.Error(codes.FailedPrecondition, "the last admin cannot leave the company")
+		case errors.Is(err, service.ErrNotCompanyMember):
+			return nil, status.Error(codes.NotFound, "user is not a member of this company")
+		case errors.Is(err, service.ErrCompanyNotFound):
+			return nil, status.Error(codes.NotFound, "company not found")
+		default:
+			return nil, internalError(err, "failed to leave company")
+		}
+	}
+
+	if user.SelectedCompanyID == companyID {
+		user.SelectedCompanyID = 0
+		h.cacheSetToken(user.Token, user)
+	}
+
+	return &compiled.LeaveCompanyResponse{Success: true}, nil
+}
+
+func (h *Handler) AttachChildCompany(ctx context.Context, req *compiled.AttachChildCompanyRequest) (*compiled.AttachChildCompanyResponse, error) {
+	user, ok := UserFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "unauthorized")
+	}
+
+	parentID := int32(req.ParentCompanyId)
+	if parentID == 0 {
+		if scope, ok := CompanyFromContext(ctx); ok {
+			parentID = scope.ID
+		}
+	}
+	if parentID == 0 {
+		return nil, status.Error(codes.InvalidArgument, "parent_company_id is required")
+	}
+	if req.ChildCompanyId == 0 {
+		return nil, status.Error(codes.InvalidArgument, "child_company_id is required")
+	}
+
+	err := h.companyService.AttachChildCompany(ctx, user.ID, parentID, int32(req.ChildCompanyId))
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrCompanyHasParent):
+			return nil, status.Error(codes.FailedPrecondition, "company already has a parent company")
+		case errors.Is(err, service.ErrCompanyCycle):
+			return nil, status.Error(codes.FailedPrecondition, "a company cannot be placed under itself or one of its descendants")
+		default:
+			return nil, companyError(err, "failed to attach child company")
+		}
+	}
+
+	return &compiled.AttachChildCompanyResponse{Success: true}, nil
+}
+
+func (h *Handler) DetachChildCompany(ctx context.Context, req *compiled.DetachChildCompanyRequest) (*compiled.DetachChildCompanyResponse, error) {
+	user, ok := UserFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "unauthorized")
+	}
+
+	if req.ChildCompanyId == 0 {
+		return nil, status.Error(codes.InvalidArgument, "child_company_id is required")
+	}
+
+	err := h.companyService.DetachChildCompany(ctx, user.ID, int32(req.ChildCompanyId))
+	if err != nil {
+		if errors.Is(err, service.ErrCompanyNoParent) {
+			return nil, status.Error(codes.FailedPrecondition, "company has no parent company")
+		}
+		return nil, companyError(err, "failed to detach child company")
+	}
+
+	return &compiled.DetachChildCompanyResponse{Success: true}, nil
+}
+
+func companyError(err error, internalMsg string) error {
+	switch {
+	case errors.Is(err, service.ErrNotAdmin):
+		return status.Error(codes.PermissionDenied, "only admins can manage the company")
+	case errors.Is(err, service.ErrNotOwner):
+		return status.Error(codes.PermissionDenied, "only the owner can delete the company")
+	case errors.Is(err, service.ErrCompanyNotFound):
+		return status.Error(codes.NotFound, "company not found")
+	case errors.Is(err, service.ErrCompanyArchived):
+		return status.Error(codes.FailedPrecondition, "company is archived")
+	case errors.Is(err, service.ErrFeatureNotInPlan):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	default:
+		return internalError(err, internalMsg)
+	}
+}
@@ -0,0 +1,139 @@
+package handler
+
+import (
+	"context"
+	"errors"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"project/compiled"
+	"project/service"
+)
+
+func (h *Handler) ClaimCompanyDomain(ctx context.Context, req *compiled.ClaimCompanyDomainRequest) (*compiled.ClaimCompanyDomainResponse, error) {
+	user, ok := UserFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "unauthorized")
+	}
+	scope, ok := CompanyFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.FailedPrecondition, "no company selected")
+	}
+
+	if req.Domain == "" {
+		return nil, status.Error(codes.InvalidArgument, "domain is required")
+	}
+	if req.JoinRole != "" && req.JoinRole != "admin" && req.JoinRole != "member" {
+		return nil, status.Error(codes.InvalidArgument, "join_role must be 'admin' or 'member'")
+	}
+
+	claim, err := h.companyService.ClaimDomain(ctx, user.ID, scope.ID, req.Domain, req.JoinRole)
+	if err != nil {
+		return nil, domainError(err, "failed to claim domain")
+	}
+
+	return &compiled.ClaimCompanyDomainResponse{Domain: domainToProto(claim)}, nil
+}
+
+func (h *Handler) VerifyCompanyDomain(ctx context.Context, req *compiled.VerifyCompanyDomainRequest) (*compiled.VerifyCompanyDomainResponse, error) {
+	user, ok := UserFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "unauthorized")
+	}
+	scope, ok := CompanyFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.FailedPrecondition, "no company selected")
+	}
+	if req.DomainId == 0 {
+		return nil, status.Error(codes.InvalidArgument, "domain_id is required")
+	}
+
+	claim, err := h.companyService.VerifyDomain(ctx, user.ID, scope.ID, int32(req.DomainId))
+	if err != nil {
+		return nil, domainError(err, "failed to verify domain")
+	}
+
+	return &compiled.VerifyCompanyDomainResponse{Domain: domainToProto(claim)}, nil
+}
+
+func (h *Handler) ListCompanyDomains(ctx context.Context, req *compiled.ListCompanyDomainsRequest) (*compiled.ListCompanyDomainsResponse, error) {
+	user, ok := UserFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "unauthorized")
+	}
+	scope, ok := CompanyFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.FailedPrecondition, "no company selected")
+	}
+
+	claims, err := h.companyService.ListDomains(ctx, user.ID, scope.ID)
+	if err != nil {
+		return nil, domainError(err, "failed to list domains")
+	}
+
+	result := make([]*compiled.CompanyDomainInfo, 0, len(claims))
+	for i := range claims {
+		result = append(result, domainToProto(&claims[i]))
+	}
+
+	return &compiled.ListCompanyDomainsResponse{Domains: result}, nil
+}
+
+func (h *Handler) RemoveCompanyDomain(ctx context.Context, req *compiled.RemoveCompanyDomainRequest) (*compiled.RemoveCompanyDomainResponse, error) {
+	user, ok := UserFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "unauthorized")
+	}
+	scope, ok := CompanyFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.FailedPrecondition, "no company selected")
+	}
+	if req.DomainId == 0 {
+		return nil, status.Error(codes.InvalidArgument, "domain_id is required")
+	}
+
+	if err := h.companyService.RemoveDomain(ctx, user.ID, scope.ID, int32(req.DomainId)); err != nil {
+		return nil, domainError(err, "failed to remove domain")
+	}
+
+	return &compiled.RemoveCompanyDomainResponse{Success: true}, nil
+}
+
+func domainError(err error, internalMsg string) error {
+	switch {
+	case errors.Is(err, service.ErrNotAdmin):
+		return status.Error(codes.PermissionDenied, "only admins can manage domains")
+	case errors.Is(err, service.ErrDomainNotFound):
+		return status.Error(codes.NotFound, "domain not found")
+	case errors.Is(err, service.ErrDomainInvalid):
+		return status.Error(codes.InvalidArgument, "domain is invalid")
+	case errors.Is(err, service.ErrDomainAlreadyClaimed),
+		errors.Is(err, service.ErrDomainVerifiedElsewhere):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, service.ErrDomainNotVerified):
+		return status.Error(codes.FailedPrecondition, "verification TXT record was not found")
+	case errors.Is(err, service.ErrCompanyArchived):
+		return status.Error(codes.FailedPrecondition, "company is archived")
+	default:
+		return internalError(err, internalMsg)
+	}
+}
+
+func domainToProto(claim *compiled.CompanyDomain) *compiled.CompanyDomainInfo {
+	var verifiedAt string
+	if claim.VerifiedAt.Valid {
+		verifiedAt = claim.VerifiedAt.Time.Format("2006-01-02T15:04:05Z")
+	}
+
+	return &compiled.CompanyDomainInfo{
+		Id:                      int64(claim.ID),
+		Domain:                  claim.Domain,
+		JoinRole:                claim.JoinRole,
+		Verified:                claim.VerifiedAt.Valid,
+		VerificationRecordName:  service.DomainVerificationRecordName(claim.Domain),
+		VerificationRecordValue: service.DomainVerificationRecordValue(claim),
+		CreatedAt:               claim.CreatedAt.Time.Format("2006-01-02T15:04:05Z"),
+		VerifiedAt:              verifiedAt,
+	}
+}
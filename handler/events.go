@@ -0,0 +1,89 @@
+package handler
+
+import (
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"project/compiled"
+	"project/service"
+)
+
+// watchEventReady is the type of the first message on a WatchEvents stream.
+const watchEventReady = "ready"
+
+// WatchEvents streams the caller's membership and session events until the
+// client goes away, the caller's session ends or the caller falls behind.
+func (h *Handler) WatchEvents(req *compiled.WatchEventsRequest, stream grpc.ServerStreamingServer[compiled.WatchEventsResponse]) error {
+	ctx := stream.Context()
+	user, ok := UserFromContext(ctx)
+	if !ok {
+		return status.Error(codes.Unauthenticated, "unauthorized")
+	}
+
+	events, stop := h.realtimeHub.Watch(user.ID)
+	defer stop()
+
+	if err := stream.Send(&compiled.WatchEventsResponse{
+		Type:       watchEventReady,
+		UserId:     int64(user.ID),
+		OccurredAt: time.Now().UTC().Format("2006-01-02T15:04:05Z"),
+	}); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-events:
+			if !ok {
+				return status.Error(codes.Unavailable, "event stream fell behind, reconnect")
+			}
+
+			revoked := false
+			if event.Type == service.RealtimeSessionRevoked {
+				// Signing in again revokes the user's other sessions only
+				_, err := h.queries.FindUserByToken(ctx, user.Token)
+				if err == nil {
+					continue
+				}
+				if !errors.Is(err, pgx.ErrNoRows) {
+					return internalError(err, "failed to check session")
+				}
+				revoked = true
+			}
+
+			msg, err := realtimeEventToProto(event)
+			if err != nil {
+				return internalError(err, "failed to encode event")
+			}
+			if err := stream.Send(msg); err != nil {
+				return err
+			}
+			if revoked {
+				return status.Error(codes.Unauthenticated, "session revoked")
+			}
+		}
+	}
+}
+
+func realtimeEventToProto(event service.RealtimeEvent) (*compiled.WatchEventsResponse, error) {
+	data, err := structpb.NewStruct(event.Data)
+	if err != nil {
+		return nil, err
+	}
+	return &compiled.WatchEventsResponse{
+		Id:         event.ID,
+		Type:       event.Type,
+		CompanyId:  int64(event.CompanyID),
+		UserId:     int64(event.UserID),
+		Data:       data,
+		OccurredAt: event.OccurredAt.Format("2006-01-02T15:04:05Z"),
+	}, nil
+}
@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"context"
+	"errors"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"project/compiled"
+	"project/service"
+)
+
+func (h *Handler) ExportMyData(ctx context.Context, req *compiled.ExportMyDataRequest) (*compiled.ExportMyDataResponse, error) {
+	user, ok := UserFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "unauthorized")
+	}
+
+	export, err := h.exportService.RequestUserExport(ctx, user.ID)
+	if err != nil {
+		return nil, internalError(err, "failed to request export")
+	}
+
+	return &compiled.ExportMyDataResponse{Export: &compiled.DataExportInfo{
+		Id:        int64(export.ID),
+		Kind:      export.Kind,
+		Status:    export.Status,
+		CreatedAt: export.CreatedAt.Time.Format("2006-01-02T15:04:05Z"),
+	}}, nil
+}
+
+func (h *Handler) ExportCompanyData(ctx context.Context, req *compiled.ExportCompanyDataRequest) (*compiled.ExportCompanyDataResponse, error) {
+	user, ok := UserFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "unauthorized")
+	}
+	scope, ok := CompanyFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.FailedPrecondition, "no company selected")
+	}
+
+	export, err := h.exportService.RequestCompanyExport(ctx, user.ID, scope.ID)
+	if err != nil {
+		if errors.Is(err, service.ErrNotAdmin) {
+			return nil, status.Error(codes.PermissionDenied, "only admins can export company data")
+		}
+		return nil, internalError(err, "failed to request export")
+	}
+
+	return &compiled.ExportCompanyDataResponse{Export: &compiled.DataExportInfo{
+		Id:        int64(export.ID),
+		Kind:      export.Kind,
+		Status:    export.Status,
+		CreatedAt: export.CreatedAt.Time.Format("2006-01-02T15:04:05Z"),
+	}}, nil
+}
+
+func (h *Handler) GetDataExport(ctx context.Context, req *compiled.GetDataExportRequest) (*compiled.GetDataExportResponse, error) {
+	user, ok := UserFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "unauthorized")
+	}
+
+	export, err := h.exportService.GetExport(ctx, user.ID, int32(req.ExportId))
+	if err != nil {
+		if errors.Is(err, service.ErrExportNotFound) {
+			return nil, status.Error(codes.NotFound, "export not found")
+		}
+		return nil, internalError(err, "failed to get export")
+	}
+
+	info := &compiled.DataExportInfo{
+		Id:        int64(export.ID),
+		Kind:      export.Kind,
+		Status:    export.Status,
+		CreatedAt: export.CreatedAt.Time.Format("2006-01-02T15:04:05Z"),
+		Error:     export.Error.String,
+	}
+	if export.ExpiresAt.Valid {
+		info.ExpiresAt = export.ExpiresAt.Time.Format("2006-01-02T15:04:05Z")
+	}
+	if export.Status == service.ExportStatusReady && export.DownloadToken.Valid {
+		info.DownloadUrl = h.exportService.DownloadURL(export.DownloadToken.String)
+	}
+
+	return &compiled.GetDataExportResponse{Export: info}, nil
+}
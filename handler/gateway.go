@@ -0,0 +1,246 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"project/compiled"
+	"project/service"
+)
+
+const (
+	maxBulkInviteUploadBytes = 5 << 20
+	// Comment lines keep idle event streams open through proxies
+	sseKeepaliveInterval = 25 * time.Second
+)
+
+// IncomingHeaderMatcher forwards the company selection and request ID
+// headers to the gRPC server in addition to the gateway's default headers.
+func IncomingHeaderMatcher(key string) (string, bool) {
+	if strings.EqualFold(key, CompanyHeader) {
+		return CompanyHeader, true
+	}
+	if strings.EqualFold(key, RequestIDHeader) {
+		return RequestIDHeader, true
+	}
+	return runtime.DefaultHeaderMatcher(key)
+}
+
+// OutgoingHeaderMatcher returns the request ID as a plain X-Request-Id
+// response header; other metadata keeps the gateway's Grpc-Metadata- prefix.
+func OutgoingHeaderMatcher(key string) (string, bool) {
+	if strings.EqualFold(key, RequestIDHeader) {
+		return "X-Request-Id", true
+	}
+	return runtime.MetadataHeaderPrefix + key, true
+}
+
+// RegisterGatewayRoutes adds the HTTP-only routes that grpc-gateway cannot
+// generate from the proto annotations. They call the gRPC server through
+// client so that interceptors apply exactly as for generated routes, except
+// for export downloads, which are authorized by their link token alone and
+// stream archives that can exceed the gRPC message size limit.
+func RegisterGatewayRoutes(mux *runtime.ServeMux, client compiled.APIClient, exports *service.ExportService) error {
+	if err := mux.HandlePath(http.MethodPost, "/companies/invite/bulk/upload", bulkInviteUpload(mux, client)); err != nil {
+		return err
+	}
+	if err := mux.HandlePath(http.MethodGet, "/events/stream", watchEventsSSE(mux, client)); err != nil {
+		return err
+	}
+	return mux.HandlePath(http.MethodGet, "/exports/download", exportDownload(mux, exports))
+}
+
+// bulkInviteUpload accepts a multipart form with the CSV in the "file" field
+// and forwards it to BulkInviteUsers.
+func bulkInviteUpload(mux *runtime.ServeMux, client compiled.APIClient) runtime.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+		_, outbound := runtime.MarshalerForRequest(mux, r)
+
+		ctx, err := runtime.AnnotateContext(r.Context(), mux, r, "/api.API/BulkInviteUsers")
+		if err != nil {
+			runtime.HTTPError(r.Context(), mux, outbound, w, r, err)
+			return
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, maxBulkInviteUploadBytes)
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			runtime.HTTPError(ctx, mux, outbound, w, r, status.Error(codes.InvalidArgument, "multipart field 'file' is required"))
+			return
+		}
+		defer file.Close()
+
+		data, err := io.ReadAll(file)
+		if err != nil {
+			runtime.HTTPError(ctx, mux, outbound, w, r, status.Error(codes.InvalidArgument, "failed to read uploaded file"))
+			return
+		}
+
+		var md runtime.ServerMetadata
+		resp, err := client.BulkInviteUsers(ctx, &compiled.BulkInviteUsersRequest{Csv: string(data)},
+			grpc.Header(&md.HeaderMD), grpc.Trailer(&md.TrailerMD))
+		ctx = runtime.NewServerMetadataContext(ctx, md)
+		if err != nil {
+			runtime.HTTPError(ctx, mux, outbound, w, r, err)
+			return
+		}
+
+		runtime.ForwardResponseMessage(ctx, mux, outbound, w, r, resp)
+	}
+}
+
+// exportDownload serves a ready export archive for the token in the query
+// string.
+func exportDownload(mux *runtime.ServeMux, exports *service.ExportService) runtime.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+		_, outbound := runtime.MarshalerForRequest(mux, r)
+
+		token := r.URL.Query().Get("token")
+		if token == "" {
+			runtime.HTTPError(r.Context(), mux, outbound, w, r, status.Error(codes.InvalidArgument, "token is required"))
+			return
+		}
+
+		export, err := exports.OpenDownload(r.Context(), token)
+		if errors.Is(err, service.ErrExportNotFound) {
+			runtime.HTTPError(r.Context(), mux, outbound, w, r, status.Error(codes.NotFound, "export not found or link expired"))
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to load export", "error", err)
+			runtime.HTTPError(r.Context(), mux, outbound, w, r, status.Error(codes.Internal, "failed to load export"))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/zip")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-export-%d.zip"`, export.Kind, export.ID))
+		w.Header().Set("Content-Length", strconv.Itoa(len(export.Archive)))
+		w.Header().Set("Cache-Control", "no-store")
+		w.Write(export.Archive)
+	}
+}
+
+// watchEventsSSE serves WatchEvents as Server-Sent Events. Each message is
+// sent as an event named after its type, with the message as JSON data and
+// its ID as the event ID. Errors before the stream starts are returned as
+// regular HTTP errors; later ones end the stream with an "error" event.
+// Clients authenticate with the Authorization header, so browsers need a
+// fetch-based event source rather than EventSource.
+func watchEventsSSE(mux *runtime.ServeMux, client compiled.APIClient) runtime.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+		_, outbound := runtime.MarshalerForRequest(mux, r)
+
+		ctx, err := runtime.AnnotateContext(r.Context(), mux, r, "/api.API/WatchEvents")
+		if err != nil {
+			runtime.HTTPError(r.Context(), mux, outbound, w, r, err)
+			return
+		}
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			runtime.HTTPError(ctx, mux, outbound, w, r, status.Error(codes.Unimplemented, "streaming is not supported"))
+			return
+		}
+
+		stream, err := client.WatchEvents(ctx, &compiled.WatchEventsRequest{})
+		if err != nil {
+			runtime.HTTPError(ctx, mux, outbound, w, r, err)
+			return
+		}
+		// The first message confirms the caller was authorized
+		first, err := stream.Recv()
+		if err != nil {
+			if header, herr := stream.Header(); herr == nil {
+				ctx = runtime.NewServerMetadataContext(ctx, runtime.ServerMetadata{HeaderMD: header})
+			}
+			runtime.HTTPError(ctx, mux, outbound, w, r, err)
+			return
+		}
+		if header, err := stream.Header(); err == nil {
+			if ids := header.Get(RequestIDHeader); len(ids) > 0 {
+				w.Header().Set("X-Request-Id", ids[0])
+			}
+		}
+
+		type result struct {
+			msg *compiled.WatchEventsResponse
+			err error
+		}
+		results := make(chan result)
+		go func() {
+			for {
+				msg, err := stream.Recv()
+				select {
+				case results <- result{msg, err}:
+				case <-ctx.Done():
+					return
+				}
+				if err != nil {
+					return
+				}
+			}
+		}()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+
+		if err := writeSSEEvent(w, outbound, first); err != nil {
+			return
+		}
+		flusher.Flush()
+
+		keepalive := time.NewTicker(sseKeepaliveInterval)
+		defer keepalive.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-keepalive.C:
+				if _, err := io.WriteString(w, ": keepalive\n\n"); err != nil {
+					return
+				}
+			case res := <-results:
+				if res.err != nil {
+					if !errors.Is(res.err, io.EOF) {
+						st := status.Convert(res.err)
+						data, _ := json.Marshal(map[string]any{"code": st.Code(), "message": st.Message()})
+						fmt.Fprintf(w, "event: error\ndata: %s\n\n", data)
+						flusher.Flush()
+					}
+					return
+				}
+				if err := writeSSEEvent(w, outbound, res.msg); err != nil {
+					return
+				}
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+func writeSSEEvent(w io.Writer, marshaler runtime.Marshaler, msg *compiled.WatchEventsResponse) error {
+	data, err := marshaler.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	if msg.Id != 0 {
+		if _, err := fmt.Fprintf(w, "id: %d\n", msg.Id); err != nil {
+			return err
+		}
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", msg.Type, data)
+	return err
+}
@@ -2,13 +2,19 @@ package handler
 
 import (
 	"context"
-	"log"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/netip"
+	"strconv"
 	"strings"
 	"sync"
 
-	"google.golang.org/grpc"
+	"github.com/jackc/pgx/v5"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/status"
 
 	"project/compiled"
@@ -17,44 +23,219 @@ import (
 
 type contextKey string
 
-const UserContextKey contextKey = "user"
+const (
+	UserContextKey    contextKey = "user"
+	CompanyContextKey contextKey = "company"
+)
+
+// CompanyHeader selects the company a request acts on. It takes precedence
+// over the user's persisted selection so that several clients of the same
+// user can work in different companies at once.
+const CompanyHeader = "x-company-id"
 
+// publicMethods can be called without a token. AuthInterceptor and
+// StreamAuthInterceptor both consult it through authorize, so unary and
+// streaming methods follow the same rules.
 var publicMethods = map[string]bool{
-	"/api.API/Health":          true,
-	"/api.API/Login":           true,
-	"/api.API/RequestLoginOTP": true,
+	"/api.API/Health":            true,
+	"/api.API/Login":             true,
+	"/api.API/RequestLoginOTP":   true,
+	"/api.API/AcceptInvitation":  true,
+	"/api.API/DeclineInvitation": true,
 }
 
 type Handler struct {
 	compiled.UnimplementedAPIServer
-	authService    *service.AuthService
-	companyService *service.CompanyService
-	queries        *compiled.Queries
-	tokenCache     sync.Map
+	authService         *service.AuthService
+	companyService      *service.CompanyService
+	exportService       *service.ExportService
+	accountService      *service.AccountService
+	webhookService      *service.WebhookService
+	auditExportService  *service.AuditExportService
+	notificationService *service.NotificationService
+	realtimeHub         *service.RealtimeHub
+	queries             *compiled.Queries
+	tokenCache          sync.Map
+	// trustedProxies may set X-Forwarded-For, in addition to loopback
+	// peers such as the in-process gateway
+	trustedProxies []netip.Prefix
 }
 
-func NewHandler(authService *service.AuthService, companyService *service.CompanyService, queries *compiled.Queries) *Handler {
-	return &Handler{
-		authService:    authService,
-		companyService: companyService,
-		queries:        queries,
+func NewHandler(authService *service.AuthService, companyService *service.CompanyService, exportService *service.ExportService, accountService *service.AccountService, webhookService *service.WebhookService, auditExportService *service.AuditExportService, notificationService *service.NotificationService, realtimeHub *service.RealtimeHub, queries *compiled.Queries, trustedProxies []netip.Prefix) *Handler {
+	h := &Handler{
+		authService:         authService,
+		companyService:      companyService,
+		exportService:       exportService,
+		accountService:      accountService,
+		webhookService:      webhookService,
+		auditExportService:  auditExportService,
+		notificationService: notificationService,
+		realtimeHub:         realtimeHub,
+		queries:             queries,
+		trustedProxies:      trustedProxies,
 	}
+	// Sessions revoked through another instance must not stay cached here
+	if realtimeHub != nil {
+		realtimeHub.OnSessionRevoked(h.cacheDeleteByUserID)
+	}
+	return h
 }
 
-func (h *Handler) AuthInterceptor() grpc.UnaryServerInterceptor {
-	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
-		if publicMethods[info.FullMethod] {
-			return handler(ctx, req)
+// ParseTrustedProxies parses a comma-separated list of IP addresses and
+// CIDR prefixes.
+func ParseTrustedProxies(list string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if addr, err := netip.ParseAddr(entry); err == nil {
+			addr = addr.Unmap()
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", entry, err)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// authorize attaches the client details to ctx and, unless method is
+// public, the authenticated user and the company the call acts on.
+func (h *Handler) authorize(ctx context.Context, method string) (context.Context, error) {
+	ctx = service.WithRequestInfo(ctx, h.clientInfo(ctx))
+
+	if publicMethods[method] {
+		return ctx, nil
+	}
+
+	user, err := h.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx = context.WithValue(ctx, UserContextKey, user)
+
+	scope, err := h.resolveCompany(ctx, user)
+	if err != nil {
+		annotateRequestLog(ctx, user.ID, 0)
+		return nil, err
+	}
+	var companyID int32
+	if scope != nil {
+		ctx = context.WithValue(ctx, CompanyContextKey, scope)
+		companyID = scope.ID
+	}
+	annotateRequestLog(ctx, user.ID, companyID)
+
+	return ctx, nil
+}
+
+// CompanyScope is the company a request acts on and the caller's role in it.
+type CompanyScope struct {
+	ID   int32
+	Role string
+}
+
+// resolveCompany picks the company from the x-company-id header, falling back
+// to the user's persisted selection when the header is absent. A header
+// naming a company the user does not belong to is rejected; a stale persisted
+// selection simply yields no company.
+func (h *Handler) resolveCompany(ctx context.Context, user *AuthenticatedUser) (*CompanyScope, error) {
+	var values []string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		values = md.Get(CompanyHeader)
+	}
+
+	if len(values) > 0 && values[0] != "" {
+		companyID, err := strconv.ParseInt(values[0], 10, 32)
+		if err != nil || companyID <= 0 {
+			return nil, status.Error(codes.InvalidArgument, "invalid x-company-id header")
 		}
 
-		user, err := h.authenticate(ctx)
+		role, err := h.companyService.GetCompanyUserRole(ctx, int32(companyID), user.ID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, status.Error(codes.PermissionDenied, "user is not a member of this company")
+		}
 		if err != nil {
-			return nil, err
+			return nil, internalError(err, "internal error")
+		}
+
+		return &CompanyScope{ID: int32(companyID), Role: role}, nil
+	}
+
+	if user.SelectedCompanyID == 0 {
+		return nil, nil
+	}
+
+	role, err := h.companyService.GetCompanyUserRole(ctx, user.SelectedCompanyID, user.ID)
+	if err != nil {
+		return nil, nil
+	}
+
+	return &CompanyScope{ID: user.SelectedCompanyID, Role: role}, nil
+}
+
+// clientInfo reads the caller's address and user agent for the audit log.
+// The address is the connection's peer. X-Forwarded-For is only honoured
+// when the peer is a trusted proxy, such as the in-process gateway, which
+// appends the address it accepted the request from; the hops are read from
+// the right, skipping trusted proxies, so a client cannot forge its address
+// by sending the header itself.
+func (h *Handler) clientInfo(ctx context.Context) service.RequestInfo {
+	var info service.RequestInfo
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	if p, ok := peer.FromContext(ctx); ok {
+		if host, _, err := net.SplitHostPort(p.Addr.String()); err == nil {
+			info.IP = host
+		} else {
+			info.IP = p.Addr.String()
+		}
+	}
+
+	if h.isTrustedProxy(info.IP) {
+		hops := strings.Split(strings.Join(md.Get("x-forwarded-for"), ","), ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop := strings.TrimSpace(hops[i])
+			if _, err := netip.ParseAddr(hop); err != nil {
+				break
+			}
+			info.IP = hop
+			if !h.isTrustedProxy(hop) {
+				break
+			}
 		}
+	}
+
+	if agent := md.Get("grpcgateway-user-agent"); len(agent) > 0 {
+		info.UserAgent = agent[0]
+	} else if agent := md.Get("user-agent"); len(agent) > 0 {
+		info.UserAgent = agent[0]
+	}
+
+	return info
+}
 
-		ctx = context.WithValue(ctx, UserContextKey, user)
-		return handler(ctx, req)
+func (h *Handler) isTrustedProxy(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	if addr.IsLoopback() {
+		return true
 	}
+	for _, prefix := range h.trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
 }
 
 type AuthenticatedUser struct {
@@ -123,10 +304,15 @@ func UserFromContext(ctx context.Context) (*AuthenticatedUser, bool) {
 	return user, ok
 }
 
+func CompanyFromContext(ctx context.Context) (*CompanyScope, bool) {
+	scope, ok := ctx.Value(CompanyContextKey).(*CompanyScope)
+	return scope, ok
+}
+
 func (h *Handler) LoadTokenCache(ctx context.Context) {
 	rows, err := h.queries.GetAllUsersWithToken(ctx)
 	if err != nil {
-		log.Printf("Failed to load token cache: %v", err)
+		slog.ErrorContext(ctx, "Failed to load token cache", "error", err)
 		return
 	}
 
@@ -146,7 +332,7 @@ func (h *Handler) LoadTokenCache(ctx context.Context) {
 		})
 	}
 
-	log.Printf("Token cache loaded: %d entries", len(rows))
+	slog.InfoContext(ctx, "Token cache loaded", "entries", len(rows))
 }
 
 func (h *Handler) cacheSetToken(token string, user *AuthenticatedUser) {
@@ -155,9 +341,9 @@ func (h *Handler) cacheSetToken(token string, user *AuthenticatedUser) {
 
 func (h *Handler) cacheDeleteByUserID(userID int32) {
 	h.tokenCache.Range(func(key, value any) bool {
+		// Keep going: tokens cached before a sign-in elsewhere may linger
 		if value.(*AuthenticatedUser).ID == userID {
 			h.tokenCache.Delete(key)
-			return false
 		}
 		return true
 	})
@@ -0,0 +1,147 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"project/compiled"
+)
+
+func TestClientInfoTrustsForwardedForOnlyFromProxies(t *testing.T) {
+	h := &Handler{trustedProxies: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}}
+
+	tests := []struct {
+		name      string
+		peer      string
+		forwarded []string
+		want      string
+	}{
+		{"direct client", "198.51.100.4:5000", nil, "198.51.100.4"},
+		{"direct client forging the header", "198.51.100.4:5000", []string{"203.0.113.9"}, "198.51.100.4"},
+		{"gateway", "127.0.0.1:5000", []string{"203.0.113.9"}, "203.0.113.9"},
+		{"gateway with client-sent hops", "127.0.0.1:5000", []string{"192.0.2.1, 203.0.113.9"}, "203.0.113.9"},
+		{"gateway behind a trusted load balancer", "[::1]:5000", []string{"192.0.2.1, 203.0.113.9, 10.1.2.3"}, "203.0.113.9"},
+		{"gateway with a malformed hop", "127.0.0.1:5000", []string{"not-an-ip"}, "127.0.0.1"},
+		{"gateway without the header", "127.0.0.1:5000", nil, "127.0.0.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr, err := net.ResolveTCPAddr("tcp", tt.peer)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: addr})
+			md := metadata.MD{}
+			if tt.forwarded != nil {
+				md.Set("x-forwarded-for", tt.forwarded...)
+			}
+			ctx = metadata.NewIncomingContext(ctx, md)
+
+			if got := h.clientInfo(ctx).IP; got != tt.want {
+				t.Errorf("IP = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+	prefixes, err := ParseTrustedProxies(" 10.0.0.0/8, 192.0.2.7 ,,2001:db8::/32")
+	if err != nil {
+		t.Fatalf("ParseTrustedProxies: %v", err)
+	}
+	want := []string{"10.0.0.0/8", "192.0.2.7/32", "2001:db8::/32"}
+	if len(prefixes) != len(want) {
+		t.Fatalf("prefixes = %v, want %v", prefixes, want)
+	}
+	for i, p := range prefixes {
+		if p.String() != want[i] {
+			t.Errorf("prefixes[%d] = %s, want %s", i, p, want[i])
+		}
+	}
+
+	if _, err := ParseTrustedProxies("10.0.0.0/33"); err == nil {
+		t.Error("ParseTrustedProxies accepted an invalid prefix")
+	}
+}
+
+func TestResolveCompanyRejectsMalformedHeader(t *testing.T) {
+	// Malformed headers are rejected before any lookup
+	h := &Handler{}
+	user := &AuthenticatedUser{ID: 1, SelectedCompanyID: 7}
+
+	for _, header := range []string{"abc", "0", "-3", "1.5", "99999999999"} {
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(CompanyHeader, header))
+		scope, err := h.resolveCompany(ctx, user)
+		if status.Code(err) != codes.InvalidArgument || scope != nil {
+			t.Errorf("header %q: scope = %+v, err = %v, want InvalidArgument", header, scope, err)
+		}
+	}
+}
+
+func TestResolveCompany(t *testing.T) {
+	pool, queries := testDB(t)
+	h := newTestHandler(pool, queries)
+	ctx := context.Background()
+
+	newUser := func(name string) int32 {
+		user, err := queries.CreateUser(ctx, compiled.CreateUserParams{
+			Email: fmt.Sprintf("%s-%d@example.com", name, time.Now().UnixNano()),
+			Name:  name,
+		})
+		if err != nil {
+			t.Fatalf("create user: %v", err)
+		}
+		return user.ID
+	}
+	newCompany := func(ownerID int32) int32 {
+		company, err := h.companyService.CreateCompany(ctx, ownerID, fmt.Sprintf("Company %d", time.Now().UnixNano()))
+		if err != nil {
+			t.Fatalf("create company: %v", err)
+		}
+		return company.ID
+	}
+	userID := newUser("member")
+	ownID := newCompany(userID)
+	foreignID := newCompany(newUser("other"))
+
+	tests := []struct {
+		name     string
+		header   string
+		selected int32
+		want     *CompanyScope
+		wantCode codes.Code
+	}{
+		{"header for own company", fmt.Sprint(ownID), 0, &CompanyScope{ID: ownID, Role: "admin"}, codes.OK},
+		{"header for foreign company", fmt.Sprint(foreignID), ownID, nil, codes.PermissionDenied},
+		{"header overrides selection", fmt.Sprint(ownID), foreignID, &CompanyScope{ID: ownID, Role: "admin"}, codes.OK},
+		{"selected own company", "", ownID, &CompanyScope{ID: ownID, Role: "admin"}, codes.OK},
+		{"stale selection", "", foreignID, nil, codes.OK},
+		{"no selection", "", 0, nil, codes.OK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			md := metadata.MD{}
+			if tt.header != "" {
+				md.Set(CompanyHeader, tt.header)
+			}
+			user := &AuthenticatedUser{ID: userID, SelectedCompanyID: tt.selected}
+
+			scope, err := h.resolveCompany(metadata.NewIncomingContext(ctx, md), user)
+			if status.Code(err) != tt.wantCode {
+				t.Fatalf("err = %v, want %v", err, tt.wantCode)
+			}
+			if (scope == nil) != (tt.want == nil) || (scope != nil && *scope != *tt.want) {
+				t.Errorf("scope = %+v, want %+v", scope, tt.want)
+			}
+		})
+	}
+}
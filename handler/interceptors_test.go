@@ -0,0 +1,203 @@
+package handler
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"project/compiled"
+)
+
+// newTestClient serves h over an in-memory connection with both auth
+// interceptors installed, as main does.
+func newTestClient(t *testing.T, h *Handler) compiled.APIClient {
+	t.Helper()
+
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(h.AuthInterceptor()),
+		grpc.ChainStreamInterceptor(h.StreamAuthInterceptor()),
+	)
+	compiled.RegisterAPIServer(srv, h)
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return compiled.NewAPIClient(conn)
+}
+
+func TestStreamAuthRejectsMissingToken(t *testing.T) {
+	client := newTestClient(t, &Handler{})
+
+	stream, err := client.WatchEvents(context.Background(), &compiled.WatchEventsRequest{})
+	if err != nil {
+		t.Fatalf("WatchEvents: %v", err)
+	}
+	// Server-streaming errors surface on the first receive
+	if _, err := stream.Recv(); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("Recv = %v, want Unauthenticated", err)
+	}
+}
+
+func TestAuthInterceptorsFollowPublicMethods(t *testing.T) {
+	client := newTestClient(t, &Handler{})
+
+	resp, err := client.Health(context.Background(), &compiled.HealthRequest{})
+	if err != nil {
+		t.Fatalf("Health without a token: %v", err)
+	}
+	if resp.Status != "ok" {
+		t.Errorf("Health status = %q, want ok", resp.Status)
+	}
+
+	if _, err := client.GetNotificationPreferences(context.Background(), &compiled.GetNotificationPreferencesRequest{}); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("unary call without a token = %v, want Unauthenticated", err)
+	}
+}
+
+// fakeServerStream is a server stream that only carries a context.
+type fakeServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *fakeServerStream) Context() context.Context {
+	return s.ctx
+}
+
+func TestStreamAuthInjectsUser(t *testing.T) {
+	h := &Handler{}
+	h.cacheSetToken("stream-token", &AuthenticatedUser{ID: 42, Email: "user@example.com"})
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer stream-token"))
+	info := &grpc.StreamServerInfo{FullMethod: compiled.API_WatchEvents_FullMethodName, IsServerStream: true}
+
+	var got *AuthenticatedUser
+	err := h.StreamAuthInterceptor()(nil, &fakeServerStream{ctx: ctx}, info, func(srv any, ss grpc.ServerStream) error {
+		got, _ = UserFromContext(ss.Context())
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("interceptor: %v", err)
+	}
+	if got == nil || got.ID != 42 {
+		t.Fatalf("user in stream context = %+v, want user 42", got)
+	}
+}
+
+func TestRecoveryInterceptorsReturnInternal(t *testing.T) {
+	ctx := context.Background()
+	panics := []struct {
+		name  string
+		value any
+	}{
+		{"string", "boom"},
+		{"error", context.Canceled},
+		{"nil map write", nil},
+	}
+	for _, tt := range panics {
+		t.Run(tt.name, func(t *testing.T) {
+			fail := func() {
+				if tt.value == nil {
+					var m map[string]int
+					m["x"] = 1
+				}
+				panic(tt.value)
+			}
+
+			_, err := RecoveryInterceptor()(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/api.API/Unary"}, func(context.Context, any) (any, error) {
+				fail()
+				return nil, nil
+			})
+			if status.Code(err) != codes.Internal {
+				t.Errorf("unary: err = %v, want Internal", err)
+			}
+
+			err = StreamRecoveryInterceptor()(nil, &fakeServerStream{ctx: ctx}, &grpc.StreamServerInfo{FullMethod: "/api.API/Stream"}, func(any, grpc.ServerStream) error {
+				fail()
+				return nil
+			})
+			if status.Code(err) != codes.Internal {
+				t.Errorf("stream: err = %v, want Internal", err)
+			}
+		})
+	}
+}
+
+func TestRecoveryInterceptorPassesErrorsThrough(t *testing.T) {
+	want := status.Error(codes.NotFound, "not found")
+	_, err := RecoveryInterceptor()(context.Background(), nil, &grpc.UnaryServerInfo{}, func(context.Context, any) (any, error) {
+		return nil, want
+	})
+	if err != want {
+		t.Errorf("err = %v, want %v", err, want)
+	}
+}
+
+func TestRequestIDIsKeptOrGenerated(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		keep   bool
+	}{
+		{"client ID", "req-123", true},
+		{"no ID", "", false},
+		{"control characters", "bad\nid", false},
+		{"too long", string(make([]byte, maxRequestIDLength+1)), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			md := metadata.MD{}
+			if tt.header != "" {
+				md.Set(RequestIDHeader, tt.header)
+			}
+			got := requestID(metadata.NewIncomingContext(context.Background(), md))
+			if tt.keep && got != tt.header {
+				t.Errorf("request ID = %q, want the client's %q", got, tt.header)
+			}
+			if !tt.keep && (got == tt.header || !validRequestID(got)) {
+				t.Errorf("request ID = %q, want a generated one", got)
+			}
+		})
+	}
+}
+
+func TestDeadlineInterceptor(t *testing.T) {
+	interceptor := DeadlineInterceptor(time.Minute)
+	deadline := func(ctx context.Context) time.Duration {
+		var left time.Duration
+		_, _ = interceptor(ctx, nil, &grpc.UnaryServerInfo{}, func(ctx context.Context, _ any) (any, error) {
+			if d, ok := ctx.Deadline(); ok {
+				left = time.Until(d)
+			}
+			return nil, nil
+		})
+		return left
+	}
+
+	if left := deadline(context.Background()); left <= 0 || left > time.Minute {
+		t.Errorf("call without a deadline got %v left, want at most a minute", left)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+	if left := deadline(ctx); left <= time.Minute {
+		t.Errorf("client deadline was shortened to %v", left)
+	}
+}
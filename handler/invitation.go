@@ -0,0 +1,155 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"project/compiled"
+	"project/service"
+)
+
+func (h *Handler) ListInvitations(ctx context.Context, req *compiled.ListInvitationsRequest) (*compiled.ListInvitationsResponse, error) {
+	user, ok := UserFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "unauthorized")
+	}
+	scope, ok := CompanyFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.FailedPrecondition, "no company selected")
+	}
+
+	invitations, err := h.companyService.ListInvitations(ctx, user.ID, scope.ID)
+	if err != nil {
+		if errors.Is(err, service.ErrNotAdmin) {
+			return nil, status.Error(codes.PermissionDenied, "only admins can list invitations")
+		}
+		return nil, internalError(err, "failed to list invitations")
+	}
+
+	result := make([]*compiled.InvitationInfo, 0, len(invitations))
+	for i := range invitations {
+		result = append(result, invitationToProto(&invitations[i]))
+	}
+
+	return &compiled.ListInvitationsResponse{Invitations: result}, nil
+}
+
+func (h *Handler) RevokeInvitation(ctx context.Context, req *compiled.RevokeInvitationRequest) (*compiled.RevokeInvitationResponse, error) {
+	user, ok := UserFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "unauthorized")
+	}
+	scope, ok := CompanyFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.FailedPrecondition, "no company selected")
+	}
+	if req.InvitationId == 0 {
+		return nil, status.Error(codes.InvalidArgument, "invitation_id is required")
+	}
+
+	err := h.companyService.RevokeInvitation(ctx, user.ID, scope.ID, int32(req.InvitationId))
+	if err != nil {
+		return nil, invitationError(err, "failed to revoke invitation")
+	}
+
+	return &compiled.RevokeInvitationResponse{Success: true}, nil
+}
+
+func (h *Handler) ResendInvitation(ctx context.Context, req *compiled.ResendInvitationRequest) (*compiled.ResendInvitationResponse, error) {
+	user, ok := UserFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "unauthorized")
+	}
+	scope, ok := CompanyFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.FailedPrecondition, "no company selected")
+	}
+	if req.InvitationId == 0 {
+		return nil, status.Error(codes.InvalidArgument, "invitation_id is required")
+	}
+
+	invitation, err := h.companyService.ResendInvitation(ctx, user.ID, scope.ID, int32(req.InvitationId))
+	if err != nil {
+		return nil, invitationError(err, "failed to resend invitation")
+	}
+
+	return &compiled.ResendInvitationResponse{Invitation: invitationToProto(invitation)}, nil
+}
+
+func (h *Handler) AcceptInvitation(ctx context.Context, req *compiled.AcceptInvitationRequest) (*compiled.AcceptInvitationResponse, error) {
+	if req.Token == "" {
+		return nil, status.Error(codes.InvalidArgument, "token is required")
+	}
+
+	invitation, err := h.companyService.AcceptInvitation(ctx, req.Token)
+	if err != nil {
+		return nil, invitationError(err, "failed to accept invitation")
+	}
+
+	return &compiled.AcceptInvitationResponse{
+		Success:   true,
+		CompanyId: int64(invitation.CompanyID),
+		Email:     invitation.Email,
+	}, nil
+}
+
+func (h *Handler) DeclineInvitation(ctx context.Context, req *compiled.DeclineInvitationRequest) (*compiled.DeclineInvitationResponse, error) {
+	if req.Token == "" {
+		return nil, status.Error(codes.InvalidArgument, "token is required")
+	}
+
+	if err := h.companyService.DeclineInvitation(ctx, req.Token); err != nil {
+		return nil, invitationError(err, "failed to decline invitation")
+	}
+
+	return &compiled.DeclineInvitationResponse{Success: true}, nil
+}
+
+func invitationError(err error, internalMsg string) error {
+	switch {
+	case errors.Is(err, service.ErrNotAdmin):
+		return status.Error(codes.PermissionDenied, "only admins can manage invitations")
+	case errors.Is(err, service.ErrInvitationNotFound):
+		return status.Error(codes.NotFound, "invitation not found")
+	case errors.Is(err, service.ErrInvitationExpired):
+		return status.Error(codes.FailedPrecondition, "invitation has expired")
+	case errors.Is(err, service.ErrInvitationNotPending):
+		return status.Error(codes.FailedPrecondition, "invitation is no longer pending")
+	case errors.Is(err, service.ErrInvitationPending):
+		return status.Error(codes.AlreadyExists, "user already has a pending invitation")
+	case errors.Is(err, service.ErrCompanyArchived):
+		return status.Error(codes.FailedPrecondition, "company is archived")
+	case errors.Is(err, service.ErrCompanyNotFound):
+		return status.Error(codes.NotFound, "company not found")
+	default:
+		return internalError(err, internalMsg)
+	}
+}
+
+func invitationToProto(inv *compiled.Invitation) *compiled.InvitationInfo {
+	state := inv.Status
+	if state == service.InvitationStatusPending && time.Now().After(inv.ExpiresAt.Time) {
+		state = service.InvitationStatusExpired
+	}
+
+	var respondedAt string
+	if inv.RespondedAt.Valid {
+		respondedAt = inv.RespondedAt.Time.Format("2006-01-02T15:04:05Z")
+	}
+
+	return &compiled.InvitationInfo{
+		Id:          int64(inv.ID),
+		Email:       inv.Email,
+		Name:        inv.Name,
+		Role:        inv.Role,
+		Status:      state,
+		InvitedBy:   int64(inv.InvitedBy.Int32),
+		ExpiresAt:   inv.ExpiresAt.Time.Format("2006-01-02T15:04:05Z"),
+		RespondedAt: respondedAt,
+		CreatedAt:   inv.CreatedAt.Time.Format("2006-01-02T15:04:05Z"),
+	}
+}
@@ -0,0 +1,189 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"project/compiled"
+	"project/service"
+)
+
+func (h *Handler) CreateJoinLink(ctx context.Context, req *compiled.CreateJoinLinkRequest) (*compiled.CreateJoinLinkResponse, error) {
+	user, ok := UserFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "unauthorized")
+	}
+	scope, ok := CompanyFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.FailedPrecondition, "no company selected")
+	}
+
+	if req.Role != "admin" && req.Role != "member" {
+		return nil, status.Error(codes.InvalidArgument, "role must be 'admin' or 'member'")
+	}
+	if req.MaxUses < 0 {
+		return nil, status.Error(codes.InvalidArgument, "max_uses must not be negative")
+	}
+
+	opts := service.JoinLinkOptions{
+		Role:        req.Role,
+		MaxUses:     req.MaxUses,
+		EmailDomain: req.EmailDomain,
+	}
+	if req.ExpiresAt != "" {
+		expiresAt, err := time.Parse(time.RFC3339, req.ExpiresAt)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, "expires_at must be an RFC 3339 timestamp")
+		}
+		if expiresAt.Before(time.Now()) {
+			return nil, status.Error(codes.InvalidArgument, "expires_at must be in the future")
+		}
+		opts.ExpiresAt = expiresAt.UTC()
+	}
+
+	link, err := h.companyService.CreateJoinLink(ctx, user.ID, scope.ID, opts)
+	if err != nil {
+		if errors.Is(err, service.ErrNotAdmin) {
+			return nil, status.Error(codes.PermissionDenied, "only admins can create join links")
+		}
+		if errors.Is(err, service.ErrCompanyArchived) {
+			return nil, status.Error(codes.FailedPrecondition, "company is archived")
+		}
+		if errors.Is(err, service.ErrFeatureNotInPlan) {
+			return nil, status.Error(codes.FailedPrecondition, err.Error())
+		}
+		return nil, internalError(err, "failed to create join link")
+	}
+
+	return &compiled.CreateJoinLinkResponse{JoinLink: h.joinLinkToProto(link)}, nil
+}
+
+func (h *Handler) ListJoinLinks(ctx context.Context, req *compiled.ListJoinLinksRequest) (*compiled.ListJoinLinksResponse, error) {
+	user, ok := UserFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "unauthorized")
+	}
+	scope, ok := CompanyFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.FailedPrecondition, "no company selected")
+	}
+
+	links, err := h.companyService.ListJoinLinks(ctx, user.ID, scope.ID)
+	if err != nil {
+		if errors.Is(err, service.ErrNotAdmin) {
+			return nil, status.Error(codes.PermissionDenied, "only admins can list join links")
+		}
+		return nil, internalError(err, "failed to list join links")
+	}
+
+	result := make([]*compiled.JoinLinkInfo, 0, len(links))
+	for i := range links {
+		result = append(result, h.joinLinkToProto(&links[i]))
+	}
+
+	return &compiled.ListJoinLinksResponse{JoinLinks: result}, nil
+}
+
+func (h *Handler) DisableJoinLink(ctx context.Context, req *compiled.DisableJoinLinkRequest) (*compiled.DisableJoinLinkResponse, error) {
+	user, ok := UserFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "unauthorized")
+	}
+	scope, ok := CompanyFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.FailedPrecondition, "no company selected")
+	}
+	if req.JoinLinkId == 0 {
+		return nil, status.Error(codes.InvalidArgument, "join_link_id is required")
+	}
+
+	err := h.companyService.DisableJoinLink(ctx, user.ID, scope.ID, int32(req.JoinLinkId))
+	if err != nil {
+		if errors.Is(err, service.ErrNotAdmin) {
+			return nil, status.Error(codes.PermissionDenied, "only admins can disable join links")
+		}
+		if errors.Is(err, service.ErrJoinLinkNotFound) {
+			return nil, status.Error(codes.NotFound, "join link not found or already disabled")
+		}
+		if errors.Is(err, service.ErrCompanyArchived) {
+			return nil, status.Error(codes.FailedPrecondition, "company is archived")
+		}
+		return nil, internalError(err, "failed to disable join link")
+	}
+
+	return &compiled.DisableJoinLinkResponse{Success: true}, nil
+}
+
+func (h *Handler) JoinCompanyByLink(ctx context.Context, req *compiled.JoinCompanyByLinkRequest) (*compiled.JoinCompanyByLinkResponse, error) {
+	user, ok := UserFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "unauthorized")
+	}
+	if req.Token == "" {
+		return nil, status.Error(codes.InvalidArgument, "token is required")
+	}
+
+	company, role, err := h.companyService.JoinCompanyByLink(ctx, user.ID, user.Email, req.Token)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrJoinLinkNotFound):
+			return nil, status.Error(codes.NotFound, "join link not found")
+		case errors.Is(err, service.ErrJoinLinkDisabled),
+			errors.Is(err, service.ErrJoinLinkExpired),
+			errors.Is(err, service.ErrJoinLinkExhausted),
+			errors.Is(err, service.ErrSeatLimitReached),
+			errors.Is(err, service.ErrFeatureNotInPlan),
+			errors.Is(err, service.ErrCompanyArchived):
+			return nil, status.Error(codes.FailedPrecondition, err.Error())
+		case errors.Is(err, service.ErrJoinLinkEmailDomain),
+			errors.Is(err, service.ErrEmailDomainBlocked):
+			return nil, status.Error(codes.PermissionDenied, err.Error())
+		case errors.Is(err, service.ErrUserAlreadyMember):
+			return nil, status.Error(codes.AlreadyExists, "user is already a member of this company")
+		default:
+			return nil, internalError(err, "failed to join company")
+		}
+	}
+
+	// Users without a company land in the one they just joined
+	if user.SelectedCompanyID == 0 {
+		if _, err := h.companyService.SelectCompany(ctx, user.ID, company.ID); err == nil {
+			user.SelectedCompanyID = company.ID
+			h.cacheSetToken(user.Token, user)
+		}
+	}
+
+	return &compiled.JoinCompanyByLinkResponse{
+		Success: true,
+		Company: &compiled.CompanyInfo{
+			Id:        int64(company.ID),
+			Name:      company.CompanyName,
+			Role:      role,
+			IsOwner:   company.OwnerID == user.ID,
+			CreatedAt: company.CreatedAt.Time.Format("2006-01-02T15:04:05Z"),
+			Archived:  company.ArchivedAt.Valid,
+		},
+	}, nil
+}
+
+func (h *Handler) joinLinkToProto(link *compiled.CompanyJoinLink) *compiled.JoinLinkInfo {
+	info := &compiled.JoinLinkInfo{
+		Id:          int64(link.ID),
+		Token:       link.Token,
+		Url:         h.companyService.JoinLinkURL(link.Token),
+		Role:        link.Role,
+		MaxUses:     link.MaxUses.Int32,
+		UseCount:    link.UseCount,
+		EmailDomain: link.EmailDomain.String,
+		Disabled:    link.DisabledAt.Valid,
+		CreatedAt:   link.CreatedAt.Time.Format("2006-01-02T15:04:05Z"),
+	}
+	if link.ExpiresAt.Valid {
+		info.ExpiresAt = link.ExpiresAt.Time.Format("2006-01-02T15:04:05Z")
+	}
+	return info
+}
@@ -0,0 +1,156 @@
+package handler
+
+import (
+	"context"
+	"errors"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"project/compiled"
+	"project/service"
+)
+
+const maxJoinRequestMessageLength = 1000
+
+func (h *Handler) RequestToJoinCompany(ctx context.Context, req *compiled.RequestToJoinCompanyRequest) (*compiled.RequestToJoinCompanyResponse, error) {
+	user, ok := UserFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "unauthorized")
+	}
+
+	if req.CompanyId == 0 {
+		return nil, status.Error(codes.InvalidArgument, "company_id is required")
+	}
+	if len(req.Message) > maxJoinRequestMessageLength {
+		return nil, status.Error(codes.InvalidArgument, "message is too long")
+	}
+
+	request, err := h.companyService.RequestToJoinCompany(ctx, user.ID, int32(req.CompanyId), req.Message)
+	if err != nil {
+		return nil, joinRequestError(err, "failed to request to join company")
+	}
+
+	return &compiled.RequestToJoinCompanyResponse{
+		JoinRequest: joinRequestToProto(request, user.Name, user.Email),
+	}, nil
+}
+
+func (h *Handler) ListJoinRequests(ctx context.Context, req *compiled.ListJoinRequestsRequest) (*compiled.ListJoinRequestsResponse, error) {
+	user, ok := UserFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "unauthorized")
+	}
+	scope, ok := CompanyFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.FailedPrecondition, "no company selected")
+	}
+
+	requests, err := h.companyService.ListJoinRequests(ctx, user.ID, scope.ID)
+	if err != nil {
+		return nil, joinRequestError(err, "failed to list join requests")
+	}
+
+	result := make([]*compiled.JoinRequestInfo, 0, len(requests))
+	for _, r := range requests {
+		result = append(result, &compiled.JoinRequestInfo{
+			Id:        int64(r.ID),
+			CompanyId: int64(r.CompanyID),
+			UserId:    int64(r.UserID),
+			Name:      r.Name,
+			Email:     r.Email,
+			Message:   r.Message,
+			Status:    r.Status,
+			CreatedAt: r.CreatedAt.Time.Format("2006-01-02T15:04:05Z"),
+		})
+	}
+
+	return &compiled.ListJoinRequestsResponse{JoinRequests: result}, nil
+}
+
+func (h *Handler) ApproveJoinRequest(ctx context.Context, req *compiled.ApproveJoinRequestRequest) (*compiled.ApproveJoinRequestResponse, error) {
+	user, ok := UserFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "unauthorized")
+	}
+	scope, ok := CompanyFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.FailedPrecondition, "no company selected")
+	}
+
+	if req.RequestId == 0 {
+		return nil, status.Error(codes.InvalidArgument, "request_id is required")
+	}
+	if req.Role != "" && req.Role != "admin" && req.Role != "member" {
+		return nil, status.Error(codes.InvalidArgument, "role must be 'admin' or 'member'")
+	}
+
+	request, err := h.companyService.ApproveJoinRequest(ctx, user.ID, scope.ID, int32(req.RequestId), req.Role)
+	if err != nil {
+		return nil, joinRequestError(err, "failed to approve join request")
+	}
+
+	return &compiled.ApproveJoinRequestResponse{JoinRequest: joinRequestToProto(request, "", "")}, nil
+}
+
+func (h *Handler) RejectJoinRequest(ctx context.Context, req *compiled.RejectJoinRequestRequest) (*compiled.RejectJoinRequestResponse, error) {
+	user, ok := UserFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "unauthorized")
+	}
+	scope, ok := CompanyFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.FailedPrecondition, "no company selected")
+	}
+
+	if req.RequestId == 0 {
+		return nil, status.Error(codes.InvalidArgument, "request_id is required")
+	}
+
+	request, err := h.companyService.RejectJoinRequest(ctx, user.ID, scope.ID, int32(req.RequestId))
+	if err != nil {
+		return nil, joinRequestError(err, "failed to reject join request")
+	}
+
+	return &compiled.RejectJoinRequestResponse{JoinRequest: joinRequestToProto(request, "", "")}, nil
+}
+
+func joinRequestError(err error, internalMsg string) error {
+	switch {
+	case errors.Is(err, service.ErrNotAdmin):
+		return status.Error(codes.PermissionDenied, "only admins can review join requests")
+	case errors.Is(err, service.ErrJoinRequestNotFound):
+		return status.Error(codes.NotFound, "join request not found")
+	case errors.Is(err, service.ErrJoinRequestPending):
+		return status.Error(codes.AlreadyExists, "a join request for this company is already pending")
+	case errors.Is(err, service.ErrJoinRequestCooldown):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, service.ErrJoinRequestNotPending):
+		return status.Error(codes.FailedPrecondition, "join request is no longer pending")
+	case errors.Is(err, service.ErrUserAlreadyMember):
+		return status.Error(codes.AlreadyExists, "user is already a member of this company")
+	case errors.Is(err, service.ErrSeatLimitReached):
+		return status.Error(codes.FailedPrecondition, "the company has no seats left on its plan")
+	case errors.Is(err, service.ErrEmailDomainBlocked):
+		return status.Error(codes.PermissionDenied, "email domain is not allowed by the company settings")
+	case errors.Is(err, service.ErrCompanyNotFound):
+		return status.Error(codes.NotFound, "company not found")
+	case errors.Is(err, service.ErrCompanyArchived):
+		return status.Error(codes.FailedPrecondition, "company is archived")
+	default:
+		return internalError(err, internalMsg)
+	}
+}
+
+func joinRequestToProto(request *compiled.JoinRequest, name, email string) *compiled.JoinRequestInfo {
+	return &compiled.JoinRequestInfo{
+		Id:        int64(request.ID),
+		CompanyId: int64(request.CompanyID),
+		UserId:    int64(request.UserID),
+		Name:      name,
+		Email:     email,
+		Message:   request.Message,
+		Status:    request.Status,
+		CreatedAt: request.CreatedAt.Time.Format("2006-01-02T15:04:05Z"),
+	}
+}
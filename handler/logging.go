@@ -0,0 +1,110 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// internalErr is returned to the client as an Internal status with a generic
+// message, while the access log records the underlying cause.
+type internalErr struct {
+	cause error
+	msg   string
+}
+
+// internalError hides cause from the client behind msg.
+func internalError(cause error, msg string) error {
+	return &internalErr{cause: cause, msg: msg}
+}
+
+func (e *internalErr) Error() string {
+	if e.cause == nil {
+		return e.msg
+	}
+	return e.msg + ": " + e.cause.Error()
+}
+
+func (e *internalErr) Unwrap() error {
+	return e.cause
+}
+
+func (e *internalErr) GRPCStatus() *status.Status {
+	return status.New(codes.Internal, e.msg)
+}
+
+// requestLog collects the fields of an access log entry that are only known
+// once the request has been authenticated.
+type requestLog struct {
+	userID    int32
+	companyID int32
+}
+
+type requestLogKey struct{}
+
+// annotateRequestLog records the caller for the access log entry of ctx.
+func annotateRequestLog(ctx context.Context, userID, companyID int32) {
+	if entry, ok := ctx.Value(requestLogKey{}).(*requestLog); ok {
+		entry.userID = userID
+		entry.companyID = companyID
+	}
+}
+
+// AccessLogInterceptor logs one line per call with its method, status code,
+// latency, request ID and caller. Internal errors are logged with their
+// cause.
+func AccessLogInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		entry := &requestLog{}
+		start := time.Now()
+		resp, err := handler(context.WithValue(ctx, requestLogKey{}, entry), req)
+		logAccess(ctx, info.FullMethod, entry, start, err)
+		return resp, err
+	}
+}
+
+func StreamAccessLogInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx := ss.Context()
+		entry := &requestLog{}
+		start := time.Now()
+		err := handler(srv, &contextServerStream{ServerStream: ss, ctx: context.WithValue(ctx, requestLogKey{}, entry)})
+		logAccess(ctx, info.FullMethod, entry, start, err)
+		return err
+	}
+}
+
+func logAccess(ctx context.Context, method string, entry *requestLog, start time.Time, err error) {
+	code := status.Code(err)
+	attrs := []slog.Attr{
+		slog.String("method", method),
+		slog.String("code", code.String()),
+		slog.Duration("latency", time.Since(start)),
+		slog.String("request_id", RequestIDFromContext(ctx)),
+	}
+	if entry.userID != 0 {
+		attrs = append(attrs, slog.Int("user_id", int(entry.userID)))
+	}
+	if entry.companyID != 0 {
+		attrs = append(attrs, slog.Int("company_id", int(entry.companyID)))
+	}
+
+	level := slog.LevelInfo
+	switch code {
+	case codes.Internal, codes.Unknown, codes.DataLoss:
+		level = slog.LevelError
+		var ie *internalErr
+		if errors.As(err, &ie) && ie.cause != nil {
+			attrs = append(attrs, slog.Any("error", ie.cause))
+		} else if err != nil {
+			attrs = append(attrs, slog.Any("error", err))
+		}
+	}
+
+	slog.LogAttrs(ctx, level, "request", attrs...)
+}
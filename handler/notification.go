@@ -0,0 +1,150 @@
+package handler
+
+import (
+	"context"
+	"errors"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"project/compiled"
+	"project/service"
+)
+
+func (h *Handler) ListNotifications(ctx context.Context, req *compiled.ListNotificationsRequest) (*compiled.ListNotificationsResponse, error) {
+	user, ok := UserFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "unauthorized")
+	}
+
+	page, err := h.notificationService.ListNotifications(ctx, user.ID, service.NotificationListOptions{
+		PageSize:   req.PageSize,
+		PageToken:  req.PageToken,
+		UnreadOnly: req.UnreadOnly,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidPageToken),
+			errors.Is(err, service.ErrInvalidPageSize):
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		default:
+			return nil, internalError(err, "failed to list notifications")
+		}
+	}
+	unread, err := h.notificationService.UnreadCount(ctx, user.ID)
+	if err != nil {
+		return nil, internalError(err, "failed to list notifications")
+	}
+
+	notifications := make([]*compiled.NotificationInfo, 0, len(page.Notifications))
+	for _, n := range page.Notifications {
+		data := &structpb.Struct{}
+		if err := data.UnmarshalJSON(n.Data); err != nil {
+			return nil, internalError(err, "failed to list notifications")
+		}
+
+		notifications = append(notifications, &compiled.NotificationInfo{
+			Id:        n.ID,
+			Type:      n.Type,
+			CompanyId: int64(n.CompanyID.Int32),
+			Title:     n.Title,
+			Body:      n.Body,
+			Data:      data,
+			Read:      n.ReadAt.Valid,
+			CreatedAt: n.CreatedAt.Time.Format("2006-01-02T15:04:05Z"),
+		})
+	}
+
+	return &compiled.ListNotificationsResponse{
+		Notifications: notifications,
+		NextPageToken: page.NextPageToken,
+		UnreadCount:   unread,
+	}, nil
+}
+
+func (h *Handler) GetUnreadNotificationCount(ctx context.Context, req *compiled.GetUnreadNotificationCountRequest) (*compiled.GetUnreadNotificationCountResponse, error) {
+	user, ok := UserFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "unauthorized")
+	}
+
+	unread, err := h.notificationService.UnreadCount(ctx, user.ID)
+	if err != nil {
+		return nil, internalError(err, "failed to count notifications")
+	}
+
+	return &compiled.GetUnreadNotificationCountResponse{UnreadCount: unread}, nil
+}
+
+func (h *Handler) MarkNotificationsRead(ctx context.Context, req *compiled.MarkNotificationsReadRequest) (*compiled.MarkNotificationsReadResponse, error) {
+	user, ok := UserFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "unauthorized")
+	}
+	if !req.All && len(req.Ids) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "ids or all is required")
+	}
+
+	marked, err := h.notificationService.MarkRead(ctx, user.ID, req.Ids, req.All)
+	if err != nil {
+		return nil, internalError(err, "failed to mark notifications read")
+	}
+	unread, err := h.notificationService.UnreadCount(ctx, user.ID)
+	if err != nil {
+		return nil, internalError(err, "failed to mark notifications read")
+	}
+
+	return &compiled.MarkNotificationsReadResponse{
+		Marked:      marked,
+		UnreadCount: unread,
+	}, nil
+}
+
+func (h *Handler) GetNotificationPreferences(ctx context.Context, req *compiled.GetNotificationPreferencesRequest) (*compiled.GetNotificationPreferencesResponse, error) {
+	user, ok := UserFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "unauthorized")
+	}
+
+	prefs, err := h.notificationService.Preferences(ctx, user.ID)
+	if err != nil {
+		return nil, internalError(err, "failed to get notification preferences")
+	}
+
+	return &compiled.GetNotificationPreferencesResponse{Preferences: notificationPreferencesToProto(prefs)}, nil
+}
+
+func (h *Handler) UpdateNotificationPreferences(ctx context.Context, req *compiled.UpdateNotificationPreferencesRequest) (*compiled.UpdateNotificationPreferencesResponse, error) {
+	user, ok := UserFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "unauthorized")
+	}
+
+	updates := make([]service.NotificationPreference, 0, len(req.Preferences))
+	for _, p := range req.Preferences {
+		updates = append(updates, service.NotificationPreference{Type: p.Type, InApp: p.InApp, Email: p.Email})
+	}
+
+	prefs, err := h.notificationService.UpdatePreferences(ctx, user.ID, updates)
+	if err != nil {
+		if errors.Is(err, service.ErrNotificationTypeUnknown) {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		return nil, internalError(err, "failed to update notification preferences")
+	}
+
+	return &compiled.UpdateNotificationPreferencesResponse{Preferences: notificationPreferencesToProto(prefs)}, nil
+}
+
+func notificationPreferencesToProto(prefs []service.NotificationPreference) []*compiled.NotificationPreferenceInfo {
+	result := make([]*compiled.NotificationPreferenceInfo, 0, len(prefs))
+	for _, p := range prefs {
+		result = append(result, &compiled.NotificationPreferenceInfo{
+			Type:  p.Type,
+			InApp: p.InApp,
+			Email: p.Email,
+		})
+	}
+	return result
+}
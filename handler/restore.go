@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"context"
+	"errors"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"project/compiled"
+	"project/service"
+)
+
+func (h *Handler) ListRemovedCompanyMembers(ctx context.Context, req *compiled.ListRemovedCompanyMembersRequest) (*compiled.ListRemovedCompanyMembersResponse, error) {
+	user, ok := UserFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "unauthorized")
+	}
+	scope, ok := CompanyFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.FailedPrecondition, "no company selected")
+	}
+
+	members, err := h.companyService.ListRemovedMembers(ctx, user.ID, scope.ID)
+	if err != nil {
+		return nil, companyError(err, "failed to list removed members")
+	}
+
+	result := make([]*compiled.RemovedMemberInfo, 0, len(members))
+	for _, m := range members {
+		result = append(result, &compiled.RemovedMemberInfo{
+			UserId:    int64(m.ID),
+			Name:      m.Name,
+			Email:     m.Email,
+			Role:      m.Role,
+			RemovedAt: m.RemovedAt.Time.Format("2006-01-02T15:04:05Z"),
+		})
+	}
+
+	return &compiled.ListRemovedCompanyMembersResponse{Members: result}, nil
+}
+
+func (h *Handler) RestoreCompanyMember(ctx context.Context, req *compiled.RestoreCompanyMemberRequest) (*compiled.RestoreCompanyMemberResponse, error) {
+	user, ok := UserFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "unauthorized")
+	}
+	scope, ok := CompanyFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.FailedPrecondition, "no company selected")
+	}
+	if req.UserId == 0 {
+		return nil, status.Error(codes.InvalidArgument, "user_id is required")
+	}
+
+	role, err := h.companyService.RestoreMember(ctx, user.ID, scope.ID, int32(req.UserId))
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrRemovedMemberNotFound):
+			return nil, status.Error(codes.NotFound, "no restorable removed member found")
+		case errors.Is(err, service.ErrSeatLimitReached):
+			return nil, status.Error(codes.FailedPrecondition, "the company has no seats left on its plan")
+		default:
+			return nil, companyError(err, "failed to restore member")
+		}
+	}
+
+	return &compiled.RestoreCompanyMemberResponse{Success: true, Role: role}, nil
+}
+
+func (h *Handler) ListDeletedCompanies(ctx context.Context, req *compiled.ListDeletedCompaniesRequest) (*compiled.ListDeletedCompaniesResponse, error) {
+	user, ok := UserFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "unauthorized")
+	}
+
+	companies, err := h.companyService.ListDeletedCompanies(ctx, user.ID)
+	if err != nil {
+		return nil, internalError(err, "failed to list deleted companies")
+	}
+
+	result := make([]*compiled.DeletedCompanyInfo, 0, len(companies))
+	for _, c := range companies {
+		result = append(result, &compiled.DeletedCompanyInfo{
+			Id:        int64(c.ID),
+			Name:      c.CompanyName,
+			CreatedAt: c.CreatedAt.Time.Format("2006-01-02T15:04:05Z"),
+			DeletedAt: c.DeletedAt.Time.Format("2006-01-02T15:04:05Z"),
+		})
+	}
+
+	return &compiled.ListDeletedCompaniesResponse{Companies: result}, nil
+}
+
+func (h *Handler) RestoreCompany(ctx context.Context, req *compiled.RestoreCompanyRequest) (*compiled.RestoreCompanyResponse, error) {
+	user, ok := UserFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "unauthorized")
+	}
+	if req.CompanyId == 0 {
+		return nil, status.Error(codes.InvalidArgument, "company_id is required")
+	}
+
+	if _, err := h.companyService.RestoreCompany(ctx, user.ID, int32(req.CompanyId)); err != nil {
+		if errors.Is(err, service.ErrDeletedCompanyNotFound) {
+			return nil, status.Error(codes.NotFound, "no restorable deleted company found")
+		}
+		return nil, internalError(err, "failed to restore company")
+	}
+
+	return &compiled.RestoreCompanyResponse{Success: true}, nil
+}
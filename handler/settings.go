@@ -0,0 +1,91 @@
+package handler
+
+import (
+	"context"
+	"errors"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"project/compiled"
+	"project/service"
+)
+
+func (h *Handler) GetCompanySettings(ctx context.Context, req *compiled.GetCompanySettingsRequest) (*compiled.GetCompanySettingsResponse, error) {
+	if _, ok := UserFromContext(ctx); !ok {
+		return nil, status.Error(codes.Unauthenticated, "unauthorized")
+	}
+	scope, ok := CompanyFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.FailedPrecondition, "no company selected")
+	}
+	if scope.Role != "admin" {
+		return nil, status.Error(codes.PermissionDenied, "only admins can view company settings")
+	}
+
+	settings, err := h.companyService.CompanySettings(ctx, scope.ID)
+	if err != nil {
+		return nil, internalError(err, "failed to get company settings")
+	}
+
+	return &compiled.GetCompanySettingsResponse{Settings: settingsToProto(&settings)}, nil
+}
+
+func (h *Handler) UpdateCompanySettings(ctx context.Context, req *compiled.UpdateCompanySettingsRequest) (*compiled.UpdateCompanySettingsResponse, error) {
+	user, ok := UserFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "unauthorized")
+	}
+	scope, ok := CompanyFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.FailedPrecondition, "no company selected")
+	}
+	if len(req.UpdateMask.GetPaths()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "update_mask is required")
+	}
+
+	update := settingsFromProto(req.Settings)
+	settings, err := h.companyService.UpdateCompanySettings(ctx, user.ID, scope.ID, update, req.UpdateMask.GetPaths())
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrUnknownSetting):
+			return nil, status.Error(codes.InvalidArgument, "update_mask names an unknown setting")
+		case errors.Is(err, service.ErrInvalidRole):
+			return nil, status.Error(codes.InvalidArgument, "default_invite_role must be 'admin' or 'member'")
+		case errors.Is(err, service.ErrInvalidSetting):
+			return nil, status.Error(codes.InvalidArgument, "invalid setting value")
+		case errors.Is(err, service.ErrTeamNotFound):
+			return nil, status.Error(codes.InvalidArgument, "inviter_team_ids names an unknown team")
+		default:
+			return nil, companyError(err, "failed to update company settings")
+		}
+	}
+
+	return &compiled.UpdateCompanySettingsResponse{Settings: settingsToProto(&settings)}, nil
+}
+
+func settingsToProto(settings *service.CompanySettings) *compiled.CompanySettings {
+	return &compiled.CompanySettings{
+		DefaultInviteRole:   settings.DefaultInviteRole,
+		AllowedEmailDomains: settings.AllowedEmailDomains,
+		RequireTwoFactor:    settings.RequireTwoFactor,
+		Branding: &compiled.CompanyBranding{
+			LogoUrl:      settings.Branding.LogoURL,
+			PrimaryColor: settings.Branding.PrimaryColor,
+		},
+		InviterTeamIds: settings.InviterTeamIDs,
+	}
+}
+
+func settingsFromProto(settings *compiled.CompanySettings) service.CompanySettings {
+	return service.CompanySettings{
+		DefaultInviteRole:   settings.GetDefaultInviteRole(),
+		AllowedEmailDomains: settings.GetAllowedEmailDomains(),
+		RequireTwoFactor:    settings.GetRequireTwoFactor(),
+		Branding: service.CompanyBranding{
+			LogoURL:      settings.GetBranding().GetLogoUrl(),
+			PrimaryColor: settings.GetBranding().GetPrimaryColor(),
+		},
+		InviterTeamIDs: settings.GetInviterTeamIds(),
+	}
+}
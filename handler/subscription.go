@@ -0,0 +1,103 @@
+package handler
+
+import (
+	"context"
+	"errors"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"project/compiled"
+	"project/service"
+)
+
+func (h *Handler) ListPlans(ctx context.Context, req *compiled.ListPlansRequest) (*compiled.ListPlansResponse, error) {
+	if _, ok := UserFromContext(ctx); !ok {
+		return nil, status.Error(codes.Unauthenticated, "unauthorized")
+	}
+
+	plans, err := h.companyService.ListPlans(ctx)
+	if err != nil {
+		return nil, internalError(err, "failed to list plans")
+	}
+
+	result := make([]*compiled.PlanInfo, 0, len(plans))
+	for i := range plans {
+		result = append(result, planToProto(&plans[i]))
+	}
+
+	return &compiled.ListPlansResponse{Plans: result}, nil
+}
+
+func (h *Handler) GetCompanySubscription(ctx context.Context, req *compiled.GetCompanySubscriptionRequest) (*compiled.GetCompanySubscriptionResponse, error) {
+	if _, ok := UserFromContext(ctx); !ok {
+		return nil, status.Error(codes.Unauthenticated, "unauthorized")
+	}
+	scope, ok := CompanyFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.FailedPrecondition, "no company selected")
+	}
+
+	sub, err := h.companyService.GetCompanySubscription(ctx, scope.ID)
+	if err != nil {
+		return nil, internalError(err, "failed to get subscription")
+	}
+
+	return &compiled.GetCompanySubscriptionResponse{Subscription: subscriptionToProto(sub)}, nil
+}
+
+func (h *Handler) ChangeCompanyPlan(ctx context.Context, req *compiled.ChangeCompanyPlanRequest) (*compiled.ChangeCompanyPlanResponse, error) {
+	user, ok := UserFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "unauthorized")
+	}
+	scope, ok := CompanyFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.FailedPrecondition, "no company selected")
+	}
+	if req.PlanCode == "" {
+		return nil, status.Error(codes.InvalidArgument, "plan_code is required")
+	}
+
+	sub, err := h.companyService.ChangeCompanyPlan(ctx, user.ID, scope.ID, user.Email, req.PlanCode)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrPlanNotFound):
+			return nil, status.Error(codes.NotFound, "plan not found")
+		case errors.Is(err, service.ErrSeatLimitExceeded):
+			return nil, status.Error(codes.FailedPrecondition, "the company has more seats in use than the plan allows")
+		case errors.Is(err, service.ErrPaymentFailed):
+			return nil, status.Error(codes.FailedPrecondition, "payment was declined")
+		case errors.Is(err, service.ErrBillingDisabled):
+			return nil, status.Error(codes.FailedPrecondition, err.Error())
+		default:
+			return nil, companyError(err, "failed to change plan")
+		}
+	}
+
+	return &compiled.ChangeCompanyPlanResponse{Subscription: subscriptionToProto(sub)}, nil
+}
+
+func planToProto(plan *compiled.Plan) *compiled.PlanInfo {
+	return &compiled.PlanInfo{
+		Code:       plan.Code,
+		Name:       plan.Name,
+		SeatLimit:  plan.SeatLimit.Int32,
+		Features:   plan.Features,
+		PriceCents: int64(plan.PriceCents),
+	}
+}
+
+func subscriptionToProto(sub *service.CompanySubscription) *compiled.SubscriptionInfo {
+	var periodEnd string
+	if sub.CurrentPeriodEnd.Valid {
+		periodEnd = sub.CurrentPeriodEnd.Time.Format("2006-01-02T15:04:05Z")
+	}
+
+	return &compiled.SubscriptionInfo{
+		Plan:             planToProto(&sub.Plan),
+		Status:           sub.Status,
+		SeatsUsed:        sub.SeatsUsed,
+		CurrentPeriodEnd: periodEnd,
+	}
+}
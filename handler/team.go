@@ -0,0 +1,201 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"project/compiled"
+	"project/service"
+)
+
+func (h *Handler) CreateTeam(ctx context.Context, req *compiled.CreateTeamRequest) (*compiled.CreateTeamResponse, error) {
+	user, ok := UserFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "unauthorized")
+	}
+	scope, ok := CompanyFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.FailedPrecondition, "no company selected")
+	}
+	if req.Name == "" {
+		return nil, status.Error(codes.InvalidArgument, "name is required")
+	}
+
+	team, err := h.companyService.CreateTeam(ctx, user.ID, scope.ID, req.Name, int32(req.ParentTeamId))
+	if err != nil {
+		return nil, teamError(err, "failed to create team")
+	}
+
+	return &compiled.CreateTeamResponse{Team: teamToProto(team.ID, team.Name, team.ParentTeamID.Int32, 0, team.CreatedAt.Time)}, nil
+}
+
+func (h *Handler) UpdateTeam(ctx context.Context, req *compiled.UpdateTeamRequest) (*compiled.UpdateTeamResponse, error) {
+	user, ok := UserFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "unauthorized")
+	}
+	scope, ok := CompanyFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.FailedPrecondition, "no company selected")
+	}
+	if req.TeamId == 0 {
+		return nil, status.Error(codes.InvalidArgument, "team_id is required")
+	}
+	if req.Name == "" {
+		return nil, status.Error(codes.InvalidArgument, "name is required")
+	}
+
+	team, err := h.companyService.UpdateTeam(ctx, user.ID, scope.ID, int32(req.TeamId), req.Name, int32(req.ParentTeamId))
+	if err != nil {
+		return nil, teamError(err, "failed to update team")
+	}
+
+	return &compiled.UpdateTeamResponse{Team: teamToProto(team.ID, team.Name, team.ParentTeamID.Int32, 0, team.CreatedAt.Time)}, nil
+}
+
+func (h *Handler) DeleteTeam(ctx context.Context, req *compiled.DeleteTeamRequest) (*compiled.DeleteTeamResponse, error) {
+	user, ok := UserFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "unauthorized")
+	}
+	scope, ok := CompanyFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.FailedPrecondition, "no company selected")
+	}
+	if req.TeamId == 0 {
+		return nil, status.Error(codes.InvalidArgument, "team_id is required")
+	}
+
+	if err := h.companyService.DeleteTeam(ctx, user.ID, scope.ID, int32(req.TeamId)); err != nil {
+		return nil, teamError(err, "failed to delete team")
+	}
+
+	return &compiled.DeleteTeamResponse{Success: true}, nil
+}
+
+func (h *Handler) ListTeams(ctx context.Context, req *compiled.ListTeamsRequest) (*compiled.ListTeamsResponse, error) {
+	if _, ok := UserFromContext(ctx); !ok {
+		return nil, status.Error(codes.Unauthenticated, "unauthorized")
+	}
+	scope, ok := CompanyFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.FailedPrecondition, "no company selected")
+	}
+
+	teams, err := h.companyService.ListTeams(ctx, scope.ID)
+	if err != nil {
+		return nil, internalError(err, "failed to list teams")
+	}
+
+	result := make([]*compiled.TeamInfo, 0, len(teams))
+	for _, t := range teams {
+		result = append(result, teamToProto(t.ID, t.Name, t.ParentTeamID.Int32, t.MemberCount, t.CreatedAt.Time))
+	}
+
+	return &compiled.ListTeamsResponse{Teams: result}, nil
+}
+
+func (h *Handler) AddTeamMember(ctx context.Context, req *compiled.AddTeamMemberRequest) (*compiled.AddTeamMemberResponse, error) {
+	user, ok := UserFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "unauthorized")
+	}
+	scope, ok := CompanyFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.FailedPrecondition, "no company selected")
+	}
+	if req.TeamId == 0 || req.UserId == 0 {
+		return nil, status.Error(codes.InvalidArgument, "team_id and user_id are required")
+	}
+
+	if err := h.companyService.AddTeamMember(ctx, user.ID, scope.ID, int32(req.TeamId), int32(req.UserId)); err != nil {
+		return nil, teamError(err, "failed to add team member")
+	}
+
+	return &compiled.AddTeamMemberResponse{Success: true}, nil
+}
+
+func (h *Handler) RemoveTeamMember(ctx context.Context, req *compiled.RemoveTeamMemberRequest) (*compiled.RemoveTeamMemberResponse, error) {
+	user, ok := UserFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "unauthorized")
+	}
+	scope, ok := CompanyFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.FailedPrecondition, "no company selected")
+	}
+	if req.TeamId == 0 || req.UserId == 0 {
+		return nil, status.Error(codes.InvalidArgument, "team_id and user_id are required")
+	}
+
+	if err := h.companyService.RemoveTeamMember(ctx, user.ID, scope.ID, int32(req.TeamId), int32(req.UserId)); err != nil {
+		return nil, teamError(err, "failed to remove team member")
+	}
+
+	return &compiled.RemoveTeamMemberResponse{Success: true}, nil
+}
+
+func (h *Handler) ListUserTeams(ctx context.Context, req *compiled.ListUserTeamsRequest) (*compiled.ListUserTeamsResponse, error) {
+	user, ok := UserFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "unauthorized")
+	}
+	scope, ok := CompanyFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.FailedPrecondition, "no company selected")
+	}
+
+	userID := user.ID
+	if req.UserId != 0 {
+		userID = int32(req.UserId)
+	}
+
+	teams, err := h.companyService.ListUserTeams(ctx, scope.ID, userID)
+	if err != nil {
+		return nil, internalError(err, "failed to list teams")
+	}
+
+	result := make([]*compiled.TeamInfo, 0, len(teams))
+	for _, t := range teams {
+		result = append(result, teamToProto(t.ID, t.Name, t.ParentTeamID.Int32, 0, t.CreatedAt.Time))
+	}
+
+	return &compiled.ListUserTeamsResponse{Teams: result}, nil
+}
+
+func teamToProto(id int32, name string, parentTeamID int32, memberCount int64, createdAt time.Time) *compiled.TeamInfo {
+	return &compiled.TeamInfo{
+		Id:           int64(id),
+		Name:         name,
+		ParentTeamId: int64(parentTeamID),
+		MemberCount:  memberCount,
+		CreatedAt:    createdAt.Format("2006-01-02T15:04:05Z"),
+	}
+}
+
+func teamError(err error, internalMsg string) error {
+	switch {
+	case errors.Is(err, service.ErrNotAdmin):
+		return status.Error(codes.PermissionDenied, "only admins can manage teams")
+	case errors.Is(err, service.ErrCompanyArchived):
+		return status.Error(codes.FailedPrecondition, "company is archived")
+	case errors.Is(err, service.ErrFeatureNotInPlan):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, service.ErrTeamNotFound):
+		return status.Error(codes.NotFound, "team not found")
+	case errors.Is(err, service.ErrTeamNameTaken):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, service.ErrTeamCycle), errors.Is(err, service.ErrTeamHasChildren):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, service.ErrNotTeamMember):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, service.ErrTeamMemberNotInOrg):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	default:
+		return internalError(err, internalMsg)
+	}
+}
@@ -0,0 +1,68 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-migrate/migrate/v4"
+	_ "github.com/golang-migrate/migrate/v4/database/postgres"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"project/compiled"
+	"project/database/migration"
+	"project/service"
+)
+
+// Tests that need Postgres run against TEST_DATABASE_URL and are skipped
+// without it, as in the service package.
+var (
+	migrateOnce sync.Once
+	migrateErr  error
+)
+
+func testDB(t *testing.T) (*pgxpool.Pool, *compiled.Queries) {
+	t.Helper()
+
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	migrateOnce.Do(func() {
+		src, err := iofs.New(migration.FS, "sql")
+		if err != nil {
+			migrateErr = err
+			return
+		}
+		m, err := migrate.NewWithSourceInstance("iofs", src, url)
+		if err != nil {
+			migrateErr = err
+			return
+		}
+		if err := m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+			migrateErr = err
+		}
+	})
+	if migrateErr != nil {
+		t.Fatalf("migrate test database: %v", migrateErr)
+	}
+
+	pool, err := pgxpool.New(context.Background(), url)
+	if err != nil {
+		t.Fatalf("open test database: %v", err)
+	}
+	t.Cleanup(pool.Close)
+	return pool, compiled.New(pool)
+}
+
+// newTestHandler returns a Handler backed by the test database, with only
+// the services that authorization needs.
+func newTestHandler(pool *pgxpool.Pool, queries *compiled.Queries) *Handler {
+	companies := service.NewCompanyService(pool, queries, service.NewMailer(""), nil, nil, "http://app.test", 7*24*time.Hour, 30*24*time.Hour)
+	return NewHandler(nil, companies, nil, nil, nil, nil, nil, nil, queries, nil)
+}
@@ -2,11 +2,14 @@ package handler
 
 import (
 	"context"
+	"errors"
+	"strings"
 
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 
 	"project/compiled"
+	"project/service"
 )
 
 func (h *Handler) UpdateProfile(ctx context.Context, req *compiled.UpdateProfileRequest) (*compiled.UpdateProfileResponse, error) {
@@ -24,7 +27,7 @@ func (h *Handler) UpdateProfile(ctx context.Context, req *compiled.UpdateProfile
 		ID:   user.ID,
 	})
 	if err != nil {
-		return nil, status.Error(codes.Internal, "failed to update profile")
+		return nil, internalError(err, "failed to update profile")
 	}
 
 	user.Name = req.Name
@@ -42,13 +45,13 @@ func (h *Handler) GetProfile(ctx context.Context, req *compiled.GetProfileReques
 	// Get user companies
 	companies, err := h.companyService.GetUserCompanies(ctx, user.ID)
 	if err != nil {
-		return nil, status.Error(codes.Internal, "failed to get user companies")
+		return nil, internalError(err, "failed to get user companies")
 	}
 
 	// Check if user is an owner of any company
 	isOwner, err := h.companyService.IsUserOwner(ctx, user.ID)
 	if err != nil {
-		return nil, status.Error(codes.Internal, "failed to check company ownership")
+		return nil, internalError(err, "failed to check company ownership")
 	}
 
 	// Build company info list
@@ -57,15 +60,41 @@ func (h *Handler) GetProfile(ctx context.Context, req *compiled.GetProfileReques
 
 	for _, c := range companies {
 		info := &compiled.CompanyInfo{
-			Id:        int64(c.ID),
-			Name:      c.CompanyName,
-			Role:      c.Role,
-			IsOwner:   c.OwnerID == user.ID,
-			CreatedAt: c.CreatedAt.Time.Format("2006-01-02T15:04:05Z"),
+			Id:              int64(c.ID),
+			Name:            c.CompanyName,
+			Role:            c.Role,
+			IsOwner:         c.OwnerID == user.ID,
+			CreatedAt:       c.CreatedAt.Time.Format("2006-01-02T15:04:05Z"),
+			Archived:        c.ArchivedAt.Valid,
+			ParentCompanyId: int64(c.ParentCompanyID.Int32),
 		}
 		companyInfoList = append(companyInfoList, info)
 
-		if user.SelectedCompanyID != 0 && c.ID == user.SelectedCompanyID {
+		if scope, ok := CompanyFromContext(ctx); ok && c.ID == scope.ID {
+			selectedCompany = info
+		}
+	}
+
+	// Companies administered through a parent company
+	inherited, err := h.companyService.GetInheritedCompanies(ctx, user.ID)
+	if err != nil {
+		return nil, internalError(err, "failed to get user companies")
+	}
+
+	for _, c := range inherited {
+		info := &compiled.CompanyInfo{
+			Id:              int64(c.ID),
+			Name:            c.CompanyName,
+			Role:            "admin",
+			IsOwner:         c.OwnerID == user.ID,
+			CreatedAt:       c.CreatedAt.Time.Format("2006-01-02T15:04:05Z"),
+			Archived:        c.ArchivedAt.Valid,
+			ParentCompanyId: int64(c.ParentCompanyID.Int32),
+			Inherited:       true,
+		}
+		companyInfoList = append(companyInfoList, info)
+
+		if scope, ok := CompanyFromContext(ctx); ok && c.ID == scope.ID {
 			selectedCompany = info
 		}
 	}
@@ -80,3 +109,25 @@ func (h *Handler) GetProfile(ctx context.Context, req *compiled.GetProfileReques
 		IsOwner:         isOwner,
 	}, nil
 }
+
+func (h *Handler) DeleteAccount(ctx context.Context, req *compiled.DeleteAccountRequest) (*compiled.DeleteAccountResponse, error) {
+	user, ok := UserFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "unauthorized")
+	}
+
+	if !strings.EqualFold(req.ConfirmEmail, user.Email) {
+		return nil, status.Error(codes.InvalidArgument, "confirm_email must match the account email")
+	}
+
+	if err := h.accountService.DeleteAccount(ctx, user.ID); err != nil {
+		if errors.Is(err, service.ErrOwnsCompanies) {
+			return nil, status.Error(codes.FailedPrecondition, "transfer or delete the companies you own first")
+		}
+		return nil, internalError(err, "failed to delete account")
+	}
+
+	h.cacheDeleteByUserID(user.ID)
+
+	return &compiled.DeleteAccountResponse{Success: true}, nil
+}
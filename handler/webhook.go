@@ -0,0 +1,201 @@
+package handler
+
+import (
+	"context"
+	"errors"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"project/compiled"
+	"project/service"
+)
+
+func (h *Handler) CreateWebhook(ctx context.Context, req *compiled.CreateWebhookRequest) (*compiled.CreateWebhookResponse, error) {
+	user, ok := UserFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "unauthorized")
+	}
+	scope, ok := CompanyFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.FailedPrecondition, "no company selected")
+	}
+	if req.Url == "" {
+		return nil, status.Error(codes.InvalidArgument, "url is required")
+	}
+
+	endpoint, err := h.webhookService.CreateWebhook(ctx, user.ID, scope.ID, req.Url, req.EventTypes)
+	if err != nil {
+		return nil, webhookError(err, "failed to create webhook")
+	}
+
+	return &compiled.CreateWebhookResponse{
+		Webhook: webhookToProto(endpoint),
+		Secret:  endpoint.Secret,
+	}, nil
+}
+
+func (h *Handler) ListWebhooks(ctx context.Context, req *compiled.ListWebhooksRequest) (*compiled.ListWebhooksResponse, error) {
+	user, ok := UserFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "unauthorized")
+	}
+	scope, ok := CompanyFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.FailedPrecondition, "no company selected")
+	}
+
+	endpoints, err := h.webhookService.ListWebhooks(ctx, user.ID, scope.ID)
+	if err != nil {
+		return nil, webhookError(err, "failed to list webhooks")
+	}
+
+	result := make([]*compiled.WebhookInfo, 0, len(endpoints))
+	for i := range endpoints {
+		result = append(result, webhookToProto(&endpoints[i]))
+	}
+
+	return &compiled.ListWebhooksResponse{Webhooks: result}, nil
+}
+
+func (h *Handler) UpdateWebhook(ctx context.Context, req *compiled.UpdateWebhookRequest) (*compiled.UpdateWebhookResponse, error) {
+	user, ok := UserFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "unauthorized")
+	}
+	scope, ok := CompanyFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.FailedPrecondition, "no company selected")
+	}
+	if req.WebhookId == 0 {
+		return nil, status.Error(codes.InvalidArgument, "webhook_id is required")
+	}
+	if req.Url == "" {
+		return nil, status.Error(codes.InvalidArgument, "url is required")
+	}
+
+	endpoint, err := h.webhookService.UpdateWebhook(ctx, user.ID, scope.ID, int32(req.WebhookId), req.Url, req.EventTypes, req.Enabled)
+	if err != nil {
+		return nil, webhookError(err, "failed to update webhook")
+	}
+
+	return &compiled.UpdateWebhookResponse{Webhook: webhookToProto(endpoint)}, nil
+}
+
+func (h *Handler) DeleteWebhook(ctx context.Context, req *compiled.DeleteWebhookRequest) (*compiled.DeleteWebhookResponse, error) {
+	user, ok := UserFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "unauthorized")
+	}
+	scope, ok := CompanyFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.FailedPrecondition, "no company selected")
+	}
+	if req.WebhookId == 0 {
+		return nil, status.Error(codes.InvalidArgument, "webhook_id is required")
+	}
+
+	if err := h.webhookService.DeleteWebhook(ctx, user.ID, scope.ID, int32(req.WebhookId)); err != nil {
+		return nil, webhookError(err, "failed to delete webhook")
+	}
+
+	return &compiled.DeleteWebhookResponse{Success: true}, nil
+}
+
+func (h *Handler) ListWebhookDeliveries(ctx context.Context, req *compiled.ListWebhookDeliveriesRequest) (*compiled.ListWebhookDeliveriesResponse, error) {
+	user, ok := UserFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "unauthorized")
+	}
+	scope, ok := CompanyFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.FailedPrecondition, "no company selected")
+	}
+	if req.WebhookId == 0 {
+		return nil, status.Error(codes.InvalidArgument, "webhook_id is required")
+	}
+
+	deliveries, err := h.webhookService.ListDeliveries(ctx, user.ID, scope.ID, int32(req.WebhookId))
+	if err != nil {
+		return nil, webhookError(err, "failed to list webhook deliveries")
+	}
+
+	result := make([]*compiled.WebhookDeliveryInfo, 0, len(deliveries))
+	for i := range deliveries {
+		result = append(result, webhookDeliveryToProto(&deliveries[i]))
+	}
+
+	return &compiled.ListWebhookDeliveriesResponse{Deliveries: result}, nil
+}
+
+func (h *Handler) SendTestWebhook(ctx context.Context, req *compiled.SendTestWebhookRequest) (*compiled.SendTestWebhookResponse, error) {
+	user, ok := UserFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "unauthorized")
+	}
+	scope, ok := CompanyFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.FailedPrecondition, "no company selected")
+	}
+	if req.WebhookId == 0 {
+		return nil, status.Error(codes.InvalidArgument, "webhook_id is required")
+	}
+
+	delivery, err := h.webhookService.SendTestEvent(ctx, user.ID, scope.ID, int32(req.WebhookId))
+	if err != nil {
+		return nil, webhookError(err, "failed to send test webhook")
+	}
+
+	return &compiled.SendTestWebhookResponse{Delivery: webhookDeliveryToProto(delivery)}, nil
+}
+
+func webhookError(err error, internalMsg string) error {
+	switch {
+	case errors.Is(err, service.ErrNotAdmin):
+		return status.Error(codes.PermissionDenied, "only admins can manage webhooks")
+	case errors.Is(err, service.ErrWebhookNotFound):
+		return status.Error(codes.NotFound, "webhook not found")
+	case errors.Is(err, service.ErrWebhookURLInvalid),
+		errors.Is(err, service.ErrWebhookURLNotPublic),
+		errors.Is(err, service.ErrWebhookEventUnknown):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, service.ErrCompanyArchived):
+		return status.Error(codes.FailedPrecondition, "company is archived")
+	default:
+		return internalError(err, internalMsg)
+	}
+}
+
+func webhookToProto(endpoint *compiled.WebhookEndpoint) *compiled.WebhookInfo {
+	return &compiled.WebhookInfo{
+		Id:         int64(endpoint.ID),
+		Url:        endpoint.Url,
+		EventTypes: endpoint.EventTypes,
+		Enabled:    endpoint.Enabled,
+		CreatedAt:  endpoint.CreatedAt.Time.Format("2006-01-02T15:04:05Z"),
+		UpdatedAt:  endpoint.UpdatedAt.Time.Format("2006-01-02T15:04:05Z"),
+	}
+}
+
+func webhookDeliveryToProto(delivery *compiled.WebhookDelivery) *compiled.WebhookDeliveryInfo {
+	info := &compiled.WebhookDeliveryInfo{
+		Id:             int64(delivery.ID),
+		EventId:        delivery.EventID,
+		EventType:      delivery.EventType,
+		Status:         delivery.Status,
+		Attempts:       delivery.Attempts,
+		ResponseStatus: delivery.ResponseStatus.Int32,
+		LastError:      delivery.LastError.String,
+		CreatedAt:      delivery.CreatedAt.Time.Format("2006-01-02T15:04:05Z"),
+	}
+	if delivery.LastAttemptAt.Valid {
+		info.LastAttemptAt = delivery.LastAttemptAt.Time.Format("2006-01-02T15:04:05Z")
+	}
+	if delivery.Status == service.WebhookDeliveryPending {
+		info.NextAttemptAt = delivery.NextAttemptAt.Time.Format("2006-01-02T15:04:05Z")
+	}
+	if delivery.CompletedAt.Valid {
+		info.CompletedAt = delivery.CompletedAt.Time.Format("2006-01-02T15:04:05Z")
+	}
+	return info
+}
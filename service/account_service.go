@@ -0,0 +1,164 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"project/compiled"
+)
+
+const retentionInterval = time.Hour
+
+var ErrOwnsCompanies = errors.New("user still owns companies")
+
+// AccountService handles account deletion and the retention job that
+// anonymizes and finally purges deleted data.
+type AccountService struct {
+	pool           *pgxpool.Pool
+	queries        *compiled.Queries
+	anonymizeAfter time.Duration
+	purgeAfter     time.Duration
+	auditRetention time.Duration
+}
+
+// NewAccountService anonymizes deleted users after anonymizeAfter,
+// hard-deletes soft-deleted users, companies and memberships after
+// purgeAfter, and drops audit events older than auditRetention.
+func NewAccountService(pool *pgxpool.Pool, queries *compiled.Queries, anonymizeAfter, purgeAfter, auditRetention time.Duration) *AccountService {
+	return &AccountService{
+		pool:           pool,
+		queries:        queries,
+		anonymizeAfter: anonymizeAfter,
+		purgeAfter:     purgeAfter,
+		auditRetention: auditRetention,
+	}
+}
+
+// DeleteAccount soft-deletes the user, ends their session and memberships.
+// Owners must transfer or delete their companies first.
+func (s *AccountService) DeleteAccount(ctx context.Context, userID int32) error {
+	return runInTx(ctx, s.pool, s.queries, func(q *compiled.Queries) error {
+		owns, err := q.IsUserCompanyOwner(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if owns {
+			return ErrOwnsCompanies
+		}
+
+		user, err := q.GetUserByID(ctx, userID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrUserNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		rows, err := q.SoftDeleteUser(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if rows == 0 {
+			return ErrUserNotFound
+		}
+
+		if err := q.RemoveUserFromAllTeams(ctx, userID); err != nil {
+			return err
+		}
+		if err := q.DeletePendingJoinRequestsByUser(ctx, userID); err != nil {
+			return err
+		}
+		if err := q.DeleteUserNotifications(ctx, userID); err != nil {
+			return err
+		}
+
+		if err := recordEvent(ctx, q, AccountDeleted{UserID: user.ID}); err != nil {
+			return err
+		}
+
+		memberships, err := q.SoftDeleteUserMemberships(ctx, userID)
+		if err != nil {
+			return err
+		}
+		for _, m := range memberships {
+			if err := recordEvent(ctx, q, MemberRemoved{
+				CompanyID: m.CompanyID,
+				UserID:    user.ID,
+				Email:     user.Email,
+				Name:      user.Name,
+				Role:      m.Role,
+				RemovedBy: user.ID,
+				Reason:    MemberRemovedReasonAccountDeleted,
+			}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Run applies the retention policy every hour until ctx is cancelled.
+func (s *AccountService) Run(ctx context.Context) {
+	ticker := time.NewTicker(retentionInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.ApplyRetention(ctx); err != nil && ctx.Err() == nil {
+			slog.ErrorContext(ctx, "Retention run failed", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ApplyRetention anonymizes users past the grace period, then purges
+// companies, memberships and users past retention, and expired audit events
+// and notifications. Companies go first so their owners can be purged in the
+// same run.
+func (s *AccountService) ApplyRetention(ctx context.Context) error {
+	now := time.Now()
+
+	anonymized, err := s.queries.AnonymizeDeletedUsers(ctx, pgtype.Timestamp{Time: now.Add(-s.anonymizeAfter), Valid: true})
+	if err != nil {
+		return err
+	}
+
+	cutoff := pgtype.Timestamp{Time: now.Add(-s.purgeAfter), Valid: true}
+	companies, err := s.queries.PurgeDeletedCompanies(ctx, cutoff)
+	if err != nil {
+		return err
+	}
+	memberships, err := s.queries.PurgeDeletedMemberships(ctx, cutoff)
+	if err != nil {
+		return err
+	}
+	users, err := s.queries.PurgeDeletedUsers(ctx, cutoff)
+	if err != nil {
+		return err
+	}
+	audits, err := s.queries.PurgeAuditEvents(ctx, pgtype.Timestamp{Time: now.Add(-s.auditRetention), Valid: true})
+	if err != nil {
+		return err
+	}
+	notifications, err := s.queries.PurgeNotifications(ctx, pgtype.Timestamp{Time: now.Add(-notificationRetention), Valid: true})
+	if err != nil {
+		return err
+	}
+
+	if anonymized+companies+memberships+users+audits+notifications > 0 {
+		slog.InfoContext(ctx, "Retention run finished",
+			"anonymized_users", anonymized, "companies", companies, "memberships", memberships,
+			"users", users, "audit_events", audits, "notifications", notifications)
+	}
+	return nil
+}
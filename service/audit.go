@@ -0,0 +1,180 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"project/compiled"
+)
+
+const (
+	AuditUserLoggedIn      = "user.logged_in"
+	AuditUserLoginFailed   = "user.login_failed"
+	AuditAccountDeleted    = "user.account_deleted"
+	AuditMemberInvited     = "member.invited"
+	AuditInvitationRevoked = "invitation.revoked"
+	AuditMemberJoined      = WebhookEventMemberJoined
+	AuditMemberLeft        = WebhookEventMemberLeft
+	AuditMemberRemoved     = WebhookEventMemberRemoved
+	AuditMemberRoleChanged = WebhookEventMemberRoleChanged
+)
+
+// AuditActions are the actions written to the audit log.
+var AuditActions = []string{
+	AuditUserLoggedIn,
+	AuditUserLoginFailed,
+	AuditAccountDeleted,
+	AuditMemberInvited,
+	AuditInvitationRevoked,
+	AuditMemberJoined,
+	AuditMemberLeft,
+	AuditMemberRemoved,
+	AuditMemberRoleChanged,
+}
+
+// RequestInfo identifies the client behind a request for the audit log.
+type RequestInfo struct {
+	IP        string
+	UserAgent string
+}
+
+type requestInfoKey struct{}
+
+// WithRequestInfo attaches the client's address and user agent to ctx.
+func WithRequestInfo(ctx context.Context, info RequestInfo) context.Context {
+	return context.WithValue(ctx, requestInfoKey{}, info)
+}
+
+func requestInfoFromContext(ctx context.Context) RequestInfo {
+	info, _ := ctx.Value(requestInfoKey{}).(RequestInfo)
+	return info
+}
+
+// AuditEntry is one audit log record. Zero IDs are stored as NULL.
+type AuditEntry struct {
+	Action       string
+	ActorID      int32
+	TargetUserID int32
+	CompanyID    int32
+	Metadata     map[string]any
+}
+
+// SubscribeAuditLog writes the audit log from events on the bus. Each event
+// becomes at most one row, dated when the event was recorded and carrying
+// the client details of the request behind it.
+func SubscribeAuditLog(bus *EventBus, queries *compiled.Queries) {
+	Subscribe(bus, "audit", func(ctx context.Context, meta EventMeta, e UserLoggedIn) error {
+		return recordAudit(ctx, queries, meta, AuditEntry{Action: AuditUserLoggedIn, ActorID: e.UserID})
+	})
+	Subscribe(bus, "audit", func(ctx context.Context, meta EventMeta, e LoginFailed) error {
+		return recordAudit(ctx, queries, meta, AuditEntry{
+			Action:       AuditUserLoginFailed,
+			TargetUserID: e.UserID,
+			Metadata:     map[string]any{"reason": e.Reason},
+		})
+	})
+	Subscribe(bus, "audit", func(ctx context.Context, meta EventMeta, e AccountDeleted) error {
+		return recordAudit(ctx, queries, meta, AuditEntry{Action: AuditAccountDeleted, ActorID: e.UserID})
+	})
+	Subscribe(bus, "audit", func(ctx context.Context, meta EventMeta, e MemberInvited) error {
+		return recordAudit(ctx, queries, meta, AuditEntry{
+			Action:       AuditMemberInvited,
+			ActorID:      e.InvitedBy,
+			TargetUserID: e.UserID,
+			CompanyID:    e.CompanyID,
+			Metadata:     map[string]any{"invitation_id": e.InvitationID, "email": e.Email, "role": e.Role},
+		})
+	})
+	Subscribe(bus, "audit", func(ctx context.Context, meta EventMeta, e InvitationRevoked) error {
+		return recordAudit(ctx, queries, meta, AuditEntry{
+			Action:    AuditInvitationRevoked,
+			ActorID:   e.RevokedBy,
+			CompanyID: e.CompanyID,
+			Metadata:  map[string]any{"invitation_id": e.InvitationID, "email": e.Email},
+		})
+	})
+	Subscribe(bus, "audit", func(ctx context.Context, meta EventMeta, e MemberJoined) error {
+		return recordAudit(ctx, queries, meta, memberAuditEntry(AuditMemberJoined, e.CompanyID, MemberEvent{
+			UserID:  e.UserID,
+			Role:    e.Role,
+			Source:  e.Source,
+			ActorID: e.AddedBy,
+		}))
+	})
+	Subscribe(bus, "audit", func(ctx context.Context, meta EventMeta, e MemberRoleChanged) error {
+		return recordAudit(ctx, queries, meta, memberAuditEntry(AuditMemberRoleChanged, e.CompanyID, MemberEvent{
+			UserID:       e.UserID,
+			Role:         e.Role,
+			PreviousRole: e.PreviousRole,
+			ActorID:      e.ChangedBy,
+		}))
+	})
+	Subscribe(bus, "audit", func(ctx context.Context, meta EventMeta, e MemberRemoved) error {
+		action := AuditMemberLeft
+		var source string
+		switch e.Reason {
+		case MemberRemovedReasonRemoved:
+			action = AuditMemberRemoved
+		case MemberRemovedReasonAccountDeleted:
+			source = e.Reason
+		}
+		return recordAudit(ctx, queries, meta, memberAuditEntry(action, e.CompanyID, MemberEvent{
+			UserID:  e.UserID,
+			Role:    e.Role,
+			Source:  source,
+			ActorID: e.RemovedBy,
+		}))
+	})
+}
+
+// recordAudit appends entry to the audit log for the event described by
+// meta. A redelivered event does not add a second row.
+func recordAudit(ctx context.Context, q *compiled.Queries, meta EventMeta, entry AuditEntry) error {
+	metadata := []byte("{}")
+	if len(entry.Metadata) > 0 {
+		var err error
+		if metadata, err = json.Marshal(entry.Metadata); err != nil {
+			return err
+		}
+	}
+
+	info := meta.Request
+	return q.InsertAuditEvent(ctx, compiled.InsertAuditEventParams{
+		Action:        entry.Action,
+		ActorID:       pgtype.Int4{Int32: entry.ActorID, Valid: entry.ActorID != 0},
+		TargetUserID:  pgtype.Int4{Int32: entry.TargetUserID, Valid: entry.TargetUserID != 0},
+		CompanyID:     pgtype.Int4{Int32: entry.CompanyID, Valid: entry.CompanyID != 0},
+		IpAddress:     pgtype.Text{String: info.IP, Valid: info.IP != ""},
+		UserAgent:     pgtype.Text{String: info.UserAgent, Valid: info.UserAgent != ""},
+		Metadata:      metadata,
+		SourceEventID: pgtype.Int8{Int64: meta.ID, Valid: true},
+		CreatedAt:     pgtype.Timestamp{Time: meta.OccurredAt, Valid: true},
+	})
+}
+
+// memberAuditEntry describes a member.* change. Without an admin acting, the
+// member is their own actor.
+func memberAuditEntry(action string, companyID int32, event MemberEvent) AuditEntry {
+	actorID := event.ActorID
+	if actorID == 0 {
+		actorID = event.UserID
+	}
+
+	metadata := map[string]any{"role": event.Role}
+	if event.PreviousRole != "" {
+		metadata["previous_role"] = event.PreviousRole
+	}
+	if event.Source != "" {
+		metadata["source"] = event.Source
+	}
+
+	return AuditEntry{
+		Action:       action,
+		ActorID:      actorID,
+		TargetUserID: event.UserID,
+		CompanyID:    companyID,
+		Metadata:     metadata,
+	}
+}
@@ -0,0 +1,375 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"project/compiled"
+)
+
+const (
+	AuditSinkFile   = "file"
+	AuditSinkSyslog = "syslog"
+	AuditSinkHTTP   = "http"
+
+	auditExportPollInterval = 5 * time.Second
+	auditExportBatchSize    = 500
+	auditExportRetryAfter   = time.Minute
+	// A run ends before ClaimAuditSink's two minute lease runs out, so no
+	// other instance picks the sink up while it is still being exported.
+	auditExportRunTimeout  = time.Minute
+	auditExportConcurrency = 8
+	auditExportDialTimeout = 10 * time.Second
+)
+
+var (
+	ErrAuditSinkNotFound           = errors.New("audit sink not found")
+	ErrAuditSinkKindInvalid        = errors.New("audit sink kind must be file, syslog or http")
+	ErrAuditSinkDestinationInvalid = errors.New("invalid audit sink destination")
+	ErrAuditSinkProtocolInvalid    = errors.New("syslog protocol must be tcp or udp")
+	ErrAuditSinkNotPublic          = errors.New("audit sink destination must be a public address")
+	ErrFileAuditSinksDisabled      = errors.New("file audit sinks are not enabled on this server")
+)
+
+// AuditRecord is one exported audit event. Delivery is at least once, so
+// consumers should drop records whose ID they have already seen.
+type AuditRecord struct {
+	ID           int64           `json:"id"`
+	Action       string          `json:"action"`
+	ActorID      int32           `json:"actor_id,omitempty"`
+	ActorEmail   string          `json:"actor_email,omitempty"`
+	TargetUserID int32           `json:"target_user_id,omitempty"`
+	TargetEmail  string          `json:"target_email,omitempty"`
+	CompanyID    int32           `json:"company_id,omitempty"`
+	IPAddress    string          `json:"ip_address,omitempty"`
+	UserAgent    string          `json:"user_agent,omitempty"`
+	Metadata     json.RawMessage `json:"metadata"`
+	CreatedAt    time.Time       `json:"created_at"`
+}
+
+// AuditExportService manages per-company audit sinks and streams the audit
+// log to them. Each sink keeps a checkpoint of the last exported event, so
+// exporting resumes where it stopped after a restart or an outage of the
+// receiving end. Like webhooks, syslog and http sinks only connect to public
+// addresses.
+type AuditExportService struct {
+	queries   *compiled.Queries
+	companies *CompanyService
+	// File sinks write below fileDir; they are disabled when it is empty.
+	fileDir  string
+	hostname string
+	client   *http.Client
+}
+
+func NewAuditExportService(queries *compiled.Queries, companies *CompanyService, fileDir string) *AuditExportService {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = ""
+	}
+	return &AuditExportService{
+		queries:   queries,
+		companies: companies,
+		fileDir:   fileDir,
+		hostname:  hostname,
+		client:    publicHTTPClient(webhookRequestTimeout),
+	}
+}
+
+// CreateAuditSink registers a sink for the company. Without backfill only
+// events recorded from now on are exported. The returned sink carries the
+// secret http sinks are signed with.
+func (s *AuditExportService) CreateAuditSink(ctx context.Context, adminID, companyID int32, kind, destination, protocol string, backfill bool) (*compiled.AuditSink, error) {
+	if err := s.companies.requireAdmin(ctx, companyID, adminID); err != nil {
+		return nil, err
+	}
+	if _, err := s.companies.getWritableCompany(ctx, companyID); err != nil {
+		return nil, err
+	}
+	if err := s.validateSink(kind, destination, protocol); err != nil {
+		return nil, err
+	}
+
+	sink, err := s.queries.CreateAuditSink(ctx, compiled.CreateAuditSinkParams{
+		CompanyID:   companyID,
+		Kind:        kind,
+		Destination: destination,
+		Protocol:    pgtype.Text{String: protocol, Valid: kind == AuditSinkSyslog},
+		Secret:      "whsec_" + generateToken(48),
+		CreatedBy:   pgtype.Int4{Int32: adminID, Valid: true},
+		Backfill:    backfill,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &sink, nil
+}
+
+func (s *AuditExportService) ListAuditSinks(ctx context.Context, adminID, companyID int32) ([]compiled.AuditSink, error) {
+	if err := s.companies.requireAdmin(ctx, companyID, adminID); err != nil {
+		return nil, err
+	}
+	return s.queries.ListAuditSinks(ctx, companyID)
+}
+
+// UpdateAuditSink changes where the sink exports to and whether it is
+// enabled. The checkpoint is kept, so a re-enabled sink catches up on the
+// events recorded while it was disabled.
+func (s *AuditExportService) UpdateAuditSink(ctx context.Context, adminID, companyID, sinkID int32, destination, protocol string, enabled bool) (*compiled.AuditSink, error) {
+	if err := s.companies.requireAdmin(ctx, companyID, adminID); err != nil {
+		return nil, err
+	}
+	if _, err := s.companies.getWritableCompany(ctx, companyID); err != nil {
+		return nil, err
+	}
+
+	sink, err := s.queries.GetAuditSink(ctx, compiled.GetAuditSinkParams{ID: sinkID, CompanyID: companyID})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrAuditSinkNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := s.validateSink(sink.Kind, destination, protocol); err != nil {
+		return nil, err
+	}
+
+	sink, err = s.queries.UpdateAuditSink(ctx, compiled.UpdateAuditSinkParams{
+		ID:          sinkID,
+		CompanyID:   companyID,
+		Destination: destination,
+		Protocol:    pgtype.Text{String: protocol, Valid: sink.Kind == AuditSinkSyslog},
+		Enabled:     enabled,
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrAuditSinkNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &sink, nil
+}
+
+// DeleteAuditSink stops exporting to the sink. Files already written are
+// left in place.
+func (s *AuditExportService) DeleteAuditSink(ctx context.Context, adminID, companyID, sinkID int32) error {
+	if err := s.companies.requireAdmin(ctx, companyID, adminID); err != nil {
+		return err
+	}
+	if _, err := s.companies.getWritableCompany(ctx, companyID); err != nil {
+		return err
+	}
+
+	rows, err := s.queries.DeleteAuditSink(ctx, compiled.DeleteAuditSinkParams{
+		ID:        sinkID,
+		CompanyID: companyID,
+	})
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrAuditSinkNotFound
+	}
+	return nil
+}
+
+// Run exports new audit events to due sinks until ctx is cancelled.
+func (s *AuditExportService) Run(ctx context.Context) {
+	ticker := time.NewTicker(auditExportPollInterval)
+	defer ticker.Stop()
+
+	for {
+		s.exportDue(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *AuditExportService) exportDue(ctx context.Context) {
+	// A slow or unreachable sink only holds up its own company
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	slots := make(chan struct{}, auditExportConcurrency)
+	for ctx.Err() == nil {
+		slots <- struct{}{}
+		sink, err := s.queries.ClaimAuditSink(ctx)
+		if err != nil {
+			<-slots
+			if !errors.Is(err, pgx.ErrNoRows) && ctx.Err() == nil {
+				slog.ErrorContext(ctx, "Failed to claim audit sink", "error", err)
+			}
+			return
+		}
+
+		wg.Go(func() {
+			defer func() { <-slots }()
+			s.export(ctx, sink)
+		})
+	}
+}
+
+// export sends the events recorded after the sink's checkpoint, advancing
+// the checkpoint after every batch the sink accepted, and schedules the
+// next run.
+func (s *AuditExportService) export(ctx context.Context, sink compiled.AuditSink) {
+	runCtx, cancel := context.WithTimeout(ctx, auditExportRunTimeout)
+	defer cancel()
+
+	err := s.exportBatches(runCtx, sink)
+	params := compiled.FinishAuditSinkRunParams{
+		ID:       sink.ID,
+		RunAfter: pgtype.Interval{Microseconds: auditExportPollInterval.Microseconds(), Valid: true},
+	}
+	if err != nil {
+		// Do not tell the company what its host name resolved to
+		if errors.Is(err, ErrDestinationNotPublic) {
+			err = ErrDestinationNotPublic
+		}
+		params.LastError = pgtype.Text{String: err.Error(), Valid: true}
+		params.RunAfter.Microseconds = auditExportRetryAfter.Microseconds()
+	}
+	if err := s.queries.FinishAuditSinkRun(ctx, params); err != nil && ctx.Err() == nil {
+		slog.ErrorContext(ctx, "Failed to record audit sink run", "sink_id", sink.ID, "error", err)
+	}
+}
+
+func (s *AuditExportService) exportBatches(ctx context.Context, sink compiled.AuditSink) error {
+	var w auditWriter
+	defer func() {
+		if w != nil {
+			_ = w.Close()
+		}
+	}()
+
+	checkpoint := sink.CheckpointID
+	for {
+		rows, err := s.queries.ListAuditEventsAfter(ctx, compiled.ListAuditEventsAfterParams{
+			CompanyID: sink.CompanyID,
+			AfterID:   checkpoint,
+			PageLimit: auditExportBatchSize,
+		})
+		if err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+
+		// Connect only once there is something to send
+		if w == nil {
+			if w, err = s.openWriter(ctx, sink); err != nil {
+				return err
+			}
+		}
+		records := make([]AuditRecord, 0, len(rows))
+		for _, row := range rows {
+			records = append(records, auditRecord(row))
+		}
+		if err := w.Write(ctx, records); err != nil {
+			return err
+		}
+
+		checkpoint = rows[len(rows)-1].ID
+		if err := s.queries.AdvanceAuditSinkCheckpoint(ctx, compiled.AdvanceAuditSinkCheckpointParams{
+			ID:           sink.ID,
+			CheckpointID: checkpoint,
+		}); err != nil {
+			return err
+		}
+		if len(rows) < auditExportBatchSize {
+			return nil
+		}
+	}
+}
+
+func (s *AuditExportService) openWriter(ctx context.Context, sink compiled.AuditSink) (auditWriter, error) {
+	switch sink.Kind {
+	case AuditSinkFile:
+		if s.fileDir == "" {
+			return nil, ErrFileAuditSinksDisabled
+		}
+		return openFileAuditWriter(s.auditFilePath(sink.CompanyID, sink.Destination))
+	case AuditSinkSyslog:
+		return dialSyslogAuditWriter(ctx, sink.Protocol.String, sink.Destination, s.hostname)
+	case AuditSinkHTTP:
+		return &httpAuditWriter{client: s.client, url: sink.Destination, secret: sink.Secret}, nil
+	default:
+		return nil, ErrAuditSinkKindInvalid
+	}
+}
+
+// auditFilePath keeps each company's files in a directory of its own, so a
+// company cannot append to another's export.
+func (s *AuditExportService) auditFilePath(companyID int32, name string) string {
+	return filepath.Join(s.fileDir, strconv.Itoa(int(companyID)), name)
+}
+
+func (s *AuditExportService) validateSink(kind, destination, protocol string) error {
+	switch kind {
+	case AuditSinkFile:
+		if s.fileDir == "" {
+			return ErrFileAuditSinksDisabled
+		}
+		// A bare file name; the directory is chosen by auditFilePath
+		if destination == "" || destination == "." || destination == ".." || filepath.Base(destination) != destination {
+			return ErrAuditSinkDestinationInvalid
+		}
+	case AuditSinkSyslog:
+		if protocol != "tcp" && protocol != "udp" {
+			return ErrAuditSinkProtocolInvalid
+		}
+		host, port, err := net.SplitHostPort(destination)
+		if err != nil || host == "" {
+			return ErrAuditSinkDestinationInvalid
+		}
+		if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+			return ErrAuditSinkDestinationInvalid
+		}
+		if checkPublicHost(host) != nil {
+			return ErrAuditSinkNotPublic
+		}
+	case AuditSinkHTTP:
+		u, err := url.Parse(destination)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return ErrAuditSinkDestinationInvalid
+		}
+		if checkPublicHost(u.Hostname()) != nil {
+			return ErrAuditSinkNotPublic
+		}
+	default:
+		return ErrAuditSinkKindInvalid
+	}
+	return nil
+}
+
+func auditRecord(row compiled.ListAuditEventsAfterRow) AuditRecord {
+	return AuditRecord{
+		ID:           row.ID,
+		Action:       row.Action,
+		ActorID:      row.ActorID.Int32,
+		ActorEmail:   row.ActorEmail.String,
+		TargetUserID: row.TargetUserID.Int32,
+		TargetEmail:  row.TargetEmail.String,
+		CompanyID:    row.CompanyID.Int32,
+		IPAddress:    row.IpAddress.String,
+		UserAgent:    row.UserAgent.String,
+		Metadata:     row.Metadata,
+		CreatedAt:    row.CreatedAt.Time.UTC(),
+	}
+}
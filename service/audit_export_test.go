@@ -0,0 +1,73 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"project/compiled"
+)
+
+func TestValidateSinkRejectsInternalDestinations(t *testing.T) {
+	s := &AuditExportService{}
+	tests := []struct {
+		kind, destination, protocol string
+		want                        error
+	}{
+		{AuditSinkSyslog, "logs.example.com:6514", "tcp", nil},
+		{AuditSinkSyslog, "93.184.216.34:514", "udp", nil},
+		{AuditSinkSyslog, "127.0.0.1:514", "udp", ErrAuditSinkNotPublic},
+		{AuditSinkSyslog, "localhost:514", "tcp", ErrAuditSinkNotPublic},
+		{AuditSinkSyslog, "[fd00::1]:514", "tcp", ErrAuditSinkNotPublic},
+		{AuditSinkHTTP, "https://audit.example.com/ingest", "", nil},
+		{AuditSinkHTTP, "http://10.0.0.5/ingest", "", ErrAuditSinkNotPublic},
+		{AuditSinkHTTP, "http://169.254.169.254/latest/meta-data", "", ErrAuditSinkNotPublic},
+		{AuditSinkHTTP, "http://[::1]:8080/ingest", "", ErrAuditSinkNotPublic},
+	}
+	for _, tt := range tests {
+		if err := s.validateSink(tt.kind, tt.destination, tt.protocol); !errors.Is(err, tt.want) {
+			t.Errorf("validateSink(%s, %q) = %v, want %v", tt.kind, tt.destination, err, tt.want)
+		}
+	}
+}
+
+func TestSyslogWriterRefusesLoopback(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	if _, err := dialSyslogAuditWriter(context.Background(), "tcp", ln.Addr().String(), "test"); !errors.Is(err, ErrDestinationNotPublic) {
+		t.Fatalf("dial %s = %v, want ErrDestinationNotPublic", ln.Addr(), err)
+	}
+}
+
+func TestDeleteAuditSinkOnArchivedCompany(t *testing.T) {
+	pool, queries := testDB(t)
+	companies := newTestCompanyService(pool, queries, nil)
+	s := NewAuditExportService(queries, companies, t.TempDir())
+	ctx := context.Background()
+
+	owner := newTestUser(t, queries, "example.com")
+	companyID := newTestCompany(t, companies, owner.ID)
+	sink, err := queries.CreateAuditSink(ctx, compiled.CreateAuditSinkParams{
+		CompanyID:   companyID,
+		Kind:        AuditSinkFile,
+		Destination: "audit.jsonl",
+		CreatedBy:   pgtype.Int4{Int32: owner.ID, Valid: true},
+	})
+	if err != nil {
+		t.Fatalf("create sink: %v", err)
+	}
+	if err := companies.SetCompanyArchived(ctx, owner.ID, companyID, true); err != nil {
+		t.Fatalf("SetCompanyArchived: %v", err)
+	}
+
+	if err := s.DeleteAuditSink(ctx, owner.ID, companyID, sink.ID); !errors.Is(err, ErrCompanyArchived) {
+		t.Errorf("DeleteAuditSink = %v, want ErrCompanyArchived", err)
+	}
+}
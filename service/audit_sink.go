@@ -0,0 +1,183 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+const (
+	// Syslog priority of exported events: facility 13 (log audit),
+	// severity 6 (informational)
+	syslogAuditPriority = 13*8 + 6
+	syslogAppName       = "lavorus"
+)
+
+// auditWriter delivers batches of audit records to one sink. Write returns
+// only once the sink has accepted the whole batch.
+type auditWriter interface {
+	Write(ctx context.Context, records []AuditRecord) error
+	Close() error
+}
+
+// fileAuditWriter appends records to a file as JSON lines.
+type fileAuditWriter struct {
+	file *os.File
+}
+
+func openFileAuditWriter(path string) (*fileAuditWriter, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	return &fileAuditWriter{file: file}, nil
+}
+
+func (w *fileAuditWriter) Write(ctx context.Context, records []AuditRecord) error {
+	buf := bufio.NewWriter(w.file)
+	enc := json.NewEncoder(buf)
+	for _, r := range records {
+		if err := enc.Encode(r); err != nil {
+			return err
+		}
+	}
+	if err := buf.Flush(); err != nil {
+		return err
+	}
+	// The checkpoint moves on after this, so the lines must be on disk
+	return w.file.Sync()
+}
+
+func (w *fileAuditWriter) Close() error {
+	return w.file.Close()
+}
+
+// syslogAuditWriter sends each record as an RFC 5424 message with the JSON
+// record as its body. Over TCP messages are framed by octet counting
+// (RFC 6587); over UDP each message is one datagram.
+type syslogAuditWriter struct {
+	conn     net.Conn
+	network  string
+	hostname string
+}
+
+func dialSyslogAuditWriter(ctx context.Context, network, address, hostname string) (*syslogAuditWriter, error) {
+	conn, err := publicDialer(auditExportDialTimeout).DialContext(ctx, network, address)
+	if err != nil {
+		return nil, err
+	}
+	return &syslogAuditWriter{conn: conn, network: network, hostname: hostname}, nil
+}
+
+func (w *syslogAuditWriter) Write(ctx context.Context, records []AuditRecord) error {
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := w.conn.SetWriteDeadline(deadline); err != nil {
+			return err
+		}
+	}
+
+	var buf bytes.Buffer
+	for _, r := range records {
+		msg, err := formatSyslogAuditMessage(w.hostname, r)
+		if err != nil {
+			return err
+		}
+		if w.network == "udp" {
+			if _, err := w.conn.Write(msg); err != nil {
+				return err
+			}
+			continue
+		}
+		buf.WriteString(strconv.Itoa(len(msg)))
+		buf.WriteByte(' ')
+		buf.Write(msg)
+	}
+	if buf.Len() == 0 {
+		return nil
+	}
+	_, err := w.conn.Write(buf.Bytes())
+	return err
+}
+
+func (w *syslogAuditWriter) Close() error {
+	return w.conn.Close()
+}
+
+// formatSyslogAuditMessage renders r as
+// "<PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID SD MSG", using the action
+// as MSGID. There is no structured data; the event ID is part of the JSON.
+func formatSyslogAuditMessage(hostname string, r AuditRecord) ([]byte, error) {
+	body, err := json.Marshal(r)
+	if err != nil {
+		return nil, err
+	}
+	if hostname == "" {
+		hostname = "-"
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "<%d>1 %s %s %s %d %s - ",
+		syslogAuditPriority,
+		r.CreatedAt.UTC().Format(time.RFC3339Nano),
+		hostname,
+		syslogAppName,
+		os.Getpid(),
+		r.Action,
+	)
+	buf.Write(body)
+	return buf.Bytes(), nil
+}
+
+// httpAuditWriter posts each batch as newline-delimited JSON, signed like
+// webhook payloads.
+type httpAuditWriter struct {
+	client *http.Client
+	url    string
+	secret string
+}
+
+func (w *httpAuditWriter) Write(ctx context.Context, records []AuditRecord) error {
+	var body bytes.Buffer
+	enc := json.NewEncoder(&body)
+	for _, r := range records {
+		if err := enc.Encode(r); err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body.Bytes()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	req.Header.Set("User-Agent", "Lavorus-Audit-Export/1.0")
+	req.Header.Set(WebhookSignatureHeader, SignWebhookPayload(w.secret, time.Now(), body.Bytes()))
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("receiver responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (w *httpAuditWriter) Close() error {
+	return nil
+}
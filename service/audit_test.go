@@ -0,0 +1,83 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func TestAuditLogIgnoresRedeliveredEvents(t *testing.T) {
+	_, queries := testDB(t)
+	ctx := context.Background()
+
+	user := newTestUser(t, queries, "example.com")
+	meta := EventMeta{
+		// Far above any real outbox ID, so the test owns it
+		ID:         time.Now().UnixNano(),
+		OccurredAt: time.Now().Add(-time.Hour).UTC().Truncate(time.Microsecond),
+		Request:    RequestInfo{IP: "203.0.113.7", UserAgent: "test-agent"},
+	}
+	entry := AuditEntry{Action: AuditUserLoggedIn, ActorID: user.ID}
+
+	for range 2 {
+		if err := recordAudit(ctx, queries, meta, entry); err != nil {
+			t.Fatalf("recordAudit: %v", err)
+		}
+	}
+
+	events, err := queries.ListUserAuditEvents(ctx, pgtype.Int4{Int32: user.ID, Valid: true})
+	if err != nil {
+		t.Fatalf("ListUserAuditEvents: %v", err)
+	}
+	if len(events) != 1 {
+		t.Fatalf("got %d audit rows, want 1", len(events))
+	}
+	got := events[0]
+	if got.IpAddress.String != "203.0.113.7" || got.UserAgent.String != "test-agent" {
+		t.Errorf("client = %q, %q", got.IpAddress.String, got.UserAgent.String)
+	}
+	if !got.CreatedAt.Time.Equal(meta.OccurredAt) {
+		t.Errorf("created_at = %v, want the event time %v", got.CreatedAt.Time, meta.OccurredAt)
+	}
+}
+
+func TestListAuditEventsHidesAccountEventsBeforeJoining(t *testing.T) {
+	pool, queries := testDB(t)
+	s := newTestCompanyService(pool, queries, nil)
+	ctx := context.Background()
+
+	owner := newTestUser(t, queries, "example.com")
+	companyID := newTestCompany(t, s, owner.ID)
+	member := newTestUser(t, queries, "example.com")
+
+	// A day either side keeps the test independent of the database time zone
+	id := time.Now().UnixNano()
+	before := EventMeta{ID: id, OccurredAt: time.Now().Add(-24 * time.Hour).UTC()}
+	after := EventMeta{ID: id + 1, OccurredAt: time.Now().Add(24 * time.Hour).UTC()}
+	for _, meta := range []EventMeta{before, after} {
+		if err := recordAudit(ctx, queries, meta, AuditEntry{Action: AuditUserLoggedIn, ActorID: member.ID}); err != nil {
+			t.Fatalf("recordAudit: %v", err)
+		}
+	}
+	addTestMember(t, queries, companyID, member.ID, "member")
+
+	page, err := s.ListAuditEvents(ctx, owner.ID, companyID, AuditListOptions{Actions: []string{AuditUserLoggedIn}})
+	if err != nil {
+		t.Fatalf("ListAuditEvents: %v", err)
+	}
+	var logins int
+	for _, e := range page.Events {
+		if e.ActorID.Int32 != member.ID {
+			continue
+		}
+		logins++
+		if e.CreatedAt.Time.Before(time.Now()) {
+			t.Errorf("login from before the member joined is listed: %+v", e)
+		}
+	}
+	if logins != 1 {
+		t.Errorf("listed %d logins of the member, want 1", logins)
+	}
+}
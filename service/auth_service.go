@@ -6,12 +6,13 @@ import (
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"log/slog"
 	"math/big"
 	"strings"
 	"time"
 
 	"github.com/jackc/pgx/v5/pgtype"
-	"github.com/resend/resend-go/v2"
+	"github.com/jackc/pgx/v5/pgxpool"
 
 	"project/compiled"
 )
@@ -24,12 +25,13 @@ var (
 const validOTP = "123456"
 
 type AuthService struct {
-	queries      *compiled.Queries
-	resendAPIKey string
+	pool    *pgxpool.Pool
+	queries *compiled.Queries
+	mailer  Mailer
 }
 
-func NewAuthService(queries *compiled.Queries, resendAPIKey string) *AuthService {
-	return &AuthService{queries: queries, resendAPIKey: resendAPIKey}
+func NewAuthService(pool *pgxpool.Pool, queries *compiled.Queries, mailer Mailer) *AuthService {
+	return &AuthService{pool: pool, queries: queries, mailer: mailer}
 }
 
 func (s *AuthService) RequestOTP(ctx context.Context, email string) error {
@@ -57,20 +59,8 @@ func (s *AuthService) RequestOTP(ctx context.Context, email string) error {
 		return err
 	}
 
-	if s.resendAPIKey != "" {
-		client := resend.NewClient(s.resendAPIKey)
-		_, err = client.Emails.Send(&resend.SendEmailRequest{
-			From:    "noreply@mg.lavorus.com",
-			To:      []string{email},
-			Subject: "Your access login OTP",
-			Text:    fmt.Sprintf("Your OTP is: %s\nIt expires in 5 minutes.", otp),
-		})
-		if err != nil {
-			return err
-		}
-	}
-
-	return nil
+	return s.mailer.Send(ctx, email, "Your access login OTP",
+		fmt.Sprintf("Your OTP is: %s\nIt expires in 5 minutes.", otp))
 }
 
 func (s *AuthService) Login(ctx context.Context, email, otp string) (string, error) {
@@ -81,14 +71,14 @@ func (s *AuthService) Login(ctx context.Context, email, otp string) (string, err
 
 	if strings.HasSuffix(email, "@localhost") {
 		if otp != validOTP {
-			return "", ErrInvalidOTP
+			return "", s.loginFailed(ctx, user.ID, "invalid_otp")
 		}
 	} else {
 		if !user.Otp.Valid || user.Otp.String != otp {
-			return "", ErrInvalidOTP
+			return "", s.loginFailed(ctx, user.ID, "invalid_otp")
 		}
 		if !user.OtpExpiresAt.Valid || time.Now().After(user.OtpExpiresAt.Time) {
-			return "", ErrInvalidOTP
+			return "", s.loginFailed(ctx, user.ID, "expired_otp")
 		}
 		// Clear OTP after successful use
 		_ = s.queries.UpdateUserOTP(ctx, compiled.UpdateUserOTPParams{
@@ -100,9 +90,14 @@ func (s *AuthService) Login(ctx context.Context, email, otp string) (string, err
 
 	token := generateToken(10)
 
-	err = s.queries.UpdateUserToken(ctx, compiled.UpdateUserTokenParams{
-		ID:    user.ID,
-		Token: pgtype.Text{String: token, Valid: true},
+	err = runInTx(ctx, s.pool, s.queries, func(q *compiled.Queries) error {
+		if err := q.UpdateUserToken(ctx, compiled.UpdateUserTokenParams{
+			ID:    user.ID,
+			Token: pgtype.Text{String: token, Valid: true},
+		}); err != nil {
+			return err
+		}
+		return recordEvent(ctx, q, UserLoggedIn{UserID: user.ID, Email: user.Email})
 	})
 	if err != nil {
 		return "", err
@@ -111,6 +106,15 @@ func (s *AuthService) Login(ctx context.Context, email, otp string) (string, err
 	return token, nil
 }
 
+// loginFailed records a rejected sign-in attempt on an existing account for
+// the audit log and returns ErrInvalidOTP. The write is best effort.
+func (s *AuthService) loginFailed(ctx context.Context, userID int32, reason string) error {
+	if err := recordEvent(ctx, s.queries, LoginFailed{UserID: userID, Reason: reason}); err != nil {
+		slog.ErrorContext(ctx, "Failed to record login failure", "user_id", userID, "error", err)
+	}
+	return ErrInvalidOTP
+}
+
 func generateToken(length int) string {
 	bytes := make([]byte, length/2+1)
 	rand.Read(bytes)
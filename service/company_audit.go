@@ -0,0 +1,102 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"project/compiled"
+)
+
+const (
+	defaultAuditPageSize = 50
+	maxAuditPageSize     = 500
+)
+
+var (
+	ErrInvalidAuditAction = errors.New("unknown audit action")
+	ErrInvalidTimeRange   = errors.New("end_time must be after start_time")
+)
+
+type AuditListOptions struct {
+	PageSize  int32
+	PageToken string
+	Since     time.Time
+	Until     time.Time
+	Actions   []string
+}
+
+type AuditPage struct {
+	Events        []compiled.ListAuditEventsRow
+	NextPageToken string
+}
+
+// auditPageToken records the filters it was issued for, like
+// memberPageToken.
+type auditPageToken struct {
+	Filter   string `json:"f"`
+	BeforeID int64  `json:"b"`
+}
+
+// ListAuditEvents returns one page of the company's audit log, newest first.
+// Only admins may read it.
+func (s *CompanyService) ListAuditEvents(ctx context.Context, adminID, companyID int32, opts AuditListOptions) (*AuditPage, error) {
+	if err := s.requireAdmin(ctx, companyID, adminID); err != nil {
+		return nil, err
+	}
+
+	if opts.PageSize < 0 {
+		return nil, ErrInvalidPageSize
+	}
+	pageSize := opts.PageSize
+	if pageSize == 0 {
+		pageSize = defaultAuditPageSize
+	}
+	pageSize = min(pageSize, maxAuditPageSize)
+
+	if !opts.Since.IsZero() && !opts.Until.IsZero() && !opts.Until.After(opts.Since) {
+		return nil, ErrInvalidTimeRange
+	}
+	actions := slices.Compact(slices.Sorted(slices.Values(opts.Actions)))
+	for _, action := range actions {
+		if !slices.Contains(AuditActions, action) {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidAuditAction, action)
+		}
+	}
+	if actions == nil {
+		actions = []string{}
+	}
+
+	filter := fmt.Sprintf("%d|%d|%s", opts.Since.UnixNano(), opts.Until.UnixNano(), strings.Join(actions, ","))
+	var before auditPageToken
+	if opts.PageToken != "" {
+		if err := decodePageToken(opts.PageToken, &before); err != nil || before.Filter != filter {
+			return nil, ErrInvalidPageToken
+		}
+	}
+
+	rows, err := s.queries.ListAuditEvents(ctx, compiled.ListAuditEventsParams{
+		CompanyID: companyID,
+		Since:     pgtype.Timestamp{Time: opts.Since.UTC(), Valid: !opts.Since.IsZero()},
+		Until:     pgtype.Timestamp{Time: opts.Until.UTC(), Valid: !opts.Until.IsZero()},
+		Actions:   actions,
+		BeforeID:  pgtype.Int8{Int64: before.BeforeID, Valid: opts.PageToken != ""},
+		PageLimit: pageSize + 1,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	page := &AuditPage{Events: rows}
+	if len(rows) > int(pageSize) {
+		page.Events = rows[:pageSize]
+		last := page.Events[pageSize-1]
+		page.NextPageToken = encodePageToken(auditPageToken{Filter: filter, BeforeID: last.ID})
+	}
+	return page, nil
+}
@@ -0,0 +1,189 @@
+package service
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"io"
+	"net/mail"
+	"strings"
+
+	"project/compiled"
+)
+
+const (
+	BulkInviteMaxRows   = 1000
+	bulkInviteChunkSize = 50
+)
+
+var (
+	ErrBulkInviteEmpty     = errors.New("csv contains no rows")
+	ErrBulkInviteTooLarge  = errors.New("csv contains too many rows")
+	ErrBulkInviteMalformed = errors.New("csv is malformed")
+
+	ErrInvalidEmail       = errors.New("email is invalid")
+	ErrNameRequired       = errors.New("name is required")
+	ErrInvalidRole        = errors.New("role must be 'admin' or 'member'")
+	ErrDuplicateRow       = errors.New("email appears more than once in the file")
+	ErrInvitationNotSaved = errors.New("invitation could not be saved")
+)
+
+type BulkInviteRow struct {
+	Line  int
+	Email string
+	Name  string
+	Role  string
+}
+
+type BulkInviteResult struct {
+	Line         int
+	Email        string
+	InvitationID int32
+	Err          error
+}
+
+// ParseBulkInviteCSV reads email,name,role rows. A leading header row is
+// skipped when its first column is "email". An empty role falls back to the
+// company's default invite role.
+func ParseBulkInviteCSV(r io.Reader) ([]BulkInviteRow, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = 3
+	reader.TrimLeadingSpace = true
+
+	var rows []BulkInviteRow
+	for first := true; ; first = false {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, ErrBulkInviteMalformed
+		}
+
+		if first && strings.EqualFold(strings.TrimSpace(record[0]), "email") {
+			continue
+		}
+		if len(rows) == BulkInviteMaxRows {
+			return nil, ErrBulkInviteTooLarge
+		}
+
+		line, _ := reader.FieldPos(0)
+		rows = append(rows, BulkInviteRow{
+			Line:  line,
+			Email: strings.TrimSpace(record[0]),
+			Name:  strings.TrimSpace(record[1]),
+			Role:  strings.ToLower(strings.TrimSpace(record[2])),
+		})
+	}
+
+	if len(rows) == 0 {
+		return nil, ErrBulkInviteEmpty
+	}
+	return rows, nil
+}
+
+// BulkInviteUsers validates every row up front, then creates invitations in
+// chunks, each chunk in its own transaction, using the same rules as
+// InviteUser. Rows that break a rule are reported and skipped; a chunk that
+// fails to save is reported row by row without affecting other chunks.
+func (s *CompanyService) BulkInviteUsers(ctx context.Context, inviterID, companyID int32, rows []BulkInviteRow) ([]BulkInviteResult, error) {
+	if len(rows) == 0 {
+		return nil, ErrBulkInviteEmpty
+	}
+	if len(rows) > BulkInviteMaxRows {
+		return nil, ErrBulkInviteTooLarge
+	}
+
+	if err := s.requireAdmin(ctx, companyID, inviterID); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.getWritableCompany(ctx, companyID); err != nil {
+		return nil, err
+	}
+	if err := s.requireFeature(ctx, companyID, FeatureBulkInvite); err != nil {
+		return nil, err
+	}
+
+	settings, err := s.CompanySettings(ctx, companyID)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]BulkInviteResult, len(rows))
+	valid := make([]int, 0, len(rows))
+	seen := make(map[string]bool, len(rows))
+	for i, row := range rows {
+		results[i] = BulkInviteResult{Line: row.Line, Email: row.Email}
+		if row.Role == "" {
+			rows[i].Role = settings.DefaultInviteRole
+			row.Role = rows[i].Role
+		}
+		if err := validateInviteRow(row); err != nil {
+			results[i].Err = err
+			continue
+		}
+		key := strings.ToLower(row.Email)
+		if seen[key] {
+			results[i].Err = ErrDuplicateRow
+			continue
+		}
+		seen[key] = true
+		valid = append(valid, i)
+	}
+
+	if err := s.queries.ExpireCompanyInvitations(ctx, companyID); err != nil {
+		return nil, err
+	}
+
+	for start := 0; start < len(valid); start += bulkInviteChunkSize {
+		chunk := valid[start:min(start+bulkInviteChunkSize, len(valid))]
+
+		created := make(map[int]compiled.Invitation, len(chunk))
+		err := runInTx(ctx, s.pool, s.queries, func(q *compiled.Queries) error {
+			clear(created)
+			for _, i := range chunk {
+				results[i].Err = nil
+				invitation, err := s.createInvitation(ctx, q, inviterID, companyID, rows[i].Email, rows[i].Name, rows[i].Role)
+				if errors.Is(err, ErrUserAlreadyMember) || errors.Is(err, ErrInvitationPending) || errors.Is(err, ErrSeatLimitReached) ||
+					errors.Is(err, ErrEmailDomainBlocked) {
+					results[i].Err = err
+					continue
+				}
+				if err != nil {
+					return err
+				}
+				created[i] = invitation
+			}
+			return nil
+		})
+		if err != nil {
+			for _, i := range chunk {
+				if results[i].Err == nil {
+					results[i].Err = ErrInvitationNotSaved
+				}
+			}
+			continue
+		}
+
+		for i, invitation := range created {
+			results[i].InvitationID = invitation.ID
+		}
+	}
+
+	return results, nil
+}
+
+func validateInviteRow(row BulkInviteRow) error {
+	addr, err := mail.ParseAddress(row.Email)
+	if err != nil || addr.Address != row.Email {
+		return ErrInvalidEmail
+	}
+	if row.Name == "" {
+		return ErrNameRequired
+	}
+	if row.Role != "admin" && row.Role != "member" {
+		return ErrInvalidRole
+	}
+	return nil
+}
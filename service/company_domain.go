@@ -0,0 +1,242 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net"
+	"slices"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"project/compiled"
+)
+
+const (
+	domainVerificationHost   = "_lavorus-verification."
+	domainVerificationPrefix = "lavorus-verification="
+)
+
+var (
+	ErrDomainNotFound          = errors.New("domain not found")
+	ErrDomainInvalid           = errors.New("domain is invalid")
+	ErrDomainAlreadyClaimed    = errors.New("the company already claimed this domain")
+	ErrDomainVerifiedElsewhere = errors.New("domain is already verified by another company")
+	ErrDomainNotVerified       = errors.New("verification TXT record was not found")
+)
+
+// TXTResolver looks up DNS TXT records. *net.Resolver satisfies it.
+type TXTResolver interface {
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+}
+
+// ClaimDomain starts a domain claim. The claim only takes effect once
+// VerifyDomain finds the returned token in DNS. An empty joinRole uses the
+// company's default invite role.
+func (s *CompanyService) ClaimDomain(ctx context.Context, adminID, companyID int32, domain, joinRole string) (*compiled.CompanyDomain, error) {
+	if err := s.requireAdmin(ctx, companyID, adminID); err != nil {
+		return nil, err
+	}
+	if _, err := s.getWritableCompany(ctx, companyID); err != nil {
+		return nil, err
+	}
+
+	domain, ok := normalizeDomain(domain)
+	if !ok {
+		return nil, ErrDomainInvalid
+	}
+	if joinRole == "" {
+		settings, err := s.CompanySettings(ctx, companyID)
+		if err != nil {
+			return nil, err
+		}
+		joinRole = settings.DefaultInviteRole
+	}
+
+	claim, err := s.queries.CreateCompanyDomain(ctx, compiled.CreateCompanyDomainParams{
+		CompanyID:         companyID,
+		Domain:            domain,
+		VerificationToken: generateToken(32),
+		JoinRole:          joinRole,
+		CreatedBy:         pgtype.Int4{Int32: adminID, Valid: true},
+	})
+	if isUniqueViolation(err) {
+		return nil, ErrDomainAlreadyClaimed
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &claim, nil
+}
+
+// VerifyDomain checks the claim's TXT record and marks it verified.
+func (s *CompanyService) VerifyDomain(ctx context.Context, adminID, companyID, domainID int32) (*compiled.CompanyDomain, error) {
+	if err := s.requireAdmin(ctx, companyID, adminID); err != nil {
+		return nil, err
+	}
+	if _, err := s.getWritableCompany(ctx, companyID); err != nil {
+		return nil, err
+	}
+
+	claim, err := s.getDomain(ctx, companyID, domainID)
+	if err != nil {
+		return nil, err
+	}
+	if claim.VerifiedAt.Valid {
+		return &claim, nil
+	}
+
+	records, err := s.resolver.LookupTXT(ctx, DomainVerificationRecordName(claim.Domain))
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+		return nil, ErrDomainNotVerified
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Contains(records, DomainVerificationRecordValue(&claim)) {
+		return nil, ErrDomainNotVerified
+	}
+
+	claim, err = s.queries.MarkCompanyDomainVerified(ctx, claim.ID)
+	if isUniqueViolation(err) {
+		return nil, ErrDomainVerifiedElsewhere
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &claim, nil
+}
+
+func (s *CompanyService) ListDomains(ctx context.Context, adminID, companyID int32) ([]compiled.CompanyDomain, error) {
+	if err := s.requireAdmin(ctx, companyID, adminID); err != nil {
+		return nil, err
+	}
+	return s.queries.ListCompanyDomains(ctx, companyID)
+}
+
+func (s *CompanyService) RemoveDomain(ctx context.Context, adminID, companyID, domainID int32) error {
+	if err := s.requireAdmin(ctx, companyID, adminID); err != nil {
+		return err
+	}
+	if _, err := s.getWritableCompany(ctx, companyID); err != nil {
+		return err
+	}
+
+	rows, err := s.queries.SoftDeleteCompanyDomain(ctx, compiled.SoftDeleteCompanyDomainParams{
+		ID:        domainID,
+		CompanyID: companyID,
+	})
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrDomainNotFound
+	}
+	return nil
+}
+
+// JoinVerifiedDomainCompanies adds the user to every company that verified
+// the domain of their email, using each claim's join role. Companies the
+// user already belongs to, was removed from or left are skipped, so ending a
+// membership sticks; so are companies without a free seat. It returns the
+// IDs of the companies joined.
+func (s *CompanyService) JoinVerifiedDomainCompanies(ctx context.Context, userID int32, email string) ([]int32, error) {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return nil, nil
+	}
+
+	claims, err := s.queries.GetVerifiedDomainCompanies(ctx, email[at+1:])
+	if err != nil {
+		return nil, err
+	}
+
+	var joined []int32
+	for _, claim := range claims {
+		err := runInTx(ctx, s.pool, s.queries, func(q *compiled.Queries) error {
+			if err := s.reserveSeat(ctx, q, claim.CompanyID); err != nil {
+				return err
+			}
+
+			// Checked with the company locked by reserveSeat
+			hasHistory, err := q.HasCompanyMembershipHistory(ctx, compiled.HasCompanyMembershipHistoryParams{
+				CompanyID: claim.CompanyID,
+				UserID:    userID,
+			})
+			if err != nil {
+				return err
+			}
+			if hasHistory {
+				return ErrUserAlreadyMember
+			}
+
+			_, err = q.AddUserToCompany(ctx, compiled.AddUserToCompanyParams{
+				CompanyID: claim.CompanyID,
+				UserID:    userID,
+				Role:      claim.JoinRole,
+			})
+			if errors.Is(err, pgx.ErrNoRows) {
+				return ErrUserAlreadyMember
+			}
+			if err != nil {
+				return err
+			}
+
+			return recordMemberEvent(ctx, q, WebhookEventMemberJoined, claim.CompanyID, MemberEvent{
+				UserID: userID,
+				Role:   claim.JoinRole,
+				Source: "domain",
+			})
+		})
+		if errors.Is(err, ErrUserAlreadyMember) || errors.Is(err, ErrSeatLimitReached) {
+			continue
+		}
+		if err != nil {
+			return joined, err
+		}
+		joined = append(joined, claim.CompanyID)
+	}
+
+	return joined, nil
+}
+
+// DomainVerificationRecordName is the DNS name that must carry the TXT record.
+func DomainVerificationRecordName(domain string) string {
+	return domainVerificationHost + domain
+}
+
+// DomainVerificationRecordValue is the TXT record value proving the claim.
+func DomainVerificationRecordValue(claim *compiled.CompanyDomain) string {
+	return domainVerificationPrefix + claim.VerificationToken
+}
+
+func (s *CompanyService) getDomain(ctx context.Context, companyID, domainID int32) (compiled.CompanyDomain, error) {
+	claim, err := s.queries.GetCompanyDomain(ctx, compiled.GetCompanyDomainParams{
+		ID:        domainID,
+		CompanyID: companyID,
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return claim, ErrDomainNotFound
+	}
+	return claim, err
+}
+
+func normalizeDomain(domain string) (string, bool) {
+	domain = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(domain)), ".")
+	if len(domain) > 253 || !strings.Contains(domain, ".") {
+		return "", false
+	}
+	for _, label := range strings.Split(domain, ".") {
+		if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return "", false
+		}
+		for _, r := range label {
+			if (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '-' {
+				return "", false
+			}
+		}
+	}
+	return domain, true
+}
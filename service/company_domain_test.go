@@ -0,0 +1,134 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net"
+	"slices"
+	"testing"
+
+	"project/compiled"
+)
+
+// fakeResolver serves TXT records from memory, keyed by DNS name.
+type fakeResolver map[string][]string
+
+func (r fakeResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	records, ok := r[name]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+	}
+	return records, nil
+}
+
+func TestVerifyDomainAndAutoJoin(t *testing.T) {
+	pool, queries := testDB(t)
+	s := newTestCompanyService(pool, queries, nil)
+	resolver := fakeResolver{}
+	s.resolver = resolver
+	ctx := context.Background()
+
+	owner := newTestUser(t, queries, "example.com")
+	companyID := newTestCompany(t, s, owner.ID)
+	domain := generateToken(12) + ".example"
+
+	claim, err := s.ClaimDomain(ctx, owner.ID, companyID, domain, "member")
+	if err != nil {
+		t.Fatalf("ClaimDomain: %v", err)
+	}
+	if _, err := s.VerifyDomain(ctx, owner.ID, companyID, claim.ID); !errors.Is(err, ErrDomainNotVerified) {
+		t.Fatalf("VerifyDomain without a record = %v, want ErrDomainNotVerified", err)
+	}
+
+	resolver[DomainVerificationRecordName(domain)] = []string{"unrelated", DomainVerificationRecordValue(claim)}
+	verified, err := s.VerifyDomain(ctx, owner.ID, companyID, claim.ID)
+	if err != nil {
+		t.Fatalf("VerifyDomain: %v", err)
+	}
+	if !verified.VerifiedAt.Valid {
+		t.Fatal("claim is not marked verified")
+	}
+
+	user := newTestUser(t, queries, domain)
+	joined, err := s.JoinVerifiedDomainCompanies(ctx, user.ID, user.Email)
+	if err != nil {
+		t.Fatalf("JoinVerifiedDomainCompanies: %v", err)
+	}
+	if !slices.Equal(joined, []int32{companyID}) {
+		t.Fatalf("joined = %v, want [%d]", joined, companyID)
+	}
+	role, err := s.GetCompanyUserRole(ctx, companyID, user.ID)
+	if err != nil || role != "member" {
+		t.Fatalf("role = %q, %v; want member", role, err)
+	}
+
+	// Users on other domains are left alone
+	other := newTestUser(t, queries, "example.com")
+	if joined, err := s.JoinVerifiedDomainCompanies(ctx, other.ID, other.Email); err != nil || len(joined) != 0 {
+		t.Fatalf("JoinVerifiedDomainCompanies(other domain) = %v, %v; want none", joined, err)
+	}
+}
+
+func TestAutoJoinDoesNotReviveEndedMembership(t *testing.T) {
+	pool, queries := testDB(t)
+	s := newTestCompanyService(pool, queries, nil)
+	ctx := context.Background()
+
+	owner := newTestUser(t, queries, "example.com")
+	companyID := newTestCompany(t, s, owner.ID)
+	domain := generateToken(12) + ".example"
+
+	claim, err := s.ClaimDomain(ctx, owner.ID, companyID, domain, "member")
+	if err != nil {
+		t.Fatalf("ClaimDomain: %v", err)
+	}
+	s.resolver = fakeResolver{DomainVerificationRecordName(domain): {DomainVerificationRecordValue(claim)}}
+	if _, err := s.VerifyDomain(ctx, owner.ID, companyID, claim.ID); err != nil {
+		t.Fatalf("VerifyDomain: %v", err)
+	}
+
+	user := newTestUser(t, queries, domain)
+	if _, err := s.JoinVerifiedDomainCompanies(ctx, user.ID, user.Email); err != nil {
+		t.Fatalf("JoinVerifiedDomainCompanies: %v", err)
+	}
+	if err := queries.RemoveUserFromCompany(ctx, compiled.RemoveUserFromCompanyParams{CompanyID: companyID, UserID: user.ID}); err != nil {
+		t.Fatalf("remove member: %v", err)
+	}
+
+	// The next login must not bring the removed member back
+	joined, err := s.JoinVerifiedDomainCompanies(ctx, user.ID, user.Email)
+	if err != nil {
+		t.Fatalf("JoinVerifiedDomainCompanies after removal: %v", err)
+	}
+	if len(joined) != 0 {
+		t.Fatalf("joined = %v after removal, want none", joined)
+	}
+	isMember, err := queries.IsUserMemberOfCompany(ctx, compiled.IsUserMemberOfCompanyParams{CompanyID: companyID, UserID: user.ID})
+	if err != nil || isMember {
+		t.Fatalf("IsUserMemberOfCompany = %v, %v; want false", isMember, err)
+	}
+}
+
+func TestArchivedCompanyDomainsAreReadOnly(t *testing.T) {
+	pool, queries := testDB(t)
+	s := newTestCompanyService(pool, queries, nil)
+	s.resolver = fakeResolver{}
+	ctx := context.Background()
+
+	owner := newTestUser(t, queries, "example.com")
+	companyID := newTestCompany(t, s, owner.ID)
+	claim, err := s.ClaimDomain(ctx, owner.ID, companyID, generateToken(12)+".example", "member")
+	if err != nil {
+		t.Fatalf("ClaimDomain: %v", err)
+	}
+	if err := s.SetCompanyArchived(ctx, owner.ID, companyID, true); err != nil {
+		t.Fatalf("SetCompanyArchived: %v", err)
+	}
+
+	if _, err := s.VerifyDomain(ctx, owner.ID, companyID, claim.ID); !errors.Is(err, ErrCompanyArchived) {
+		t.Errorf("VerifyDomain = %v, want ErrCompanyArchived", err)
+	}
+	if err := s.RemoveDomain(ctx, owner.ID, companyID, claim.ID); !errors.Is(err, ErrCompanyArchived) {
+		t.Errorf("RemoveDomain = %v, want ErrCompanyArchived", err)
+	}
+}
@@ -0,0 +1,96 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"slices"
+
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"project/compiled"
+)
+
+var (
+	ErrCompanyHasParent = errors.New("company already has a parent company")
+	ErrCompanyNoParent  = errors.New("company has no parent company")
+	ErrCompanyCycle     = errors.New("a company cannot be placed under itself or one of its descendants")
+)
+
+// AttachChildCompany places childID under parentID. The caller must
+// administer both companies. Attaches run one at a time under the hierarchy
+// lock, so the ancestry check sees every committed link and concurrent
+// attaches cannot create a cycle.
+func (s *CompanyService) AttachChildCompany(ctx context.Context, adminID, parentID, childID int32) error {
+	if parentID == childID {
+		return ErrCompanyCycle
+	}
+	if err := s.requireAdmin(ctx, parentID, adminID); err != nil {
+		return err
+	}
+	if err := s.requireAdmin(ctx, childID, adminID); err != nil {
+		return err
+	}
+	if _, err := s.getWritableCompany(ctx, parentID); err != nil {
+		return err
+	}
+	if err := s.requireFeature(ctx, parentID, FeatureCompanyHierarchy); err != nil {
+		return err
+	}
+
+	return runInTx(ctx, s.pool, s.queries, func(q *compiled.Queries) error {
+		if err := q.LockCompanyHierarchy(ctx); err != nil {
+			return err
+		}
+		if _, err := q.LockCompanies(ctx, []int32{parentID, childID}); err != nil {
+			return err
+		}
+
+		child, err := q.GetCompanyByID(ctx, childID)
+		if err != nil {
+			return ErrCompanyNotFound
+		}
+		if child.ParentCompanyID.Valid {
+			return ErrCompanyHasParent
+		}
+
+		ancestors, err := q.GetCompanyAncestorIDs(ctx, parentID)
+		if err != nil {
+			return err
+		}
+		if slices.Contains(ancestors, childID) {
+			return ErrCompanyCycle
+		}
+
+		return q.SetCompanyParent(ctx, compiled.SetCompanyParentParams{
+			ParentCompanyID: pgtype.Int4{Int32: parentID, Valid: true},
+			ID:              childID,
+		})
+	})
+}
+
+// DetachChildCompany makes childID a top-level company again. Admins of the
+// child, including those inherited from the parent, may detach it.
+func (s *CompanyService) DetachChildCompany(ctx context.Context, adminID, childID int32) error {
+	if err := s.requireAdmin(ctx, childID, adminID); err != nil {
+		return err
+	}
+
+	child, err := s.getWritableCompany(ctx, childID)
+	if err != nil {
+		return err
+	}
+	if !child.ParentCompanyID.Valid {
+		return ErrCompanyNoParent
+	}
+
+	return s.queries.SetCompanyParent(ctx, compiled.SetCompanyParentParams{
+		ParentCompanyID: pgtype.Int4{},
+		ID:              childID,
+	})
+}
+
+// GetInheritedCompanies lists companies the user administers only through a
+// parent company.
+func (s *CompanyService) GetInheritedCompanies(ctx context.Context, userID int32) ([]compiled.GetInheritedCompaniesRow, error) {
+	return s.queries.GetInheritedCompanies(ctx, userID)
+}
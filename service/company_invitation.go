@@ -0,0 +1,333 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"project/compiled"
+)
+
+var (
+	ErrInvitationNotFound   = errors.New("invitation not found")
+	ErrInvitationPending    = errors.New("user already has a pending invitation to this company")
+	ErrInvitationNotPending = errors.New("invitation is no longer pending")
+	ErrInvitationExpired    = errors.New("invitation has expired")
+)
+
+const (
+	InvitationStatusPending  = "pending"
+	InvitationStatusAccepted = "accepted"
+	InvitationStatusDeclined = "declined"
+	InvitationStatusRevoked  = "revoked"
+	InvitationStatusExpired  = "expired"
+)
+
+func (s *CompanyService) requireAdmin(ctx context.Context, companyID, userID int32) error {
+	role, err := s.GetCompanyUserRole(ctx, companyID, userID)
+	if err != nil {
+		return ErrNotAdmin
+	}
+	if role != "admin" {
+		return ErrNotAdmin
+	}
+	return nil
+}
+
+// requireInviter allows admins to invite with any role, and members of the
+// teams named by the inviter_team_ids setting to invite members.
+func (s *CompanyService) requireInviter(ctx context.Context, companyID, userID int32, role string) error {
+	adminErr := s.requireAdmin(ctx, companyID, userID)
+	if adminErr == nil || role != "member" {
+		return adminErr
+	}
+
+	settings, err := s.CompanySettings(ctx, companyID)
+	if err != nil {
+		return err
+	}
+	for _, teamID := range settings.InviterTeamIDs {
+		ok, err := s.MatchesGrant(ctx, companyID, userID, GrantTarget{TeamID: teamID})
+		if err != nil {
+			return err
+		}
+		if ok {
+			return nil
+		}
+	}
+	return adminErr
+}
+
+func (s *CompanyService) ListInvitations(ctx context.Context, adminID, companyID int32) ([]compiled.Invitation, error) {
+	if err := s.requireAdmin(ctx, companyID, adminID); err != nil {
+		return nil, err
+	}
+
+	if err := s.queries.ExpireCompanyInvitations(ctx, companyID); err != nil {
+		return nil, err
+	}
+
+	return s.queries.ListCompanyInvitations(ctx, companyID)
+}
+
+func (s *CompanyService) RevokeInvitation(ctx context.Context, adminID, companyID, invitationID int32) error {
+	if err := s.requireAdmin(ctx, companyID, adminID); err != nil {
+		return err
+	}
+
+	invitation, err := s.getInvitation(ctx, companyID, invitationID)
+	if err != nil {
+		return err
+	}
+	if invitation.Status != InvitationStatusPending {
+		return ErrInvitationNotPending
+	}
+
+	if _, err := s.getWritableCompany(ctx, companyID); err != nil {
+		return err
+	}
+
+	return runInTx(ctx, s.pool, s.queries, func(q *compiled.Queries) error {
+		if err := q.UpdateInvitationStatus(ctx, compiled.UpdateInvitationStatusParams{
+			Status: InvitationStatusRevoked,
+			ID:     invitation.ID,
+		}); err != nil {
+			return err
+		}
+
+		return recordEvent(ctx, q, InvitationRevoked{
+			CompanyID:    companyID,
+			InvitationID: invitation.ID,
+			RevokedBy:    adminID,
+			Email:        invitation.Email,
+		})
+	})
+}
+
+// ResendInvitation issues a fresh token and expiry for a pending or expired
+// invitation and queues the email again. The previous link stops working.
+func (s *CompanyService) ResendInvitation(ctx context.Context, adminID, companyID, invitationID int32) (*compiled.Invitation, error) {
+	if err := s.requireAdmin(ctx, companyID, adminID); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.getWritableCompany(ctx, companyID); err != nil {
+		return nil, err
+	}
+
+	var invitation compiled.Invitation
+	err := runInTx(ctx, s.pool, s.queries, func(q *compiled.Queries) error {
+		var err error
+		invitation, err = q.RefreshInvitationToken(ctx, compiled.RefreshInvitationTokenParams{
+			Token:     generateToken(32),
+			ExpiresAt: pgtype.Timestamp{Time: time.Now().Add(s.invitationTTL), Valid: true},
+			ID:        invitationID,
+			CompanyID: companyID,
+		})
+		if errors.Is(err, pgx.ErrNoRows) {
+			if _, err := s.getInvitation(ctx, companyID, invitationID); err != nil {
+				return err
+			}
+			return ErrInvitationNotPending
+		}
+		if err != nil {
+			return err
+		}
+
+		return recordEvent(ctx, q, InvitationResent{
+			CompanyID:    companyID,
+			InvitationID: invitation.ID,
+			ResentBy:     adminID,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &invitation, nil
+}
+
+// AcceptInvitation adds the invitee to the company, creating their account if
+// they do not have one yet. Possession of the token proves control of the
+// invited email address.
+func (s *CompanyService) AcceptInvitation(ctx context.Context, token string) (*compiled.Invitation, error) {
+	var invitation compiled.Invitation
+	var signedUpID int32
+	err := runInTx(ctx, s.pool, s.queries, func(q *compiled.Queries) error {
+		var err error
+		invitation, err = openInvitation(ctx, q, token)
+		if err != nil {
+			return err
+		}
+
+		if _, err := s.getWritableCompany(ctx, invitation.CompanyID); err != nil {
+			return err
+		}
+
+		user, err := q.FindUserByEmail(ctx, invitation.Email)
+		if errors.Is(err, pgx.ErrNoRows) {
+			newUser, err := q.CreateUser(ctx, compiled.CreateUserParams{
+				Email:             invitation.Email,
+				Name:              invitation.Name,
+				SelectedCompanyID: pgtype.Int4{Int32: invitation.CompanyID, Valid: true},
+			})
+			if err != nil {
+				return err
+			}
+			if _, err := q.AddUserToCompany(ctx, compiled.AddUserToCompanyParams{
+				CompanyID: invitation.CompanyID,
+				UserID:    newUser.ID,
+				Role:      invitation.Role,
+			}); err != nil {
+				return err
+			}
+			if err := recordMemberEvent(ctx, q, WebhookEventMemberJoined, invitation.CompanyID, MemberEvent{
+				UserID: newUser.ID,
+				Role:   invitation.Role,
+				Source: "invitation",
+			}); err != nil {
+				return err
+			}
+			signedUpID = newUser.ID
+		} else if err != nil {
+			return err
+		} else {
+			isMember, err := q.IsUserMemberOfCompany(ctx, compiled.IsUserMemberOfCompanyParams{
+				CompanyID: invitation.CompanyID,
+				UserID:    user.ID,
+			})
+			if err != nil {
+				return err
+			}
+			if !isMember {
+				if _, err := q.AddUserToCompany(ctx, compiled.AddUserToCompanyParams{
+					CompanyID: invitation.CompanyID,
+					UserID:    user.ID,
+					Role:      invitation.Role,
+				}); err != nil {
+					return err
+				}
+				if err := recordMemberEvent(ctx, q, WebhookEventMemberJoined, invitation.CompanyID, MemberEvent{
+					UserID: user.ID,
+					Role:   invitation.Role,
+					Source: "invitation",
+				}); err != nil {
+					return err
+				}
+			}
+		}
+
+		invitation.Status = InvitationStatusAccepted
+		return q.UpdateInvitationStatus(ctx, compiled.UpdateInvitationStatusParams{
+			Status: InvitationStatusAccepted,
+			ID:     invitation.ID,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// New accounts also join companies that verified their email domain.
+	// The invitation is already accepted, so failures here are not fatal.
+	if signedUpID != 0 {
+		_, _ = s.JoinVerifiedDomainCompanies(ctx, signedUpID, invitation.Email)
+	}
+
+	return &invitation, nil
+}
+
+func (s *CompanyService) DeclineInvitation(ctx context.Context, token string) error {
+	return runInTx(ctx, s.pool, s.queries, func(q *compiled.Queries) error {
+		invitation, err := openInvitation(ctx, q, token)
+		if err != nil {
+			return err
+		}
+
+		return q.UpdateInvitationStatus(ctx, compiled.UpdateInvitationStatusParams{
+			Status: InvitationStatusDeclined,
+			ID:     invitation.ID,
+		})
+	})
+}
+
+// openInvitation locks the invitation behind token and checks that it can
+// still be answered.
+func openInvitation(ctx context.Context, q *compiled.Queries, token string) (compiled.Invitation, error) {
+	invitation, err := q.GetInvitationByTokenForUpdate(ctx, token)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return invitation, ErrInvitationNotFound
+	}
+	if err != nil {
+		return invitation, err
+	}
+
+	switch {
+	case invitation.Status == InvitationStatusExpired:
+		return invitation, ErrInvitationExpired
+	case invitation.Status != InvitationStatusPending:
+		return invitation, ErrInvitationNotPending
+	case time.Now().After(invitation.ExpiresAt.Time):
+		return invitation, ErrInvitationExpired
+	}
+
+	return invitation, nil
+}
+
+func (s *CompanyService) getInvitation(ctx context.Context, companyID, invitationID int32) (compiled.Invitation, error) {
+	invitation, err := s.queries.GetInvitationByID(ctx, compiled.GetInvitationByIDParams{
+		ID:        invitationID,
+		CompanyID: companyID,
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return invitation, ErrInvitationNotFound
+	}
+	return invitation, err
+}
+
+// Subscribe emails invitations when they are created or resent. The email
+// carries the invitation's current link, so it is only sent while the
+// invitation can still be accepted.
+func (s *CompanyService) Subscribe(bus *EventBus) {
+	Subscribe(bus, "invitation-emails", func(ctx context.Context, meta EventMeta, e MemberInvited) error {
+		return s.emailInvitation(ctx, e.CompanyID, e.InvitationID)
+	})
+	Subscribe(bus, "invitation-emails", func(ctx context.Context, meta EventMeta, e InvitationResent) error {
+		return s.emailInvitation(ctx, e.CompanyID, e.InvitationID)
+	})
+}
+
+func (s *CompanyService) emailInvitation(ctx context.Context, companyID, invitationID int32) error {
+	invitation, err := s.getInvitation(ctx, companyID, invitationID)
+	if errors.Is(err, ErrInvitationNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if invitation.Status != InvitationStatusPending || time.Now().After(invitation.ExpiresAt.Time) {
+		return nil
+	}
+
+	company, err := s.queries.GetCompanyByID(ctx, companyID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return s.sendInvitationEmail(ctx, &invitation, company.CompanyName)
+}
+
+func (s *CompanyService) sendInvitationEmail(ctx context.Context, invitation *compiled.Invitation, companyName string) error {
+	link := fmt.Sprintf("%s/invitations/accept?token=%s", s.appURL, url.QueryEscape(invitation.Token))
+	return s.mailer.Send(ctx, invitation.Email,
+		fmt.Sprintf("You have been invited to join %s", companyName),
+		fmt.Sprintf("Hi %s,\n\nYou have been invited to join %s as %s.\nAccept the invitation: %s\n\nThis link expires on %s.",
+			invitation.Name, companyName, invitation.Role, link, invitation.ExpiresAt.Time.Format("2006-01-02 15:04 MST")),
+	)
+}
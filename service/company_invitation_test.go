@@ -0,0 +1,95 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// recordingMailer keeps every message it is asked to send.
+type recordingMailer struct {
+	mu   sync.Mutex
+	sent []string
+}
+
+func (m *recordingMailer) Send(ctx context.Context, to, subject, text string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = append(m.sent, to+"\n"+text)
+	return nil
+}
+
+func (m *recordingMailer) messages() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]string(nil), m.sent...)
+}
+
+func TestInvitationEmailIsSentBySubscriber(t *testing.T) {
+	pool, queries := testDB(t)
+	s := newTestCompanyService(pool, queries, nil)
+	mailer := &recordingMailer{}
+	s.mailer = mailer
+	ctx := context.Background()
+
+	owner := newTestUser(t, queries, "example.com")
+	companyID := newTestCompany(t, s, owner.ID)
+	email := "invitee-" + generateToken(12) + "@example.com"
+
+	invitation, err := s.InviteUser(ctx, owner.ID, companyID, email, "Invitee", "member")
+	if err != nil {
+		t.Fatalf("InviteUser: %v", err)
+	}
+	if sent := mailer.messages(); len(sent) != 0 {
+		t.Fatalf("InviteUser sent %d emails itself, want none", len(sent))
+	}
+
+	if err := s.emailInvitation(ctx, companyID, invitation.ID); err != nil {
+		t.Fatalf("emailInvitation: %v", err)
+	}
+	sent := mailer.messages()
+	if len(sent) != 1 || !strings.HasPrefix(sent[0], email+"\n") || !strings.Contains(sent[0], invitation.Token) {
+		t.Fatalf("sent = %q, want one email to %s with the invitation link", sent, email)
+	}
+
+	// A revoked invitation is not emailed when the event is redelivered
+	if err := s.RevokeInvitation(ctx, owner.ID, companyID, invitation.ID); err != nil {
+		t.Fatalf("RevokeInvitation: %v", err)
+	}
+	if err := s.emailInvitation(ctx, companyID, invitation.ID); err != nil {
+		t.Fatalf("emailInvitation after revoke: %v", err)
+	}
+	if sent := mailer.messages(); len(sent) != 1 {
+		t.Fatalf("sent %d emails, want the revoked invitation skipped", len(sent))
+	}
+}
+
+func TestAcceptInvitationAfterAccountDeletion(t *testing.T) {
+	pool, queries := testDB(t)
+	s := newTestCompanyService(pool, queries, nil)
+	ctx := context.Background()
+
+	owner := newTestUser(t, queries, "example.com")
+	companyID := newTestCompany(t, s, owner.ID)
+	deleted := newTestUser(t, queries, "example.com")
+	if _, err := queries.SoftDeleteUser(ctx, deleted.ID); err != nil {
+		t.Fatalf("SoftDeleteUser: %v", err)
+	}
+
+	// A fresh account is created for the address, whatever its case
+	invitation, err := s.InviteUser(ctx, owner.ID, companyID, strings.ToUpper(deleted.Email), "Invitee", "member")
+	if err != nil {
+		t.Fatalf("InviteUser: %v", err)
+	}
+	if _, err := s.AcceptInvitation(ctx, invitation.Token); err != nil {
+		t.Fatalf("AcceptInvitation: %v", err)
+	}
+	user, err := queries.FindUserByEmail(ctx, deleted.Email)
+	if err != nil {
+		t.Fatalf("FindUserByEmail: %v", err)
+	}
+	if user.ID == deleted.ID {
+		t.Errorf("invitation was accepted by the deleted account %d", deleted.ID)
+	}
+}
@@ -0,0 +1,203 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"project/compiled"
+)
+
+var (
+	ErrJoinLinkNotFound    = errors.New("join link not found")
+	ErrJoinLinkDisabled    = errors.New("join link is disabled")
+	ErrJoinLinkExpired     = errors.New("join link has expired")
+	ErrJoinLinkExhausted   = errors.New("join link has reached its maximum number of uses")
+	ErrJoinLinkEmailDomain = errors.New("email domain is not allowed by this join link")
+)
+
+type JoinLinkOptions struct {
+	Role        string
+	MaxUses     int32 // 0 means unlimited
+	EmailDomain string
+	ExpiresAt   time.Time // zero means never
+}
+
+func (s *CompanyService) CreateJoinLink(ctx context.Context, adminID, companyID int32, opts JoinLinkOptions) (*compiled.CompanyJoinLink, error) {
+	if err := s.requireAdmin(ctx, companyID, adminID); err != nil {
+		return nil, err
+	}
+	if _, err := s.getWritableCompany(ctx, companyID); err != nil {
+		return nil, err
+	}
+	if err := s.requireFeature(ctx, companyID, FeatureJoinLinks); err != nil {
+		return nil, err
+	}
+
+	link, err := s.queries.CreateJoinLink(ctx, compiled.CreateJoinLinkParams{
+		CompanyID:   companyID,
+		Token:       generateToken(32),
+		Role:        opts.Role,
+		MaxUses:     pgtype.Int4{Int32: opts.MaxUses, Valid: opts.MaxUses > 0},
+		EmailDomain: pgtype.Text{String: strings.ToLower(opts.EmailDomain), Valid: opts.EmailDomain != ""},
+		ExpiresAt:   pgtype.Timestamp{Time: opts.ExpiresAt, Valid: !opts.ExpiresAt.IsZero()},
+		CreatedBy:   pgtype.Int4{Int32: adminID, Valid: true},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &link, nil
+}
+
+func (s *CompanyService) ListJoinLinks(ctx context.Context, adminID, companyID int32) ([]compiled.CompanyJoinLink, error) {
+	if err := s.requireAdmin(ctx, companyID, adminID); err != nil {
+		return nil, err
+	}
+	return s.queries.ListCompanyJoinLinks(ctx, companyID)
+}
+
+func (s *CompanyService) DisableJoinLink(ctx context.Context, adminID, companyID, linkID int32) error {
+	if err := s.requireAdmin(ctx, companyID, adminID); err != nil {
+		return err
+	}
+	if _, err := s.getWritableCompany(ctx, companyID); err != nil {
+		return err
+	}
+
+	rows, err := s.queries.DisableJoinLink(ctx, compiled.DisableJoinLinkParams{
+		ID:        linkID,
+		CompanyID: companyID,
+	})
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrJoinLinkNotFound
+	}
+	return nil
+}
+
+// JoinCompanyByLink adds the user to the link's company with the link's role,
+// consuming one use of the link in the same transaction.
+func (s *CompanyService) JoinCompanyByLink(ctx context.Context, userID int32, email, token string) (*compiled.GetCompanyByIDRow, string, error) {
+	link, err := s.queries.GetJoinLinkByToken(ctx, token)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, "", ErrJoinLinkNotFound
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	if err := checkJoinLink(&link); err != nil {
+		return nil, "", err
+	}
+	if link.EmailDomain.Valid && !emailHasDomain(email, link.EmailDomain.String) {
+		return nil, "", ErrJoinLinkEmailDomain
+	}
+	allowed, err := s.EmailDomainAllowed(ctx, link.CompanyID, email)
+	if err != nil {
+		return nil, "", err
+	}
+	if !allowed {
+		return nil, "", ErrEmailDomainBlocked
+	}
+
+	company, err := s.getWritableCompany(ctx, link.CompanyID)
+	if errors.Is(err, ErrCompanyNotFound) {
+		return nil, "", ErrJoinLinkNotFound
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	// Links stop working when the company moves to a plan without them
+	if err := s.requireFeature(ctx, link.CompanyID, FeatureJoinLinks); err != nil {
+		return nil, "", err
+	}
+
+	err = runInTx(ctx, s.pool, s.queries, func(q *compiled.Queries) error {
+		isMember, err := q.IsUserMemberOfCompany(ctx, compiled.IsUserMemberOfCompanyParams{
+			CompanyID: link.CompanyID,
+			UserID:    userID,
+		})
+		if err != nil {
+			return err
+		}
+		if isMember {
+			return ErrUserAlreadyMember
+		}
+
+		if err := s.reserveSeat(ctx, q, link.CompanyID); err != nil {
+			return err
+		}
+
+		claimed, err := q.ClaimJoinLinkUse(ctx, link.ID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			// Lost the race for the last use, or the link changed meanwhile
+			current, err := q.GetJoinLinkByToken(ctx, token)
+			if err != nil {
+				return err
+			}
+			if err := checkJoinLink(&current); err != nil {
+				return err
+			}
+			return ErrJoinLinkExhausted
+		}
+		if err != nil {
+			return err
+		}
+
+		_, err = q.AddUserToCompany(ctx, compiled.AddUserToCompanyParams{
+			CompanyID: claimed.CompanyID,
+			UserID:    userID,
+			Role:      claimed.Role,
+		})
+		if errors.Is(err, pgx.ErrNoRows) {
+			// Joined concurrently through another request
+			return ErrUserAlreadyMember
+		}
+		if err != nil {
+			return err
+		}
+
+		return recordMemberEvent(ctx, q, WebhookEventMemberJoined, claimed.CompanyID, MemberEvent{
+			UserID: userID,
+			Role:   claimed.Role,
+			Source: "join_link",
+		})
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	return &company, link.Role, nil
+}
+
+func (s *CompanyService) JoinLinkURL(token string) string {
+	return fmt.Sprintf("%s/join?token=%s", s.appURL, url.QueryEscape(token))
+}
+
+func checkJoinLink(link *compiled.CompanyJoinLink) error {
+	switch {
+	case link.DisabledAt.Valid:
+		return ErrJoinLinkDisabled
+	case link.ExpiresAt.Valid && time.Now().After(link.ExpiresAt.Time):
+		return ErrJoinLinkExpired
+	case link.MaxUses.Valid && link.UseCount >= link.MaxUses.Int32:
+		return ErrJoinLinkExhausted
+	}
+	return nil
+}
+
+func emailHasDomain(email, domain string) bool {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return false
+	}
+	return strings.EqualFold(email[at+1:], domain)
+}
@@ -0,0 +1,249 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"project/compiled"
+)
+
+const (
+	JoinRequestStatusPending  = "pending"
+	JoinRequestStatusApproved = "approved"
+	JoinRequestStatusRejected = "rejected"
+
+	// A rejected user has to wait this long before asking the same company
+	// again, so admins are not flooded with repeat requests.
+	joinRequestCooldown = 30 * 24 * time.Hour
+)
+
+var (
+	ErrJoinRequestNotFound   = errors.New("join request not found")
+	ErrJoinRequestPending    = errors.New("a join request for this company is already pending")
+	ErrJoinRequestNotPending = errors.New("join request is no longer pending")
+	ErrJoinRequestCooldown   = errors.New("a recent join request for this company was rejected; try again later")
+)
+
+// RequestToJoinCompany records the user's request. The company's admins are
+// notified by the NotificationService. Users whose last request was rejected
+// must wait out joinRequestCooldown before asking again.
+func (s *CompanyService) RequestToJoinCompany(ctx context.Context, userID, companyID int32, message string) (*compiled.JoinRequest, error) {
+	if _, err := s.getWritableCompany(ctx, companyID); err != nil {
+		return nil, err
+	}
+
+	isMember, err := s.queries.IsUserMemberOfCompany(ctx, compiled.IsUserMemberOfCompanyParams{
+		CompanyID: companyID,
+		UserID:    userID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if isMember {
+		return nil, ErrUserAlreadyMember
+	}
+
+	rejectedAt, err := s.queries.GetLastJoinRequestRejection(ctx, compiled.GetLastJoinRequestRejectionParams{
+		CompanyID: companyID,
+		UserID:    userID,
+	})
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return nil, err
+	}
+	if rejectedAt.Valid && time.Since(rejectedAt.Time) < joinRequestCooldown {
+		return nil, ErrJoinRequestCooldown
+	}
+
+	var request compiled.JoinRequest
+	err = runInTx(ctx, s.pool, s.queries, func(q *compiled.Queries) error {
+		var err error
+		request, err = q.CreateJoinRequest(ctx, compiled.CreateJoinRequestParams{
+			CompanyID: companyID,
+			UserID:    userID,
+			Message:   message,
+		})
+		if isUniqueViolation(err) {
+			return ErrJoinRequestPending
+		}
+		if err != nil {
+			return err
+		}
+
+		// Admins are notified from the event
+		return recordEvent(ctx, q, JoinRequested{
+			CompanyID: companyID,
+			RequestID: request.ID,
+			UserID:    userID,
+			Message:   message,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &request, nil
+}
+
+func (s *CompanyService) ListJoinRequests(ctx context.Context, adminID, companyID int32) ([]compiled.ListPendingJoinRequestsRow, error) {
+	if err := s.requireAdmin(ctx, companyID, adminID); err != nil {
+		return nil, err
+	}
+	return s.queries.ListPendingJoinRequests(ctx, companyID)
+}
+
+// ApproveJoinRequest adds the requester to the company with role, or the
+// company's default invite role when role is empty. Requesters whose email
+// domain the company no longer allows cannot be approved. The requester is
+// notified by the NotificationService.
+func (s *CompanyService) ApproveJoinRequest(ctx context.Context, adminID, companyID, requestID int32, role string) (*compiled.JoinRequest, error) {
+	if err := s.requireAdmin(ctx, companyID, adminID); err != nil {
+		return nil, err
+	}
+	if _, err := s.getWritableCompany(ctx, companyID); err != nil {
+		return nil, err
+	}
+
+	if role == "" {
+		settings, err := s.CompanySettings(ctx, companyID)
+		if err != nil {
+			return nil, err
+		}
+		role = settings.DefaultInviteRole
+	}
+
+	var request compiled.JoinRequest
+	err := runInTx(ctx, s.pool, s.queries, func(q *compiled.Queries) error {
+		var err error
+		request, err = openJoinRequest(ctx, q, companyID, requestID)
+		if err != nil {
+			return err
+		}
+
+		isMember, err := q.IsUserMemberOfCompany(ctx, compiled.IsUserMemberOfCompanyParams{
+			CompanyID: companyID,
+			UserID:    request.UserID,
+		})
+		if err != nil {
+			return err
+		}
+		if !isMember {
+			requester, err := q.GetUserByID(ctx, request.UserID)
+			if err != nil {
+				return err
+			}
+			allowed, err := s.EmailDomainAllowed(ctx, companyID, requester.Email)
+			if err != nil {
+				return err
+			}
+			if !allowed {
+				return ErrEmailDomainBlocked
+			}
+
+			if err := s.reserveSeat(ctx, q, companyID); err != nil {
+				return err
+			}
+			if _, err := q.AddUserToCompany(ctx, compiled.AddUserToCompanyParams{
+				CompanyID: companyID,
+				UserID:    request.UserID,
+				Role:      role,
+			}); err != nil {
+				return err
+			}
+			if err := recordMemberEvent(ctx, q, WebhookEventMemberJoined, companyID, MemberEvent{
+				UserID:  request.UserID,
+				Role:    role,
+				Source:  "join_request",
+				ActorID: adminID,
+			}); err != nil {
+				return err
+			}
+		}
+
+		request.Status = JoinRequestStatusApproved
+		if err := q.ReviewJoinRequest(ctx, compiled.ReviewJoinRequestParams{
+			Status:     JoinRequestStatusApproved,
+			ReviewedBy: pgtype.Int4{Int32: adminID, Valid: true},
+			ID:         request.ID,
+		}); err != nil {
+			return err
+		}
+
+		return recordEvent(ctx, q, JoinRequestReviewed{
+			CompanyID:  companyID,
+			RequestID:  request.ID,
+			UserID:     request.UserID,
+			Status:     JoinRequestStatusApproved,
+			Role:       role,
+			ReviewedBy: adminID,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &request, nil
+}
+
+// RejectJoinRequest declines the request. The requester is notified by the
+// NotificationService.
+func (s *CompanyService) RejectJoinRequest(ctx context.Context, adminID, companyID, requestID int32) (*compiled.JoinRequest, error) {
+	if err := s.requireAdmin(ctx, companyID, adminID); err != nil {
+		return nil, err
+	}
+	if _, err := s.getWritableCompany(ctx, companyID); err != nil {
+		return nil, err
+	}
+
+	var request compiled.JoinRequest
+	err := runInTx(ctx, s.pool, s.queries, func(q *compiled.Queries) error {
+		var err error
+		request, err = openJoinRequest(ctx, q, companyID, requestID)
+		if err != nil {
+			return err
+		}
+
+		request.Status = JoinRequestStatusRejected
+		if err := q.ReviewJoinRequest(ctx, compiled.ReviewJoinRequestParams{
+			Status:     JoinRequestStatusRejected,
+			ReviewedBy: pgtype.Int4{Int32: adminID, Valid: true},
+			ID:         request.ID,
+		}); err != nil {
+			return err
+		}
+
+		return recordEvent(ctx, q, JoinRequestReviewed{
+			CompanyID:  companyID,
+			RequestID:  request.ID,
+			UserID:     request.UserID,
+			Status:     JoinRequestStatusRejected,
+			ReviewedBy: adminID,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &request, nil
+}
+
+// openJoinRequest locks the request and checks that it is still pending.
+func openJoinRequest(ctx context.Context, q *compiled.Queries, companyID, requestID int32) (compiled.JoinRequest, error) {
+	request, err := q.GetJoinRequestForUpdate(ctx, compiled.GetJoinRequestForUpdateParams{
+		ID:        requestID,
+		CompanyID: companyID,
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return request, ErrJoinRequestNotFound
+	}
+	if err != nil {
+		return request, err
+	}
+	if request.Status != JoinRequestStatusPending {
+		return request, ErrJoinRequestNotPending
+	}
+	return request, nil
+}
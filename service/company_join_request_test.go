@@ -0,0 +1,70 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"project/compiled"
+)
+
+func TestRequestToJoinCompanyCooldownAfterRejection(t *testing.T) {
+	pool, queries := testDB(t)
+	s := newTestCompanyService(pool, queries, nil)
+	ctx := context.Background()
+
+	owner := newTestUser(t, queries, "example.com")
+	companyID := newTestCompany(t, s, owner.ID)
+	requester := newTestUser(t, queries, "example.com")
+
+	request, err := s.RequestToJoinCompany(ctx, requester.ID, companyID, "hello")
+	if err != nil {
+		t.Fatalf("RequestToJoinCompany: %v", err)
+	}
+	if _, err := s.RequestToJoinCompany(ctx, requester.ID, companyID, "hello again"); !errors.Is(err, ErrJoinRequestPending) {
+		t.Fatalf("second pending request = %v, want ErrJoinRequestPending", err)
+	}
+
+	if _, err := s.RejectJoinRequest(ctx, owner.ID, companyID, request.ID); err != nil {
+		t.Fatalf("RejectJoinRequest: %v", err)
+	}
+	if _, err := s.RequestToJoinCompany(ctx, requester.ID, companyID, "please"); !errors.Is(err, ErrJoinRequestCooldown) {
+		t.Fatalf("request right after rejection = %v, want ErrJoinRequestCooldown", err)
+	}
+
+	// The cooldown is per company
+	otherID := newTestCompany(t, s, owner.ID)
+	if _, err := s.RequestToJoinCompany(ctx, requester.ID, otherID, "hello"); err != nil {
+		t.Fatalf("RequestToJoinCompany(other company): %v", err)
+	}
+}
+
+func TestApproveJoinRequestChecksEmailDomain(t *testing.T) {
+	pool, queries := testDB(t)
+	s := newTestCompanyService(pool, queries, nil)
+	ctx := context.Background()
+
+	owner := newTestUser(t, queries, "example.com")
+	companyID := newTestCompany(t, s, owner.ID)
+	requester := newTestUser(t, queries, "other.example")
+
+	request, err := s.RequestToJoinCompany(ctx, requester.ID, companyID, "hello")
+	if err != nil {
+		t.Fatalf("RequestToJoinCompany: %v", err)
+	}
+	// The domain is restricted after the request came in
+	if _, err := s.UpdateCompanySettings(ctx, owner.ID, companyID, CompanySettings{AllowedEmailDomains: []string{"example.com"}}, []string{"allowed_email_domains"}); err != nil {
+		t.Fatalf("UpdateCompanySettings: %v", err)
+	}
+
+	if _, err := s.ApproveJoinRequest(ctx, owner.ID, companyID, request.ID, "member"); !errors.Is(err, ErrEmailDomainBlocked) {
+		t.Fatalf("ApproveJoinRequest = %v, want ErrEmailDomainBlocked", err)
+	}
+	isMember, err := queries.IsUserMemberOfCompany(ctx, compiled.IsUserMemberOfCompanyParams{CompanyID: companyID, UserID: requester.ID})
+	if err != nil {
+		t.Fatalf("IsUserMemberOfCompany: %v", err)
+	}
+	if isMember {
+		t.Error("requester from a blocked domain was added to the company")
+	}
+}
@@ -0,0 +1,175 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"project/compiled"
+)
+
+const (
+	defaultMemberPageSize = 50
+	maxMemberPageSize     = 200
+)
+
+const (
+	MemberOrderName       = "name"
+	MemberOrderEmail      = "email"
+	MemberOrderJoined     = "joined_at"
+	MemberOrderJoinedDesc = "joined_at desc"
+)
+
+var (
+	ErrInvalidOrderBy   = errors.New("order_by must be one of: name, email, joined_at, joined_at desc")
+	ErrInvalidPageToken = errors.New("page_token is invalid or does not match the request")
+	ErrInvalidPageSize  = errors.New("page_size must not be negative")
+)
+
+type CompanyMember struct {
+	ID       int32
+	Name     string
+	Email    string
+	Role     string
+	JoinedAt pgtype.Timestamp
+
+	sortKey string
+}
+
+type MemberListOptions struct {
+	PageSize  int32
+	PageToken string
+	OrderBy   string
+	Role      string
+	Query     string
+}
+
+type MemberPage struct {
+	Members       []CompanyMember
+	NextPageToken string
+}
+
+// memberPageToken is the opaque AIP-158 page token. It records the ordering
+// and filters it was issued for so it cannot be replayed against a
+// different query.
+type memberPageToken struct {
+	OrderBy string    `json:"o"`
+	Filter  string    `json:"f"`
+	Key     string    `json:"k,omitempty"`
+	Joined  time.Time `json:"j,omitzero"`
+	ID      int32     `json:"i"`
+}
+
+// ListCompanyMembers returns one page of members using keyset pagination.
+func (s *CompanyService) ListCompanyMembers(ctx context.Context, companyID int32, opts MemberListOptions) (*MemberPage, error) {
+	if opts.PageSize < 0 {
+		return nil, ErrInvalidPageSize
+	}
+	pageSize := opts.PageSize
+	if pageSize == 0 {
+		pageSize = defaultMemberPageSize
+	}
+	pageSize = min(pageSize, maxMemberPageSize)
+
+	orderBy := strings.ToLower(strings.Join(strings.Fields(opts.OrderBy), " "))
+	if orderBy == "" {
+		orderBy = MemberOrderName
+	}
+	switch orderBy {
+	case MemberOrderName, MemberOrderEmail, MemberOrderJoined, MemberOrderJoinedDesc:
+	default:
+		return nil, ErrInvalidOrderBy
+	}
+
+	filter := opts.Role + "\x00" + opts.Query
+	var after memberPageToken
+	if opts.PageToken != "" {
+		if err := decodePageToken(opts.PageToken, &after); err != nil || after.OrderBy != orderBy || after.Filter != filter {
+			return nil, ErrInvalidPageToken
+		}
+	}
+
+	role := pgtype.Text{String: opts.Role, Valid: opts.Role != ""}
+	search := pgtype.Text{String: escapeLike(opts.Query), Valid: opts.Query != ""}
+	afterKey := pgtype.Text{String: after.Key, Valid: opts.PageToken != ""}
+	afterJoined := pgtype.Timestamp{Time: after.Joined, Valid: opts.PageToken != ""}
+	limit := pageSize + 1
+
+	var members []CompanyMember
+	switch orderBy {
+	case MemberOrderName:
+		rows, err := s.queries.ListCompanyMembersByName(ctx, compiled.ListCompanyMembersByNameParams{
+			CompanyID: companyID, Role: role, Search: search, AfterKey: afterKey, AfterID: after.ID, PageLimit: limit,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range rows {
+			members = append(members, CompanyMember{ID: r.ID, Name: r.Name, Email: r.Email, Role: r.Role, JoinedAt: r.JoinedAt, sortKey: r.SortKey})
+		}
+	case MemberOrderEmail:
+		rows, err := s.queries.ListCompanyMembersByEmail(ctx, compiled.ListCompanyMembersByEmailParams{
+			CompanyID: companyID, Role: role, Search: search, AfterKey: afterKey, AfterID: after.ID, PageLimit: limit,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range rows {
+			members = append(members, CompanyMember{ID: r.ID, Name: r.Name, Email: r.Email, Role: r.Role, JoinedAt: r.JoinedAt, sortKey: r.SortKey})
+		}
+	case MemberOrderJoined:
+		rows, err := s.queries.ListCompanyMembersByJoined(ctx, compiled.ListCompanyMembersByJoinedParams{
+			CompanyID: companyID, Role: role, Search: search, AfterJoined: afterJoined, AfterID: after.ID, PageLimit: limit,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range rows {
+			members = append(members, CompanyMember{ID: r.ID, Name: r.Name, Email: r.Email, Role: r.Role, JoinedAt: r.JoinedAt})
+		}
+	case MemberOrderJoinedDesc:
+		rows, err := s.queries.ListCompanyMembersByJoinedDesc(ctx, compiled.ListCompanyMembersByJoinedDescParams{
+			CompanyID: companyID, Role: role, Search: search, AfterJoined: afterJoined, AfterID: after.ID, PageLimit: limit,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range rows {
+			members = append(members, CompanyMember{ID: r.ID, Name: r.Name, Email: r.Email, Role: r.Role, JoinedAt: r.JoinedAt})
+		}
+	}
+
+	page := &MemberPage{Members: members}
+	if len(members) > int(pageSize) {
+		page.Members = members[:pageSize]
+		last := page.Members[pageSize-1]
+
+		next := memberPageToken{OrderBy: orderBy, Filter: filter, Key: last.sortKey, Joined: last.JoinedAt.Time, ID: last.ID}
+		page.NextPageToken = encodePageToken(next)
+	}
+
+	return page, nil
+}
+
+func encodePageToken(v any) string {
+	data, _ := json.Marshal(v)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodePageToken(token string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// escapeLike escapes LIKE wildcards so user input is matched literally.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -0,0 +1,107 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"project/compiled"
+)
+
+var (
+	ErrRemovedMemberNotFound  = errors.New("no restorable removed member found")
+	ErrDeletedCompanyNotFound = errors.New("no restorable deleted company found")
+)
+
+// ListRemovedMembers lists members removed by an admin within the restore
+// window. Members who left or deleted their account are not listed.
+func (s *CompanyService) ListRemovedMembers(ctx context.Context, adminID, companyID int32) ([]compiled.ListRemovedCompanyMembersRow, error) {
+	if err := s.requireAdmin(ctx, companyID, adminID); err != nil {
+		return nil, err
+	}
+	return s.queries.ListRemovedCompanyMembers(ctx, compiled.ListRemovedCompanyMembersParams{
+		CompanyID: companyID,
+		Since:     s.restoreCutoff(),
+	})
+}
+
+// RestoreMember brings back a member removed by an admin within the restore
+// window with their previous role. The restored member needs a free seat.
+func (s *CompanyService) RestoreMember(ctx context.Context, adminID, companyID, userID int32) (string, error) {
+	if err := s.requireAdmin(ctx, companyID, adminID); err != nil {
+		return "", err
+	}
+	if _, err := s.getWritableCompany(ctx, companyID); err != nil {
+		return "", err
+	}
+
+	var role string
+	err := runInTx(ctx, s.pool, s.queries, func(q *compiled.Queries) error {
+		if err := s.reserveSeat(ctx, q, companyID); err != nil {
+			return err
+		}
+
+		var err error
+		role, err = q.RestoreCompanyMember(ctx, compiled.RestoreCompanyMemberParams{
+			CompanyID: companyID,
+			UserID:    userID,
+			Since:     s.restoreCutoff(),
+		})
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrRemovedMemberNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		return recordMemberEvent(ctx, q, WebhookEventMemberJoined, companyID, MemberEvent{
+			UserID:  userID,
+			Role:    role,
+			Source:  "restore",
+			ActorID: adminID,
+		})
+	})
+	if err != nil {
+		return "", err
+	}
+	return role, nil
+}
+
+// ListDeletedCompanies lists the owner's companies deleted within the
+// restore window.
+func (s *CompanyService) ListDeletedCompanies(ctx context.Context, ownerID int32) ([]compiled.ListDeletedOwnedCompaniesRow, error) {
+	return s.queries.ListDeletedOwnedCompanies(ctx, compiled.ListDeletedOwnedCompaniesParams{
+		OwnerID: ownerID,
+		Since:   s.restoreCutoff(),
+	})
+}
+
+// RestoreCompany undeletes a company the user owns. Memberships come back
+// with it; invitations revoked and child companies detached at deletion do
+// not.
+func (s *CompanyService) RestoreCompany(ctx context.Context, ownerID, companyID int32) (*compiled.GetCompanyByIDRow, error) {
+	rows, err := s.queries.RestoreCompany(ctx, compiled.RestoreCompanyParams{
+		ID:      companyID,
+		OwnerID: ownerID,
+		Since:   s.restoreCutoff(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if rows == 0 {
+		return nil, ErrDeletedCompanyNotFound
+	}
+
+	company, err := s.queries.GetCompanyByID(ctx, companyID)
+	if err != nil {
+		return nil, err
+	}
+	return &company, nil
+}
+
+func (s *CompanyService) restoreCutoff() pgtype.Timestamp {
+	return pgtype.Timestamp{Time: time.Now().Add(-s.restoreWindow), Valid: true}
+}
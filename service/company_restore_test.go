@@ -0,0 +1,42 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestRestoreOnlyRemovedMembers(t *testing.T) {
+	pool, queries := testDB(t)
+	s := newTestCompanyService(pool, queries, nil)
+	ctx := context.Background()
+
+	owner := newTestUser(t, queries, "example.com")
+	companyID := newTestCompany(t, s, owner.ID)
+	removed := newTestUser(t, queries, "example.com")
+	left := newTestUser(t, queries, "example.com")
+	addTestMember(t, queries, companyID, removed.ID, "member")
+	addTestMember(t, queries, companyID, left.ID, "member")
+
+	if err := s.RemoveCompanyMember(ctx, owner.ID, companyID, removed.ID); err != nil {
+		t.Fatalf("RemoveCompanyMember: %v", err)
+	}
+	if err := s.LeaveCompany(ctx, left.ID, companyID); err != nil {
+		t.Fatalf("LeaveCompany: %v", err)
+	}
+
+	members, err := s.ListRemovedMembers(ctx, owner.ID, companyID)
+	if err != nil {
+		t.Fatalf("ListRemovedMembers: %v", err)
+	}
+	if len(members) != 1 || members[0].ID != removed.ID {
+		t.Fatalf("removed members = %+v, want only user %d", members, removed.ID)
+	}
+
+	if _, err := s.RestoreMember(ctx, owner.ID, companyID, left.ID); !errors.Is(err, ErrRemovedMemberNotFound) {
+		t.Errorf("RestoreMember of a member who left = %v, want ErrRemovedMemberNotFound", err)
+	}
+	if _, err := s.RestoreMember(ctx, owner.ID, companyID, removed.ID); err != nil {
+		t.Errorf("RestoreMember of a removed member: %v", err)
+	}
+}
@@ -3,8 +3,12 @@ package service
 import (
 	"context"
 	"errors"
+	"sync"
+	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
 
 	"project/compiled"
 )
@@ -15,14 +19,36 @@ var (
 	ErrNoSelectedCompany = errors.New("no company selected")
 	ErrUserAlreadyExists = errors.New("user already exists")
 	ErrUserAlreadyMember = errors.New("user is already a member of this company")
+	ErrCompanyNotFound   = errors.New("company not found")
+	ErrCompanyArchived   = errors.New("company is archived")
+	ErrNotOwner          = errors.New("user is not the owner of this company")
 )
 
 type CompanyService struct {
+	pool    *pgxpool.Pool
 	queries *compiled.Queries
+	mailer  Mailer
+	// payments is nil when billing is not configured; paid plans are then
+	// refused.
+	payments      PaymentProvider
+	resolver      TXTResolver
+	appURL        string
+	invitationTTL time.Duration
+	restoreWindow time.Duration
+	settingsCache sync.Map
 }
 
-func NewCompanyService(queries *compiled.Queries) *CompanyService {
-	return &CompanyService{queries: queries}
+func NewCompanyService(pool *pgxpool.Pool, queries *compiled.Queries, mailer Mailer, payments PaymentProvider, resolver TXTResolver, appURL string, invitationTTL, restoreWindow time.Duration) *CompanyService {
+	return &CompanyService{
+		pool:          pool,
+		queries:       queries,
+		mailer:        mailer,
+		payments:      payments,
+		resolver:      resolver,
+		appURL:        appURL,
+		invitationTTL: invitationTTL,
+		restoreWindow: restoreWindow,
+	}
 }
 
 func (s *CompanyService) CreateCompany(ctx context.Context, userID int32, companyName string) (*compiled.CreateCompanyRow, error) {
@@ -58,29 +84,34 @@ func (s *CompanyService) CreateCompany(ctx context.Context, userID int32, compan
 }
 
 func (s *CompanyService) SelectCompany(ctx context.Context, userID int32, companyID int32) (*compiled.GetCompanyByIDRow, error) {
-	// Check if user is member of this company
-	isMember, err := s.queries.IsUserMemberOfCompany(ctx, compiled.IsUserMemberOfCompanyParams{
-		CompanyID: companyID,
-		UserID:    userID,
-	})
-	if err != nil {
+	// Check if user is member of this company, directly or as an admin of
+	// a parent company
+	if _, err := s.GetCompanyUserRole(ctx, companyID, userID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotCompanyMember
+		}
 		return nil, err
 	}
-	if !isMember {
-		return nil, ErrNotCompanyMember
-	}
 
-	// Update selected company
-	err = s.queries.UpdateUserSelectedCompany(ctx, compiled.UpdateUserSelectedCompanyParams{
-		SelectedCompanyID: pgtype.Int4{Int32: companyID, Valid: true},
-		ID:                userID,
-	})
+	// Deleted companies keep their memberships but cannot be selected
+	company, err := s.queries.GetCompanyByID(ctx, companyID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrCompanyNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	// Return company info
-	company, err := s.queries.GetCompanyByID(ctx, companyID)
+	// Update selected company
+	err = runInTx(ctx, s.pool, s.queries, func(q *compiled.Queries) error {
+		if err := q.UpdateUserSelectedCompany(ctx, compiled.UpdateUserSelectedCompanyParams{
+			SelectedCompanyID: pgtype.Int4{Int32: companyID, Valid: true},
+			ID:                userID,
+		}); err != nil {
+			return err
+		}
+		return recordEvent(ctx, q, CompanySelected{UserID: userID, CompanyID: companyID})
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -96,89 +127,259 @@ func (s *CompanyService) IsUserOwner(ctx context.Context, userID int32) (bool, e
 	return s.queries.IsUserCompanyOwner(ctx, userID)
 }
 
-func (s *CompanyService) InviteUser(ctx context.Context, inviterID int32, selectedCompanyID int32, email, name, role string) (*compiled.CreateUserRow, error) {
-	// Check if inviter is admin in selected company
-	inviterRole, err := s.queries.GetCompanyUserRole(ctx, compiled.GetCompanyUserRoleParams{
-		CompanyID: selectedCompanyID,
-		UserID:    inviterID,
+func (s *CompanyService) InviteUser(ctx context.Context, inviterID int32, selectedCompanyID int32, email, name, role string) (*compiled.Invitation, error) {
+	if role == "" {
+		settings, err := s.CompanySettings(ctx, selectedCompanyID)
+		if err != nil {
+			return nil, err
+		}
+		role = settings.DefaultInviteRole
+	}
+
+	// Admins, or members of an inviter team when inviting members
+	if err := s.requireInviter(ctx, selectedCompanyID, inviterID, role); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.getWritableCompany(ctx, selectedCompanyID); err != nil {
+		return nil, err
+	}
+
+	var invitation compiled.Invitation
+	err := runInTx(ctx, s.pool, s.queries, func(q *compiled.Queries) error {
+		// Release the pending slot held by invitations that ran out
+		if err := q.ExpireCompanyInvitations(ctx, selectedCompanyID); err != nil {
+			return err
+		}
+
+		var err error
+		invitation, err = s.createInvitation(ctx, q, inviterID, selectedCompanyID, email, name, role)
+		return err
 	})
 	if err != nil {
-		return nil, ErrNotAdmin
+		return nil, err
+	}
+
+	return &invitation, nil
+}
+
+// createInvitation applies the invite rules for a single email and inserts the
+// invitation with q. Callers check admin rights; the email is sent by the
+// invitation-emails subscriber once the transaction commits.
+func (s *CompanyService) createInvitation(ctx context.Context, q *compiled.Queries, inviterID, companyID int32, email, name, role string) (compiled.Invitation, error) {
+	allowed, err := s.EmailDomainAllowed(ctx, companyID, email)
+	if err != nil {
+		return compiled.Invitation{}, err
 	}
-	if inviterRole != "admin" {
-		return nil, ErrNotAdmin
+	if !allowed {
+		return compiled.Invitation{}, ErrEmailDomainBlocked
 	}
 
-	// Check if user already exists
-	existingUser, err := s.queries.FindUserByEmail(ctx, email)
+	// Existing users must not already be a member
+	var existingUserID int32
+	existingUser, err := q.FindUserByEmail(ctx, email)
 	if err == nil {
-		// User exists, check if already member
-		isMember, err := s.queries.IsUserMemberOfCompany(ctx, compiled.IsUserMemberOfCompanyParams{
-			CompanyID: selectedCompanyID,
+		existingUserID = existingUser.ID
+		isMember, err := q.IsUserMemberOfCompany(ctx, compiled.IsUserMemberOfCompanyParams{
+			CompanyID: companyID,
 			UserID:    existingUser.ID,
 		})
 		if err != nil {
-			return nil, err
+			return compiled.Invitation{}, err
 		}
 		if isMember {
-			return nil, ErrUserAlreadyMember
+			return compiled.Invitation{}, ErrUserAlreadyMember
 		}
+	} else if !errors.Is(err, pgx.ErrNoRows) {
+		return compiled.Invitation{}, err
+	}
 
-		// Add existing user to company
-		_, err = s.queries.AddUserToCompany(ctx, compiled.AddUserToCompanyParams{
-			CompanyID: selectedCompanyID,
-			UserID:    existingUser.ID,
-			Role:      role,
-		})
-		if err != nil {
-			return nil, err
-		}
+	if _, err := q.GetPendingInvitationByEmail(ctx, compiled.GetPendingInvitationByEmailParams{
+		CompanyID: companyID,
+		Email:     email,
+	}); err == nil {
+		return compiled.Invitation{}, ErrInvitationPending
+	} else if !errors.Is(err, pgx.ErrNoRows) {
+		return compiled.Invitation{}, err
+	}
+
+	// A pending invitation holds a seat on the plan
+	if err := s.reserveSeat(ctx, q, companyID); err != nil {
+		return compiled.Invitation{}, err
+	}
 
-		return &compiled.CreateUserRow{
-			ID:                existingUser.ID,
-			Email:             existingUser.Email,
-			Name:              existingUser.Name,
-			SelectedCompanyID: pgtype.Int4{},
-			CreatedAt:         existingUser.CreatedAt,
-		}, nil
+	invitation, err := q.CreateInvitation(ctx, compiled.CreateInvitationParams{
+		CompanyID: companyID,
+		Email:     email,
+		Name:      name,
+		Role:      role,
+		Token:     generateToken(32),
+		InvitedBy: pgtype.Int4{Int32: inviterID, Valid: true},
+		ExpiresAt: pgtype.Timestamp{Time: time.Now().Add(s.invitationTTL), Valid: true},
+	})
+	if err != nil {
+		return compiled.Invitation{}, err
 	}
 
-	// Create new user with selected company set to the inviting company
-	newUser, err := s.queries.CreateUser(ctx, compiled.CreateUserParams{
-		Email:             email,
-		Name:              name,
-		SelectedCompanyID: pgtype.Int4{Int32: selectedCompanyID, Valid: true},
+	return invitation, recordEvent(ctx, q, MemberInvited{
+		CompanyID:    companyID,
+		InvitationID: invitation.ID,
+		InvitedBy:    inviterID,
+		UserID:       existingUserID,
+		Email:        email,
+		Name:         name,
+		Role:         role,
 	})
+}
+
+func (s *CompanyService) GetCompanyByID(ctx context.Context, companyID int32) (*compiled.GetCompanyByIDRow, error) {
+	company, err := s.queries.GetCompanyByID(ctx, companyID)
 	if err != nil {
 		return nil, err
 	}
+	return &company, nil
+}
 
-	// Add user to company
-	_, err = s.queries.AddUserToCompany(ctx, compiled.AddUserToCompanyParams{
-		CompanyID: selectedCompanyID,
-		UserID:    newUser.ID,
-		Role:      role,
+type CompanyUpdate struct {
+	CompanyName *string
+	Description *string
+	Website     *string
+}
+
+func (s *CompanyService) UpdateCompany(ctx context.Context, adminID, companyID int32, update CompanyUpdate) (*compiled.UpdateCompanyRow, error) {
+	if err := s.requireAdmin(ctx, companyID, adminID); err != nil {
+		return nil, err
+	}
+	if _, err := s.getWritableCompany(ctx, companyID); err != nil {
+		return nil, err
+	}
+
+	company, err := s.queries.UpdateCompany(ctx, compiled.UpdateCompanyParams{
+		CompanyName: optionalText(update.CompanyName),
+		Description: optionalText(update.Description),
+		Website:     optionalText(update.Website),
+		ID:          companyID,
 	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrCompanyNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	return &newUser, nil
+	return &company, nil
 }
 
-func (s *CompanyService) GetCompanyByID(ctx context.Context, companyID int32) (*compiled.GetCompanyByIDRow, error) {
+// SetCompanyArchived switches a company in or out of read-only mode.
+func (s *CompanyService) SetCompanyArchived(ctx context.Context, adminID, companyID int32, archived bool) error {
+	if err := s.requireAdmin(ctx, companyID, adminID); err != nil {
+		return err
+	}
+
+	archivedAt := pgtype.Timestamp{}
+	if archived {
+		archivedAt = pgtype.Timestamp{Time: time.Now(), Valid: true}
+	}
+
+	return s.queries.SetCompanyArchived(ctx, compiled.SetCompanyArchivedParams{
+		ArchivedAt: archivedAt,
+		ID:         companyID,
+	})
+}
+
+// DeleteCompany soft-deletes a company owned by ownerID. It returns the IDs of
+// users whose selected company was cleared so callers can refresh their
+// cached sessions.
+func (s *CompanyService) DeleteCompany(ctx context.Context, ownerID, companyID int32) ([]int32, error) {
 	company, err := s.queries.GetCompanyByID(ctx, companyID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrCompanyNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
-	return &company, nil
+	if company.OwnerID != ownerID {
+		return nil, ErrNotOwner
+	}
+
+	var affectedUserIDs []int32
+	err = runInTx(ctx, s.pool, s.queries, func(q *compiled.Queries) error {
+		rows, err := q.SoftDeleteCompany(ctx, companyID)
+		if err != nil {
+			return err
+		}
+		if rows == 0 {
+			return ErrCompanyNotFound
+		}
+
+		if err := q.RevokeCompanyInvitations(ctx, companyID); err != nil {
+			return err
+		}
+
+		if err := q.DetachChildCompanies(ctx, pgtype.Int4{Int32: companyID, Valid: true}); err != nil {
+			return err
+		}
+
+		affectedUserIDs, err = q.ClearSelectedCompany(ctx, pgtype.Int4{Int32: companyID, Valid: true})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return affectedUserIDs, nil
 }
 
+// getWritableCompany loads a company and refuses archived ones, for use by
+// every operation that mutates company state.
+func (s *CompanyService) getWritableCompany(ctx context.Context, companyID int32) (compiled.GetCompanyByIDRow, error) {
+	company, err := s.queries.GetCompanyByID(ctx, companyID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return company, ErrCompanyNotFound
+	}
+	if err != nil {
+		return company, err
+	}
+	if company.ArchivedAt.Valid {
+		return company, ErrCompanyArchived
+	}
+	return company, nil
+}
+
+func optionalText(v *string) pgtype.Text {
+	if v == nil {
+		return pgtype.Text{}
+	}
+	return pgtype.Text{String: *v, Valid: true}
+}
+
+// GetCompanyUserRole returns the user's effective role in the company: their
+// own membership role, or "admin" when they administer an ancestor company.
+// It returns pgx.ErrNoRows when the user has no access at all.
 func (s *CompanyService) GetCompanyUserRole(ctx context.Context, companyID, userID int32) (string, error) {
-	return s.queries.GetCompanyUserRole(ctx, compiled.GetCompanyUserRoleParams{
+	role, err := s.queries.GetCompanyUserRole(ctx, compiled.GetCompanyUserRoleParams{
 		CompanyID: companyID,
 		UserID:    userID,
 	})
+	if err == nil && role == "admin" {
+		return role, nil
+	}
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return "", err
+	}
+
+	inherited, ierr := s.queries.IsUserAdminOfAncestorCompany(ctx, compiled.IsUserAdminOfAncestorCompanyParams{
+		CompanyID: companyID,
+		UserID:    userID,
+	})
+	if ierr != nil {
+		return "", ierr
+	}
+	if inherited {
+		return "admin", nil
+	}
+
+	return role, err
 }
 
 func (s *CompanyService) GetCompanyMembers(ctx context.Context, companyID int32) ([]compiled.GetCompanyMembersRow, error) {
@@ -193,31 +394,182 @@ func (s *CompanyService) RemoveCompanyMember(ctx context.Context, adminID, compa
 	}
 
 	// Check if requester is admin
-	role, err := s.queries.GetCompanyUserRole(ctx, compiled.GetCompanyUserRoleParams{
-		CompanyID: companyID,
-		UserID:    adminID,
+	if err := s.requireAdmin(ctx, companyID, adminID); err != nil {
+		return err
+	}
+
+	if _, err := s.getWritableCompany(ctx, companyID); err != nil {
+		return err
+	}
+
+	return runInTx(ctx, s.pool, s.queries, func(q *compiled.Queries) error {
+		// Check if target user is a member
+		role, err := q.GetCompanyUserRole(ctx, compiled.GetCompanyUserRoleParams{
+			CompanyID: companyID,
+			UserID:    targetUserID,
+		})
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrNotCompanyMember
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := q.RemoveUserFromCompanyTeams(ctx, compiled.RemoveUserFromCompanyTeamsParams{
+			UserID:    targetUserID,
+			CompanyID: companyID,
+		}); err != nil {
+			return err
+		}
+
+		if err := q.RemoveUserFromCompany(ctx, compiled.RemoveUserFromCompanyParams{
+			CompanyID: companyID,
+			UserID:    targetUserID,
+			EndReason: pgtype.Text{String: MemberRemovedReasonRemoved, Valid: true},
+		}); err != nil {
+			return err
+		}
+
+		return recordMemberRemoved(ctx, q, companyID, targetUserID, role, adminID, MemberRemovedReasonRemoved)
 	})
+}
+
+var (
+	ErrCannotChangeOwnerRole = errors.New("the company owner's role cannot be changed")
+	ErrSameRole              = errors.New("member already has this role")
+)
+
+// ChangeMemberRole sets the direct role of a member and returns their
+// previous role. Demoting the last admin is refused.
+func (s *CompanyService) ChangeMemberRole(ctx context.Context, adminID, companyID, targetUserID int32, role string) (string, error) {
+	if role != "admin" && role != "member" {
+		return "", ErrInvalidRole
+	}
+	if err := s.requireAdmin(ctx, companyID, adminID); err != nil {
+		return "", err
+	}
+
+	company, err := s.getWritableCompany(ctx, companyID)
 	if err != nil {
-		return ErrNotAdmin
+		return "", err
 	}
-	if role != "admin" {
-		return ErrNotAdmin
+	if company.OwnerID == targetUserID {
+		return "", ErrCannotChangeOwnerRole
 	}
 
-	// Check if target user is a member
-	isMember, err := s.queries.IsUserMemberOfCompany(ctx, compiled.IsUserMemberOfCompanyParams{
-		CompanyID: companyID,
-		UserID:    targetUserID,
+	var previous string
+	err = runInTx(ctx, s.pool, s.queries, func(q *compiled.Queries) error {
+		var err error
+		previous, err = q.GetCompanyUserRole(ctx, compiled.GetCompanyUserRoleParams{
+			CompanyID: companyID,
+			UserID:    targetUserID,
+		})
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrNotCompanyMember
+		}
+		if err != nil {
+			return err
+		}
+		if previous == role {
+			return ErrSameRole
+		}
+
+		if previous == "admin" {
+			admins, err := q.LockCompanyAdmins(ctx, companyID)
+			if err != nil {
+				return err
+			}
+			if len(admins) <= 1 {
+				return ErrLastAdmin
+			}
+		}
+
+		if _, err := q.UpdateCompanyMemberRole(ctx, compiled.UpdateCompanyMemberRoleParams{
+			CompanyID: companyID,
+			UserID:    targetUserID,
+			Role:      role,
+		}); err != nil {
+			return err
+		}
+
+		return recordMemberEvent(ctx, q, WebhookEventMemberRoleChanged, companyID, MemberEvent{
+			UserID:       targetUserID,
+			Role:         role,
+			PreviousRole: previous,
+			ActorID:      adminID,
+		})
 	})
+	if err != nil {
+		return "", err
+	}
+	return previous, nil
+}
+
+var (
+	ErrOwnerCannotLeave = errors.New("the company owner cannot leave the company")
+	ErrLastAdmin        = errors.New("the last admin cannot leave the company")
+)
+
+// LeaveCompany removes userID from the company on their own request and
+// clears their selected company if it pointed there.
+func (s *CompanyService) LeaveCompany(ctx context.Context, userID, companyID int32) error {
+	company, err := s.queries.GetCompanyByID(ctx, companyID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrCompanyNotFound
+	}
 	if err != nil {
 		return err
 	}
-	if !isMember {
-		return ErrNotCompanyMember
+	if company.OwnerID == userID {
+		return ErrOwnerCannotLeave
 	}
 
-	return s.queries.RemoveUserFromCompany(ctx, compiled.RemoveUserFromCompanyParams{
-		CompanyID: companyID,
-		UserID:    targetUserID,
+	return runInTx(ctx, s.pool, s.queries, func(q *compiled.Queries) error {
+		role, err := q.GetCompanyUserRole(ctx, compiled.GetCompanyUserRoleParams{
+			CompanyID: companyID,
+			UserID:    userID,
+		})
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrNotCompanyMember
+		}
+		if err != nil {
+			return err
+		}
+
+		if role == "admin" {
+			// Lock the admin rows so two admins leaving at once cannot both
+			// pass the check
+			admins, err := q.LockCompanyAdmins(ctx, companyID)
+			if err != nil {
+				return err
+			}
+			if len(admins) <= 1 {
+				return ErrLastAdmin
+			}
+		}
+
+		if err := q.RemoveUserFromCompanyTeams(ctx, compiled.RemoveUserFromCompanyTeamsParams{
+			UserID:    userID,
+			CompanyID: companyID,
+		}); err != nil {
+			return err
+		}
+
+		if err := q.RemoveUserFromCompany(ctx, compiled.RemoveUserFromCompanyParams{
+			CompanyID: companyID,
+			UserID:    userID,
+			EndReason: pgtype.Text{String: MemberRemovedReasonLeft, Valid: true},
+		}); err != nil {
+			return err
+		}
+
+		if err := recordMemberRemoved(ctx, q, companyID, userID, role, userID, MemberRemovedReasonLeft); err != nil {
+			return err
+		}
+
+		return q.ClearUserSelectedCompanyIfMatches(ctx, compiled.ClearUserSelectedCompanyIfMatchesParams{
+			ID:                userID,
+			SelectedCompanyID: pgtype.Int4{Int32: companyID, Valid: true},
+		})
 	})
 }
@@ -0,0 +1,250 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"project/compiled"
+)
+
+const settingsCacheTTL = time.Minute
+
+var (
+	ErrUnknownSetting     = errors.New("unknown setting")
+	ErrInvalidSetting     = errors.New("invalid setting value")
+	ErrEmailDomainBlocked = errors.New("email domain is not allowed by the company settings")
+)
+
+var hexColorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// CompanySettings holds the per-company knobs. JSON names match the proto
+// field names so update mask paths map directly onto stored keys.
+type CompanySettings struct {
+	DefaultInviteRole   string          `json:"default_invite_role"`
+	AllowedEmailDomains []string        `json:"allowed_email_domains"`
+	RequireTwoFactor    bool            `json:"require_two_factor"`
+	Branding            CompanyBranding `json:"branding"`
+	// InviterTeamIDs lets members of these teams, and of teams nested in
+	// them, invite people as members in addition to admins.
+	InviterTeamIDs []int32 `json:"inviter_team_ids"`
+}
+
+type CompanyBranding struct {
+	LogoURL      string `json:"logo_url"`
+	PrimaryColor string `json:"primary_color"`
+}
+
+// DefaultCompanySettings returns the settings of a company that never
+// changed any of them.
+func DefaultCompanySettings() CompanySettings {
+	return CompanySettings{
+		DefaultInviteRole:   "member",
+		AllowedEmailDomains: []string{},
+		InviterTeamIDs:      []int32{},
+	}
+}
+
+type cachedSettings struct {
+	settings CompanySettings
+	loadedAt time.Time
+}
+
+// CompanySettings returns the company's effective settings. Results are
+// cached per company for a short time; updates through this service
+// invalidate the cache immediately.
+func (s *CompanyService) CompanySettings(ctx context.Context, companyID int32) (CompanySettings, error) {
+	if v, ok := s.settingsCache.Load(companyID); ok {
+		cached := v.(cachedSettings)
+		if time.Since(cached.loadedAt) < settingsCacheTTL {
+			return cached.settings, nil
+		}
+	}
+
+	settings, _, err := loadCompanySettings(ctx, s.queries, companyID)
+	if err != nil {
+		return CompanySettings{}, err
+	}
+
+	s.settingsCache.Store(companyID, cachedSettings{settings: settings, loadedAt: time.Now()})
+	return settings, nil
+}
+
+// UpdateCompanySettings copies the fields named by paths from update into the
+// company's settings. Paths use the proto field names, e.g.
+// "default_invite_role" or "branding.logo_url".
+func (s *CompanyService) UpdateCompanySettings(ctx context.Context, adminID, companyID int32, update CompanySettings, paths []string) (CompanySettings, error) {
+	if err := s.requireAdmin(ctx, companyID, adminID); err != nil {
+		return CompanySettings{}, err
+	}
+	if _, err := s.getWritableCompany(ctx, companyID); err != nil {
+		return CompanySettings{}, err
+	}
+
+	var settings CompanySettings
+	err := runInTx(ctx, s.pool, s.queries, func(q *compiled.Queries) error {
+		if _, err := q.LockCompanies(ctx, []int32{companyID}); err != nil {
+			return err
+		}
+
+		current, stored, err := loadCompanySettings(ctx, q, companyID)
+		if err != nil {
+			return err
+		}
+
+		for _, path := range paths {
+			if err := applySettingPath(&current, &update, path); err != nil {
+				return err
+			}
+		}
+		if err := validateCompanySettings(&current); err != nil {
+			return err
+		}
+		for _, teamID := range current.InviterTeamIDs {
+			if _, err := q.GetTeam(ctx, compiled.GetTeamParams{ID: teamID, CompanyID: companyID}); err != nil {
+				if errors.Is(err, pgx.ErrNoRows) {
+					return ErrTeamNotFound
+				}
+				return err
+			}
+		}
+
+		// Persist only the top-level keys that were touched so untouched
+		// settings keep following the defaults
+		full, err := settingsToMap(current)
+		if err != nil {
+			return err
+		}
+		for _, path := range paths {
+			key, _, _ := strings.Cut(path, ".")
+			stored[key] = full[key]
+		}
+
+		data, err := json.Marshal(stored)
+		if err != nil {
+			return err
+		}
+		settings = current
+		return q.UpsertCompanySettings(ctx, compiled.UpsertCompanySettingsParams{
+			CompanyID: companyID,
+			Settings:  data,
+			UpdatedBy: pgtype.Int4{Int32: adminID, Valid: true},
+		})
+	})
+	if err != nil {
+		return CompanySettings{}, err
+	}
+
+	s.settingsCache.Delete(companyID)
+	return settings, nil
+}
+
+// EmailDomainAllowed reports whether email may be invited to the company
+// under its allowed_email_domains setting. An empty list allows any domain.
+func (s *CompanyService) EmailDomainAllowed(ctx context.Context, companyID int32, email string) (bool, error) {
+	settings, err := s.CompanySettings(ctx, companyID)
+	if err != nil {
+		return false, err
+	}
+	if len(settings.AllowedEmailDomains) == 0 {
+		return true, nil
+	}
+	return slices.ContainsFunc(settings.AllowedEmailDomains, func(domain string) bool {
+		return emailHasDomain(email, domain)
+	}), nil
+}
+
+func loadCompanySettings(ctx context.Context, q *compiled.Queries, companyID int32) (CompanySettings, map[string]json.RawMessage, error) {
+	settings := DefaultCompanySettings()
+	stored := make(map[string]json.RawMessage)
+
+	data, err := q.GetCompanySettings(ctx, companyID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return settings, stored, nil
+	}
+	if err != nil {
+		return settings, nil, err
+	}
+
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return settings, nil, err
+	}
+	if err := json.Unmarshal(data, &settings); err != nil {
+		return settings, nil, err
+	}
+	return settings, stored, nil
+}
+
+func applySettingPath(dst, src *CompanySettings, path string) error {
+	switch path {
+	case "default_invite_role":
+		dst.DefaultInviteRole = src.DefaultInviteRole
+	case "allowed_email_domains":
+		dst.AllowedEmailDomains = src.AllowedEmailDomains
+	case "require_two_factor":
+		dst.RequireTwoFactor = src.RequireTwoFactor
+	case "branding":
+		dst.Branding = src.Branding
+	case "branding.logo_url":
+		dst.Branding.LogoURL = src.Branding.LogoURL
+	case "branding.primary_color":
+		dst.Branding.PrimaryColor = src.Branding.PrimaryColor
+	case "inviter_team_ids":
+		dst.InviterTeamIDs = src.InviterTeamIDs
+	default:
+		return ErrUnknownSetting
+	}
+	return nil
+}
+
+func validateCompanySettings(settings *CompanySettings) error {
+	if settings.DefaultInviteRole != "admin" && settings.DefaultInviteRole != "member" {
+		return ErrInvalidRole
+	}
+
+	if settings.AllowedEmailDomains == nil {
+		settings.AllowedEmailDomains = []string{}
+	}
+	for i, domain := range settings.AllowedEmailDomains {
+		domain = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(domain, "@")))
+		if domain == "" || strings.ContainsAny(domain, "@/ ") || !strings.Contains(domain, ".") {
+			return ErrInvalidSetting
+		}
+		settings.AllowedEmailDomains[i] = domain
+	}
+
+	if settings.InviterTeamIDs == nil {
+		settings.InviterTeamIDs = []int32{}
+	}
+	slices.Sort(settings.InviterTeamIDs)
+	settings.InviterTeamIDs = slices.Compact(settings.InviterTeamIDs)
+
+	if logo := settings.Branding.LogoURL; logo != "" {
+		u, err := url.Parse(logo)
+		if err != nil || u.Scheme != "https" || u.Host == "" {
+			return ErrInvalidSetting
+		}
+	}
+	if color := settings.Branding.PrimaryColor; color != "" && !hexColorPattern.MatchString(color) {
+		return ErrInvalidSetting
+	}
+	return nil
+}
+
+func settingsToMap(settings CompanySettings) (map[string]json.RawMessage, error) {
+	data, err := json.Marshal(settings)
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]json.RawMessage
+	err = json.Unmarshal(data, &m)
+	return m, err
+}
@@ -0,0 +1,240 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"project/compiled"
+)
+
+const (
+	DefaultPlanCode = "free"
+
+	SubscriptionStatusActive   = "active"
+	SubscriptionStatusPastDue  = "past_due"
+	SubscriptionStatusCanceled = "canceled"
+
+	FeatureTeams            = "teams"
+	FeatureJoinLinks        = "join_links"
+	FeatureBulkInvite       = "bulk_invite"
+	FeatureCompanyHierarchy = "company_hierarchy"
+)
+
+var (
+	ErrPlanNotFound      = errors.New("plan not found")
+	ErrSeatLimitReached  = errors.New("the company has no seats left on its plan")
+	ErrSeatLimitExceeded = errors.New("the company has more seats in use than the plan allows")
+	ErrFeatureNotInPlan  = errors.New("the company's plan does not include this feature")
+	ErrBillingDisabled   = errors.New("paid plans are not available on this server")
+)
+
+type CompanySubscription struct {
+	Plan             compiled.Plan
+	Status           string
+	CurrentPeriodEnd pgtype.Timestamp
+	SeatsUsed        int32
+}
+
+func (s *CompanyService) ListPlans(ctx context.Context) ([]compiled.Plan, error) {
+	return s.queries.ListPlans(ctx)
+}
+
+// GetCompanySubscription returns the company's plan and seat usage. Companies
+// that never subscribed are reported on the default plan.
+func (s *CompanyService) GetCompanySubscription(ctx context.Context, companyID int32) (*CompanySubscription, error) {
+	plan, sub, err := s.companyPlan(ctx, s.queries, companyID)
+	if err != nil {
+		return nil, err
+	}
+
+	used, err := s.queries.CountCompanySeatsUsed(ctx, companyID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &CompanySubscription{
+		Plan:      plan,
+		Status:    SubscriptionStatusActive,
+		SeatsUsed: used,
+	}
+	if sub != nil {
+		result.Status = sub.Status
+		result.CurrentPeriodEnd = sub.CurrentPeriodEnd
+	}
+	return result, nil
+}
+
+// HasFeature reports whether the company's plan includes feature.
+func (s *CompanyService) HasFeature(ctx context.Context, companyID int32, feature string) (bool, error) {
+	plan, _, err := s.companyPlan(ctx, s.queries, companyID)
+	if err != nil {
+		return false, err
+	}
+	return slices.Contains(plan.Features, feature), nil
+}
+
+// requireFeature fails with ErrFeatureNotInPlan unless the company's plan
+// includes feature. Only operations that set a feature up or use it are
+// gated; companies that downgrade can still list and remove what they have.
+func (s *CompanyService) requireFeature(ctx context.Context, companyID int32, feature string) error {
+	ok, err := s.HasFeature(ctx, companyID, feature)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrFeatureNotInPlan, feature)
+	}
+	return nil
+}
+
+// ChangeCompanyPlan moves the company to planCode, starting a paid
+// subscription with the payment provider when the plan has a price and
+// cancelling the previous one. Downgrades below the seats already in use are
+// refused, and so are paid plans when no payment provider is configured.
+//
+// The change runs in a transaction holding the company row, the same lock
+// reserveSeat takes, so concurrent plan changes and seat reservations go one
+// at a time and each plan change sees the subscription the previous one
+// recorded. A provider subscription started by a change that fails to commit
+// is cancelled again.
+func (s *CompanyService) ChangeCompanyPlan(ctx context.Context, adminID, companyID int32, billingEmail, planCode string) (*CompanySubscription, error) {
+	if err := s.requireAdmin(ctx, companyID, adminID); err != nil {
+		return nil, err
+	}
+	if _, err := s.getWritableCompany(ctx, companyID); err != nil {
+		return nil, err
+	}
+
+	plan, err := s.queries.GetPlanByCode(ctx, planCode)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrPlanNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var created string
+	err = runInTx(ctx, s.pool, s.queries, func(q *compiled.Queries) error {
+		if _, err := q.LockCompanies(ctx, []int32{companyID}); err != nil {
+			return err
+		}
+
+		current, sub, err := s.companyPlan(ctx, q, companyID)
+		if err != nil {
+			return err
+		}
+		if s.payments == nil && (plan.PriceCents > 0 || (sub != nil && sub.ProviderSubscriptionID.Valid)) {
+			return ErrBillingDisabled
+		}
+		if current.ID == plan.ID && (sub == nil || sub.Status == SubscriptionStatusActive) {
+			return nil
+		}
+
+		used, err := q.CountCompanySeatsUsed(ctx, companyID)
+		if err != nil {
+			return err
+		}
+		if plan.SeatLimit.Valid && used > plan.SeatLimit.Int32 {
+			return ErrSeatLimitExceeded
+		}
+
+		params := compiled.UpsertCompanySubscriptionParams{
+			CompanyID: companyID,
+			PlanID:    plan.ID,
+			Status:    SubscriptionStatusActive,
+		}
+		if sub != nil {
+			params.ProviderCustomerID = sub.ProviderCustomerID
+		}
+
+		if plan.PriceCents > 0 {
+			if !params.ProviderCustomerID.Valid {
+				customerID, err := s.payments.CreateCustomer(ctx, companyID, billingEmail)
+				if err != nil {
+					return err
+				}
+				params.ProviderCustomerID = pgtype.Text{String: customerID, Valid: true}
+			}
+
+			providerSub, err := s.payments.CreateSubscription(ctx, params.ProviderCustomerID.String, plan.Code)
+			if err != nil {
+				return err
+			}
+			created = providerSub.ID
+			params.ProviderSubscriptionID = pgtype.Text{String: providerSub.ID, Valid: true}
+			params.CurrentPeriodEnd = pgtype.Timestamp{Time: providerSub.CurrentPeriodEnd, Valid: true}
+		}
+
+		if _, err := q.UpsertCompanySubscription(ctx, params); err != nil {
+			return err
+		}
+
+		if sub != nil && sub.ProviderSubscriptionID.Valid {
+			return s.payments.CancelSubscription(ctx, sub.ProviderSubscriptionID.String)
+		}
+		return nil
+	})
+	if err != nil {
+		if created != "" {
+			if cancelErr := s.payments.CancelSubscription(context.WithoutCancel(ctx), created); cancelErr != nil {
+				slog.ErrorContext(ctx, "Failed to cancel uncommitted subscription", "company_id", companyID, "subscription_id", created, "error", cancelErr)
+			}
+		}
+		return nil, err
+	}
+
+	return s.GetCompanySubscription(ctx, companyID)
+}
+
+// reserveSeat fails with ErrSeatLimitReached when adding one more member or
+// pending invitation would go over the plan's seat limit. It locks the
+// company row so concurrent invites and joins are counted one at a time.
+func (s *CompanyService) reserveSeat(ctx context.Context, q *compiled.Queries, companyID int32) error {
+	if _, err := q.LockCompanies(ctx, []int32{companyID}); err != nil {
+		return err
+	}
+
+	plan, _, err := s.companyPlan(ctx, q, companyID)
+	if err != nil {
+		return err
+	}
+	if !plan.SeatLimit.Valid {
+		return nil
+	}
+
+	used, err := q.CountCompanySeatsUsed(ctx, companyID)
+	if err != nil {
+		return err
+	}
+	if used >= plan.SeatLimit.Int32 {
+		return ErrSeatLimitReached
+	}
+	return nil
+}
+
+// companyPlan returns the plan the company is entitled to and its
+// subscription, if any. Canceled subscriptions fall back to the default plan.
+func (s *CompanyService) companyPlan(ctx context.Context, q *compiled.Queries, companyID int32) (compiled.Plan, *compiled.Subscription, error) {
+	sub, err := q.GetCompanySubscription(ctx, companyID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		plan, err := q.GetPlanByCode(ctx, DefaultPlanCode)
+		return plan, nil, err
+	}
+	if err != nil {
+		return compiled.Plan{}, nil, err
+	}
+
+	if sub.Status == SubscriptionStatusCanceled {
+		plan, err := q.GetPlanByCode(ctx, DefaultPlanCode)
+		return plan, &sub, err
+	}
+
+	plan, err := q.GetPlanByID(ctx, sub.PlanID)
+	return plan, &sub, err
+}
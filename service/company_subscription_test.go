@@ -0,0 +1,186 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestChangeCompanyPlanChargesPaidPlans(t *testing.T) {
+	pool, queries := testDB(t)
+	payments := NewFakePaymentProvider()
+	s := newTestCompanyService(pool, queries, payments)
+	ctx := context.Background()
+
+	owner := newTestUser(t, queries, "example.com")
+	companyID := newTestCompany(t, s, owner.ID)
+
+	sub, err := s.ChangeCompanyPlan(ctx, owner.ID, companyID, owner.Email, "team")
+	if err != nil {
+		t.Fatalf("ChangeCompanyPlan(team): %v", err)
+	}
+	if sub.Plan.Code != "team" || sub.Status != SubscriptionStatusActive || !sub.CurrentPeriodEnd.Valid {
+		t.Fatalf("subscription = %+v, want active team plan with a period end", sub)
+	}
+
+	if _, err := s.ChangeCompanyPlan(ctx, owner.ID, companyID, owner.Email, "business"); err != nil {
+		t.Fatalf("ChangeCompanyPlan(business): %v", err)
+	}
+	if _, err := s.ChangeCompanyPlan(ctx, owner.ID, companyID, owner.Email, DefaultPlanCode); err != nil {
+		t.Fatalf("ChangeCompanyPlan(free): %v", err)
+	}
+
+	// Every paid subscription was cancelled when the plan changed again
+	payments.mu.Lock()
+	defer payments.mu.Unlock()
+	if len(payments.subscriptions) != 0 {
+		t.Errorf("provider still has subscriptions %v", payments.subscriptions)
+	}
+	if len(payments.customers) != 1 {
+		t.Errorf("provider has %d customers, want the first one reused", len(payments.customers))
+	}
+}
+
+func TestChangeCompanyPlanDeclined(t *testing.T) {
+	pool, queries := testDB(t)
+	payments := NewFakePaymentProvider()
+	payments.Decline = true
+	s := newTestCompanyService(pool, queries, payments)
+	ctx := context.Background()
+
+	owner := newTestUser(t, queries, "example.com")
+	companyID := newTestCompany(t, s, owner.ID)
+
+	if _, err := s.ChangeCompanyPlan(ctx, owner.ID, companyID, owner.Email, "team"); !errors.Is(err, ErrPaymentFailed) {
+		t.Fatalf("ChangeCompanyPlan = %v, want ErrPaymentFailed", err)
+	}
+
+	sub, err := s.GetCompanySubscription(ctx, companyID)
+	if err != nil {
+		t.Fatalf("GetCompanySubscription: %v", err)
+	}
+	if sub.Plan.Code != DefaultPlanCode {
+		t.Errorf("plan = %q after a declined charge, want %q", sub.Plan.Code, DefaultPlanCode)
+	}
+}
+
+func TestChangeCompanyPlanWithoutPaymentProvider(t *testing.T) {
+	pool, queries := testDB(t)
+	s := newTestCompanyService(pool, queries, nil)
+	ctx := context.Background()
+
+	owner := newTestUser(t, queries, "example.com")
+	companyID := newTestCompany(t, s, owner.ID)
+
+	if _, err := s.ChangeCompanyPlan(ctx, owner.ID, companyID, owner.Email, "team"); !errors.Is(err, ErrBillingDisabled) {
+		t.Fatalf("ChangeCompanyPlan(team) = %v, want ErrBillingDisabled", err)
+	}
+	if _, err := s.ChangeCompanyPlan(ctx, owner.ID, companyID, owner.Email, DefaultPlanCode); err != nil {
+		t.Fatalf("ChangeCompanyPlan(free): %v", err)
+	}
+}
+
+func TestChangeCompanyPlanRefusesDowngradeBelowSeatsUsed(t *testing.T) {
+	pool, queries := testDB(t)
+	s := newTestCompanyService(pool, queries, NewFakePaymentProvider())
+	ctx := context.Background()
+
+	owner := newTestUser(t, queries, "example.com")
+	companyID := newTestCompany(t, s, owner.ID)
+	if _, err := s.ChangeCompanyPlan(ctx, owner.ID, companyID, owner.Email, "team"); err != nil {
+		t.Fatalf("ChangeCompanyPlan(team): %v", err)
+	}
+
+	// The free plan has five seats; the owner plus five members need six
+	for range 5 {
+		addTestMember(t, queries, companyID, newTestUser(t, queries, "example.com").ID, "member")
+	}
+
+	if _, err := s.ChangeCompanyPlan(ctx, owner.ID, companyID, owner.Email, DefaultPlanCode); !errors.Is(err, ErrSeatLimitExceeded) {
+		t.Fatalf("ChangeCompanyPlan(free) = %v, want ErrSeatLimitExceeded", err)
+	}
+}
+
+func TestInviteUserEnforcesSeatLimit(t *testing.T) {
+	pool, queries := testDB(t)
+	s := newTestCompanyService(pool, queries, nil)
+	ctx := context.Background()
+
+	owner := newTestUser(t, queries, "example.com")
+	companyID := newTestCompany(t, s, owner.ID)
+	for range 3 {
+		addTestMember(t, queries, companyID, newTestUser(t, queries, "example.com").ID, "member")
+	}
+
+	// A pending invitation takes the fifth and last seat of the free plan
+	if _, err := s.InviteUser(ctx, owner.ID, companyID, "first-"+generateToken(8)+"@example.com", "First", "member"); err != nil {
+		t.Fatalf("InviteUser: %v", err)
+	}
+	if _, err := s.InviteUser(ctx, owner.ID, companyID, "second-"+generateToken(8)+"@example.com", "Second", "member"); !errors.Is(err, ErrSeatLimitReached) {
+		t.Fatalf("InviteUser over the limit = %v, want ErrSeatLimitReached", err)
+	}
+}
+
+func TestPlanFeaturesGateOperations(t *testing.T) {
+	pool, queries := testDB(t)
+	s := newTestCompanyService(pool, queries, NewFakePaymentProvider())
+	ctx := context.Background()
+
+	owner := newTestUser(t, queries, "example.com")
+	companyID := newTestCompany(t, s, owner.ID)
+
+	if _, err := s.CreateTeam(ctx, owner.ID, companyID, "Engineering", 0); !errors.Is(err, ErrFeatureNotInPlan) {
+		t.Fatalf("CreateTeam on free plan = %v, want ErrFeatureNotInPlan", err)
+	}
+	if _, err := s.CreateJoinLink(ctx, owner.ID, companyID, JoinLinkOptions{Role: "member"}); !errors.Is(err, ErrFeatureNotInPlan) {
+		t.Fatalf("CreateJoinLink on free plan = %v, want ErrFeatureNotInPlan", err)
+	}
+
+	if _, err := s.ChangeCompanyPlan(ctx, owner.ID, companyID, owner.Email, "team"); err != nil {
+		t.Fatalf("ChangeCompanyPlan(team): %v", err)
+	}
+	if _, err := s.CreateTeam(ctx, owner.ID, companyID, "Engineering", 0); err != nil {
+		t.Fatalf("CreateTeam on team plan: %v", err)
+	}
+
+	child := newTestCompany(t, s, owner.ID)
+	if err := s.AttachChildCompany(ctx, owner.ID, companyID, child); !errors.Is(err, ErrFeatureNotInPlan) {
+		t.Fatalf("AttachChildCompany on team plan = %v, want ErrFeatureNotInPlan", err)
+	}
+}
+
+func TestChangeCompanyPlanConcurrently(t *testing.T) {
+	pool, queries := testDB(t)
+	payments := NewFakePaymentProvider()
+	s := newTestCompanyService(pool, queries, payments)
+	ctx := context.Background()
+
+	owner := newTestUser(t, queries, "example.com")
+	companyID := newTestCompany(t, s, owner.ID)
+
+	var wg sync.WaitGroup
+	for _, code := range []string{"team", "business", "team", "business"} {
+		wg.Go(func() {
+			if _, err := s.ChangeCompanyPlan(ctx, owner.ID, companyID, owner.Email, code); err != nil {
+				t.Errorf("ChangeCompanyPlan(%s): %v", code, err)
+			}
+		})
+	}
+	wg.Wait()
+
+	current, err := queries.GetCompanySubscription(ctx, companyID)
+	if err != nil {
+		t.Fatalf("GetCompanySubscription: %v", err)
+	}
+
+	// Only the recorded subscription is still billing
+	payments.mu.Lock()
+	defer payments.mu.Unlock()
+	if len(payments.subscriptions) != 1 {
+		t.Fatalf("provider has subscriptions %v, want only the recorded one", payments.subscriptions)
+	}
+	if _, ok := payments.subscriptions[current.ProviderSubscriptionID.String]; !ok {
+		t.Errorf("recorded subscription %q is not active at the provider", current.ProviderSubscriptionID.String)
+	}
+}
@@ -0,0 +1,300 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"slices"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"project/compiled"
+)
+
+var (
+	ErrTeamNotFound       = errors.New("team not found")
+	ErrTeamNameTaken      = errors.New("a team with this name already exists")
+	ErrTeamCycle          = errors.New("a team cannot be nested inside itself or its descendants")
+	ErrTeamHasChildren    = errors.New("team still has nested teams")
+	ErrNotTeamMember      = errors.New("user is not a member of this team")
+	ErrTeamMemberNotInOrg = errors.New("user must be a member of the company to join a team")
+)
+
+// GrantTarget names who an authorization rule applies to. Exactly one field
+// is expected to be set: a company role, a team (including its nested
+// teams), or a single user.
+type GrantTarget struct {
+	Role   string
+	TeamID int32
+	UserID int32
+}
+
+// MatchesGrant reports whether userID is covered by target within companyID.
+func (s *CompanyService) MatchesGrant(ctx context.Context, companyID, userID int32, target GrantTarget) (bool, error) {
+	switch {
+	case target.UserID != 0:
+		return target.UserID == userID, nil
+	case target.Role != "":
+		role, err := s.GetCompanyUserRole(ctx, companyID, userID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		return role == target.Role, nil
+	case target.TeamID != 0:
+		// Team rows can outlive a membership, so only current members match
+		isMember, err := s.queries.IsUserMemberOfCompany(ctx, compiled.IsUserMemberOfCompanyParams{
+			CompanyID: companyID,
+			UserID:    userID,
+		})
+		if err != nil || !isMember {
+			return false, err
+		}
+		if _, err := s.getTeam(ctx, companyID, target.TeamID); err != nil {
+			if errors.Is(err, ErrTeamNotFound) {
+				return false, nil
+			}
+			return false, err
+		}
+		return s.queries.IsUserInTeamTree(ctx, compiled.IsUserInTeamTreeParams{
+			TeamID: target.TeamID,
+			UserID: userID,
+		})
+	}
+	return false, nil
+}
+
+func (s *CompanyService) CreateTeam(ctx context.Context, adminID, companyID int32, name string, parentTeamID int32) (*compiled.Team, error) {
+	if err := s.requireAdmin(ctx, companyID, adminID); err != nil {
+		return nil, err
+	}
+	if _, err := s.getWritableCompany(ctx, companyID); err != nil {
+		return nil, err
+	}
+	if err := s.requireFeature(ctx, companyID, FeatureTeams); err != nil {
+		return nil, err
+	}
+	if parentTeamID != 0 {
+		if _, err := s.getTeam(ctx, companyID, parentTeamID); err != nil {
+			return nil, err
+		}
+	}
+
+	team, err := s.queries.CreateTeam(ctx, compiled.CreateTeamParams{
+		CompanyID:    companyID,
+		ParentTeamID: pgtype.Int4{Int32: parentTeamID, Valid: parentTeamID != 0},
+		Name:         name,
+	})
+	if isUniqueViolation(err) {
+		return nil, ErrTeamNameTaken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &team, nil
+}
+
+// UpdateTeam renames a team and moves it under parentTeamID (0 for top level).
+func (s *CompanyService) UpdateTeam(ctx context.Context, adminID, companyID, teamID int32, name string, parentTeamID int32) (*compiled.Team, error) {
+	if err := s.requireAdmin(ctx, companyID, adminID); err != nil {
+		return nil, err
+	}
+	if _, err := s.getWritableCompany(ctx, companyID); err != nil {
+		return nil, err
+	}
+	if err := s.requireFeature(ctx, companyID, FeatureTeams); err != nil {
+		return nil, err
+	}
+
+	var team compiled.Team
+	err := runInTx(ctx, s.pool, s.queries, func(q *compiled.Queries) error {
+		// Moves within a company run one at a time, so two concurrent moves
+		// cannot each pass the ancestor check and together form a cycle
+		if _, err := q.LockCompanies(ctx, []int32{companyID}); err != nil {
+			return err
+		}
+
+		if parentTeamID != 0 {
+			if _, err := q.GetTeam(ctx, compiled.GetTeamParams{ID: parentTeamID, CompanyID: companyID}); err != nil {
+				if errors.Is(err, pgx.ErrNoRows) {
+					return ErrTeamNotFound
+				}
+				return err
+			}
+
+			ancestors, err := q.GetTeamAncestorIDs(ctx, parentTeamID)
+			if err != nil {
+				return err
+			}
+			if slices.Contains(ancestors, teamID) {
+				return ErrTeamCycle
+			}
+		}
+
+		var err error
+		team, err = q.UpdateTeam(ctx, compiled.UpdateTeamParams{
+			Name:         name,
+			ParentTeamID: pgtype.Int4{Int32: parentTeamID, Valid: parentTeamID != 0},
+			ID:           teamID,
+			CompanyID:    companyID,
+		})
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrTeamNotFound
+		}
+		if isUniqueViolation(err) {
+			return ErrTeamNameTaken
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &team, nil
+}
+
+func (s *CompanyService) DeleteTeam(ctx context.Context, adminID, companyID, teamID int32) error {
+	if err := s.requireAdmin(ctx, companyID, adminID); err != nil {
+		return err
+	}
+	if _, err := s.getWritableCompany(ctx, companyID); err != nil {
+		return err
+	}
+	if _, err := s.getTeam(ctx, companyID, teamID); err != nil {
+		return err
+	}
+
+	return runInTx(ctx, s.pool, s.queries, func(q *compiled.Queries) error {
+		children, err := q.CountChildTeams(ctx, pgtype.Int4{Int32: teamID, Valid: true})
+		if err != nil {
+			return err
+		}
+		if children > 0 {
+			return ErrTeamHasChildren
+		}
+
+		if err := q.DeleteTeamMembers(ctx, teamID); err != nil {
+			return err
+		}
+		return q.SoftDeleteTeam(ctx, teamID)
+	})
+}
+
+func (s *CompanyService) AddTeamMember(ctx context.Context, adminID, companyID, teamID, userID int32) error {
+	if err := s.requireAdmin(ctx, companyID, adminID); err != nil {
+		return err
+	}
+	if _, err := s.getWritableCompany(ctx, companyID); err != nil {
+		return err
+	}
+	if err := s.requireFeature(ctx, companyID, FeatureTeams); err != nil {
+		return err
+	}
+	if _, err := s.getTeam(ctx, companyID, teamID); err != nil {
+		return err
+	}
+
+	isMember, err := s.queries.IsUserMemberOfCompany(ctx, compiled.IsUserMemberOfCompanyParams{
+		CompanyID: companyID,
+		UserID:    userID,
+	})
+	if err != nil {
+		return err
+	}
+	if !isMember {
+		return ErrTeamMemberNotInOrg
+	}
+
+	return s.queries.AddTeamMember(ctx, compiled.AddTeamMemberParams{
+		TeamID: teamID,
+		UserID: userID,
+	})
+}
+
+func (s *CompanyService) RemoveTeamMember(ctx context.Context, adminID, companyID, teamID, userID int32) error {
+	if err := s.requireAdmin(ctx, companyID, adminID); err != nil {
+		return err
+	}
+	if _, err := s.getWritableCompany(ctx, companyID); err != nil {
+		return err
+	}
+	if _, err := s.getTeam(ctx, companyID, teamID); err != nil {
+		return err
+	}
+
+	rows, err := s.queries.RemoveTeamMember(ctx, compiled.RemoveTeamMemberParams{
+		TeamID: teamID,
+		UserID: userID,
+	})
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrNotTeamMember
+	}
+	return nil
+}
+
+func (s *CompanyService) ListTeams(ctx context.Context, companyID int32) ([]compiled.ListCompanyTeamsRow, error) {
+	return s.queries.ListCompanyTeams(ctx, companyID)
+}
+
+func (s *CompanyService) ListUserTeams(ctx context.Context, companyID, userID int32) ([]compiled.ListUserTeamsRow, error) {
+	return s.queries.ListUserTeams(ctx, compiled.ListUserTeamsParams{
+		UserID:    userID,
+		CompanyID: companyID,
+	})
+}
+
+// GetCompanyTeamMemberships returns the direct team memberships of every
+// member of the company, keyed by user ID.
+func (s *CompanyService) GetCompanyTeamMemberships(ctx context.Context, companyID int32) (map[int32][]compiled.GetCompanyTeamMembershipsRow, error) {
+	rows, err := s.queries.GetCompanyTeamMemberships(ctx, companyID)
+	if err != nil {
+		return nil, err
+	}
+
+	byUser := make(map[int32][]compiled.GetCompanyTeamMembershipsRow)
+	for _, row := range rows {
+		byUser[row.UserID] = append(byUser[row.UserID], row)
+	}
+	return byUser, nil
+}
+
+// GetTeamMembershipsForUsers is GetCompanyTeamMemberships limited to the
+// given users, for callers showing one page of members.
+func (s *CompanyService) GetTeamMembershipsForUsers(ctx context.Context, companyID int32, userIDs []int32) (map[int32][]compiled.GetTeamMembershipsForUsersRow, error) {
+	rows, err := s.queries.GetTeamMembershipsForUsers(ctx, compiled.GetTeamMembershipsForUsersParams{
+		CompanyID: companyID,
+		UserIds:   userIDs,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	byUser := make(map[int32][]compiled.GetTeamMembershipsForUsersRow)
+	for _, row := range rows {
+		byUser[row.UserID] = append(byUser[row.UserID], row)
+	}
+	return byUser, nil
+}
+
+func (s *CompanyService) getTeam(ctx context.Context, companyID, teamID int32) (compiled.Team, error) {
+	team, err := s.queries.GetTeam(ctx, compiled.GetTeamParams{
+		ID:        teamID,
+		CompanyID: companyID,
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return team, ErrTeamNotFound
+	}
+	return team, err
+}
+
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
@@ -0,0 +1,94 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"project/compiled"
+)
+
+func TestInviterTeamsGrantInviting(t *testing.T) {
+	pool, queries := testDB(t)
+	s := newTestCompanyService(pool, queries, NewFakePaymentProvider())
+	ctx := context.Background()
+
+	owner := newTestUser(t, queries, "example.com")
+	companyID := newTestCompany(t, s, owner.ID)
+	if _, err := s.ChangeCompanyPlan(ctx, owner.ID, companyID, owner.Email, "team"); err != nil {
+		t.Fatalf("ChangeCompanyPlan(team): %v", err)
+	}
+
+	recruiting, err := s.CreateTeam(ctx, owner.ID, companyID, "Recruiting", 0)
+	if err != nil {
+		t.Fatalf("CreateTeam: %v", err)
+	}
+	campus, err := s.CreateTeam(ctx, owner.ID, companyID, "Campus", recruiting.ID)
+	if err != nil {
+		t.Fatalf("CreateTeam(nested): %v", err)
+	}
+
+	recruiter := newTestUser(t, queries, "example.com")
+	outsider := newTestUser(t, queries, "example.com")
+	addTestMember(t, queries, companyID, recruiter.ID, "member")
+	addTestMember(t, queries, companyID, outsider.ID, "member")
+	if err := s.AddTeamMember(ctx, owner.ID, companyID, campus.ID, recruiter.ID); err != nil {
+		t.Fatalf("AddTeamMember: %v", err)
+	}
+
+	if _, err := s.UpdateCompanySettings(ctx, owner.ID, companyID, CompanySettings{InviterTeamIDs: []int32{recruiting.ID}}, []string{"inviter_team_ids"}); err != nil {
+		t.Fatalf("UpdateCompanySettings: %v", err)
+	}
+
+	// Membership of a nested team counts for the parent team's grant
+	if _, err := s.InviteUser(ctx, recruiter.ID, companyID, "hire-"+generateToken(8)+"@example.com", "Hire", "member"); err != nil {
+		t.Fatalf("InviteUser by inviter team member: %v", err)
+	}
+	if _, err := s.InviteUser(ctx, recruiter.ID, companyID, "boss-"+generateToken(8)+"@example.com", "Boss", "admin"); !errors.Is(err, ErrNotAdmin) {
+		t.Fatalf("InviteUser(admin) by inviter team member = %v, want ErrNotAdmin", err)
+	}
+	if _, err := s.InviteUser(ctx, outsider.ID, companyID, "other-"+generateToken(8)+"@example.com", "Other", "member"); !errors.Is(err, ErrNotAdmin) {
+		t.Fatalf("InviteUser by member outside the team = %v, want ErrNotAdmin", err)
+	}
+
+	// A removed member loses the grant even if a team row is left behind
+	if err := queries.RemoveUserFromCompany(ctx, compiled.RemoveUserFromCompanyParams{CompanyID: companyID, UserID: recruiter.ID}); err != nil {
+		t.Fatalf("remove member: %v", err)
+	}
+	if _, err := s.InviteUser(ctx, recruiter.ID, companyID, "late-"+generateToken(8)+"@example.com", "Late", "member"); !errors.Is(err, ErrNotAdmin) {
+		t.Fatalf("InviteUser by removed member = %v, want ErrNotAdmin", err)
+	}
+}
+
+func TestGetTeamMembershipsForUsers(t *testing.T) {
+	pool, queries := testDB(t)
+	s := newTestCompanyService(pool, queries, NewFakePaymentProvider())
+	ctx := context.Background()
+
+	owner := newTestUser(t, queries, "example.com")
+	companyID := newTestCompany(t, s, owner.ID)
+	if _, err := s.ChangeCompanyPlan(ctx, owner.ID, companyID, owner.Email, "team"); err != nil {
+		t.Fatalf("ChangeCompanyPlan(team): %v", err)
+	}
+	team, err := s.CreateTeam(ctx, owner.ID, companyID, "Support", 0)
+	if err != nil {
+		t.Fatalf("CreateTeam: %v", err)
+	}
+
+	onPage := newTestUser(t, queries, "example.com")
+	offPage := newTestUser(t, queries, "example.com")
+	for _, user := range []compiled.CreateUserRow{onPage, offPage} {
+		addTestMember(t, queries, companyID, user.ID, "member")
+		if err := s.AddTeamMember(ctx, owner.ID, companyID, team.ID, user.ID); err != nil {
+			t.Fatalf("AddTeamMember: %v", err)
+		}
+	}
+
+	teams, err := s.GetTeamMembershipsForUsers(ctx, companyID, []int32{onPage.ID})
+	if err != nil {
+		t.Fatalf("GetTeamMembershipsForUsers: %v", err)
+	}
+	if len(teams) != 1 || len(teams[onPage.ID]) != 1 || teams[onPage.ID][0].TeamID != team.ID {
+		t.Fatalf("memberships = %+v, want only user %d in team %d", teams, onPage.ID, team.ID)
+	}
+}
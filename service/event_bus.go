@@ -0,0 +1,189 @@
+package service
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"project/compiled"
+)
+
+const (
+	OutboxStatusPending    = "pending"
+	OutboxStatusDispatched = "dispatched"
+	OutboxStatusFailed     = "failed"
+
+	outboxPollInterval = time.Second
+	outboxBatchSize    = 100
+	outboxMaxAttempts  = 15
+	outboxBaseBackoff  = 5 * time.Second
+	outboxMaxBackoff   = time.Hour
+	outboxRetention    = 7 * 24 * time.Hour
+)
+
+// EventMeta describes an outbox entry. ID is stable across redeliveries, so
+// subscribers can use it to drop duplicates. Request holds the client details
+// of the request that recorded the event, if any.
+type EventMeta struct {
+	ID         int64
+	OccurredAt time.Time
+	Request    RequestInfo
+}
+
+type eventSubscriber struct {
+	name   string
+	handle func(ctx context.Context, meta EventMeta, payload []byte) error
+}
+
+// EventBus dispatches outbox events to in-process subscribers. Delivery is at
+// least once: an event is retried until every subscriber has handled it, and
+// subscribers that already succeeded are skipped on retry unless the process
+// dies in between.
+type EventBus struct {
+	queries     *compiled.Queries
+	mu          sync.RWMutex
+	subscribers map[string][]eventSubscriber
+}
+
+func NewEventBus(queries *compiled.Queries) *EventBus {
+	return &EventBus{
+		queries:     queries,
+		subscribers: make(map[string][]eventSubscriber),
+	}
+}
+
+// Subscribe registers fn for events of type E under name, which must be
+// unique per event type and stay the same across restarts.
+func Subscribe[E Event](b *EventBus, name string, fn func(ctx context.Context, meta EventMeta, e E) error) {
+	var zero E
+	eventName := zero.EventName()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subscribers[eventName] = append(b.subscribers[eventName], eventSubscriber{
+		name: name,
+		handle: func(ctx context.Context, meta EventMeta, payload []byte) error {
+			var e E
+			if err := json.Unmarshal(payload, &e); err != nil {
+				return err
+			}
+			return fn(ctx, meta, e)
+		},
+	})
+}
+
+// Run dispatches outbox events until ctx is cancelled.
+func (b *EventBus) Run(ctx context.Context) {
+	ticker := time.NewTicker(outboxPollInterval)
+	defer ticker.Stop()
+
+	var lastPurge time.Time
+	for {
+		b.dispatchDue(ctx)
+		if time.Since(lastPurge) >= time.Hour {
+			lastPurge = time.Now()
+			if err := b.queries.PurgeDispatchedOutboxEvents(ctx, pgtype.Interval{Microseconds: outboxRetention.Microseconds(), Valid: true}); err != nil && ctx.Err() == nil {
+				slog.ErrorContext(ctx, "Failed to purge outbox events", "error", err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (b *EventBus) dispatchDue(ctx context.Context) {
+	for ctx.Err() == nil {
+		batch, err := b.queries.ClaimOutboxEvents(ctx, outboxBatchSize)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to claim outbox events", "error", err)
+			return
+		}
+		if len(batch) == 0 {
+			return
+		}
+
+		// Subscribers see events in the order they were recorded
+		slices.SortFunc(batch, func(a, b compiled.ClaimOutboxEventsRow) int { return cmp.Compare(a.ID, b.ID) })
+		for _, event := range batch {
+			if err := b.dispatch(ctx, event); err != nil {
+				slog.ErrorContext(ctx, "Failed to record outbox event", "event_id", event.ID, "error", err)
+			}
+		}
+	}
+}
+
+// dispatch hands the event to every subscriber that has not handled it yet
+// and records the outcome.
+func (b *EventBus) dispatch(ctx context.Context, event compiled.ClaimOutboxEventsRow) error {
+	b.mu.RLock()
+	subscribers := b.subscribers[event.EventType]
+	b.mu.RUnlock()
+
+	meta := EventMeta{
+		ID:         event.ID,
+		OccurredAt: event.CreatedAt.Time,
+		Request:    RequestInfo{IP: event.IpAddress.String, UserAgent: event.UserAgent.String},
+	}
+	handled := event.HandledBy
+	var errs []error
+	for _, sub := range subscribers {
+		if slices.Contains(handled, sub.name) {
+			continue
+		}
+		if err := b.handle(ctx, sub, meta, event.Payload); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", sub.name, err))
+			continue
+		}
+		handled = append(handled, sub.name)
+	}
+
+	if len(errs) == 0 {
+		return b.queries.CompleteOutboxEvent(ctx, compiled.CompleteOutboxEventParams{
+			ID:        event.ID,
+			HandledBy: handled,
+		})
+	}
+
+	err := errors.Join(errs...)
+	attempts := event.Attempts + 1
+	status := OutboxStatusPending
+	if attempts >= outboxMaxAttempts {
+		status = OutboxStatusFailed
+		slog.ErrorContext(ctx, "Giving up on outbox event", "event_id", event.ID, "event_type", event.EventType, "attempts", attempts, "error", err)
+	}
+
+	return b.queries.RetryOutboxEvent(ctx, compiled.RetryOutboxEventParams{
+		Status:     status,
+		HandledBy:  handled,
+		LastError:  pgtype.Text{String: err.Error(), Valid: true},
+		RetryAfter: pgtype.Interval{Microseconds: outboxBackoff(attempts).Microseconds(), Valid: true},
+		ID:         event.ID,
+	})
+}
+
+// handle runs one subscriber, turning a panic into an error so a faulty
+// subscriber cannot take the dispatcher down.
+func (b *EventBus) handle(ctx context.Context, sub eventSubscriber, meta EventMeta, payload []byte) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return sub.handle(ctx, meta, payload)
+}
+
+func outboxBackoff(attempts int32) time.Duration {
+	return min(outboxBaseBackoff<<(attempts-1), outboxMaxBackoff)
+}
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"project/compiled"
+)
+
+type testEvent struct {
+	N int `json:"n"`
+}
+
+func (testEvent) EventName() string { return "test.event" }
+
+// loadOutboxEvent reads the event back the way ClaimOutboxEvents returns it,
+// along with its status.
+func loadOutboxEvent(t *testing.T, pool *pgxpool.Pool, id int64) (compiled.ClaimOutboxEventsRow, string) {
+	t.Helper()
+
+	var row compiled.ClaimOutboxEventsRow
+	var status string
+	err := pool.QueryRow(context.Background(),
+		`SELECT id, event_type, payload, handled_by, attempts, ip_address, user_agent, created_at, status
+		 FROM outbox_events WHERE id = $1`, id,
+	).Scan(&row.ID, &row.EventType, &row.Payload, &row.HandledBy, &row.Attempts, &row.IpAddress, &row.UserAgent, &row.CreatedAt, &status)
+	if err != nil {
+		t.Fatalf("load outbox event: %v", err)
+	}
+	return row, status
+}
+
+func TestEventBusRetriesOnlyFailedSubscribers(t *testing.T) {
+	pool, queries := testDB(t)
+	ctx := WithRequestInfo(context.Background(), RequestInfo{IP: "203.0.113.7", UserAgent: "test-agent"})
+
+	if err := recordEvent(ctx, queries, testEvent{N: 42}); err != nil {
+		t.Fatalf("recordEvent: %v", err)
+	}
+	var id int64
+	if err := pool.QueryRow(ctx, `SELECT MAX(id) FROM outbox_events WHERE event_type = 'test.event'`).Scan(&id); err != nil {
+		t.Fatalf("find event: %v", err)
+	}
+
+	bus := NewEventBus(queries)
+	var okCalls, flakyCalls int
+	Subscribe(bus, "ok", func(ctx context.Context, meta EventMeta, e testEvent) error {
+		okCalls++
+		if meta.ID != id || e.N != 42 || meta.Request.IP != "203.0.113.7" || meta.Request.UserAgent != "test-agent" {
+			t.Errorf("ok got meta %+v, event %+v", meta, e)
+		}
+		return nil
+	})
+	Subscribe(bus, "flaky", func(ctx context.Context, meta EventMeta, e testEvent) error {
+		flakyCalls++
+		switch flakyCalls {
+		case 1:
+			return errors.New("temporarily unavailable")
+		case 2:
+			panic("boom")
+		}
+		return nil
+	})
+
+	// The first two attempts fail in the flaky subscriber, by error and
+	// by panic; the event stays pending and remembers who handled it
+	for attempt := 1; attempt <= 2; attempt++ {
+		row, _ := loadOutboxEvent(t, pool, id)
+		if err := bus.dispatch(ctx, row); err != nil {
+			t.Fatalf("dispatch %d: %v", attempt, err)
+		}
+		row, status := loadOutboxEvent(t, pool, id)
+		if status != OutboxStatusPending || int(row.Attempts) != attempt {
+			t.Fatalf("after attempt %d: status %q, attempts %d", attempt, status, row.Attempts)
+		}
+		if !slices.Equal(row.HandledBy, []string{"ok"}) {
+			t.Fatalf("after attempt %d: handled_by = %v, want [ok]", attempt, row.HandledBy)
+		}
+	}
+
+	row, _ := loadOutboxEvent(t, pool, id)
+	if err := bus.dispatch(ctx, row); err != nil {
+		t.Fatalf("dispatch: %v", err)
+	}
+	row, status := loadOutboxEvent(t, pool, id)
+	if status != OutboxStatusDispatched {
+		t.Fatalf("status = %q, want dispatched", status)
+	}
+	if !slices.Equal(row.HandledBy, []string{"ok", "flaky"}) {
+		t.Errorf("handled_by = %v, want [ok flaky]", row.HandledBy)
+	}
+	if okCalls != 1 || flakyCalls != 3 {
+		t.Errorf("calls: ok %d, flaky %d; want 1 and 3", okCalls, flakyCalls)
+	}
+}
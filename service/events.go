@@ -0,0 +1,225 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"project/compiled"
+)
+
+// Event is a domain event. Events are recorded in the outbox in the same
+// transaction as the change they describe and handed to subscribers by the
+// EventBus afterwards.
+type Event interface {
+	EventName() string
+}
+
+// UserLoggedIn is recorded when a user signs in and receives a new session.
+type UserLoggedIn struct {
+	UserID int32  `json:"user_id"`
+	Email  string `json:"email"`
+}
+
+func (UserLoggedIn) EventName() string { return "user.logged_in" }
+
+// LoginFailed is recorded when a sign-in attempt on an existing account is
+// rejected.
+type LoginFailed struct {
+	UserID int32  `json:"user_id"`
+	Reason string `json:"reason"`
+}
+
+func (LoginFailed) EventName() string { return "user.login_failed" }
+
+// MemberInvited is recorded when an invitation is created. UserID is the
+// invitee's account, or 0 when they do not have one yet.
+type MemberInvited struct {
+	CompanyID    int32  `json:"company_id"`
+	InvitationID int32  `json:"invitation_id"`
+	InvitedBy    int32  `json:"invited_by"`
+	UserID       int32  `json:"user_id,omitempty"`
+	Email        string `json:"email"`
+	Name         string `json:"name"`
+	Role         string `json:"role"`
+}
+
+func (MemberInvited) EventName() string { return "member.invited" }
+
+// InvitationResent is recorded when an admin issues a fresh link for an
+// invitation.
+type InvitationResent struct {
+	CompanyID    int32 `json:"company_id"`
+	InvitationID int32 `json:"invitation_id"`
+	ResentBy     int32 `json:"resent_by"`
+}
+
+func (InvitationResent) EventName() string { return "invitation.resent" }
+
+// InvitationRevoked is recorded when an admin revokes a pending invitation.
+type InvitationRevoked struct {
+	CompanyID    int32  `json:"company_id"`
+	InvitationID int32  `json:"invitation_id"`
+	RevokedBy    int32  `json:"revoked_by"`
+	Email        string `json:"email"`
+}
+
+func (InvitationRevoked) EventName() string { return "invitation.revoked" }
+
+// MemberJoined is recorded when a user becomes a member. AddedBy is the admin
+// who approved or restored the membership, or 0 when the user joined on
+// their own.
+type MemberJoined struct {
+	CompanyID int32  `json:"company_id"`
+	UserID    int32  `json:"user_id"`
+	Email     string `json:"email"`
+	Name      string `json:"name"`
+	Role      string `json:"role"`
+	Source    string `json:"source"`
+	AddedBy   int32  `json:"added_by,omitempty"`
+}
+
+func (MemberJoined) EventName() string { return "member.joined" }
+
+// MemberRemoved is recorded when a membership ends. RemovedBy equals UserID
+// when the member left on their own, including by deleting their account.
+type MemberRemoved struct {
+	CompanyID int32  `json:"company_id"`
+	UserID    int32  `json:"user_id"`
+	Email     string `json:"email"`
+	Name      string `json:"name"`
+	Role      string `json:"role"`
+	RemovedBy int32  `json:"removed_by"`
+	Reason    string `json:"reason"`
+}
+
+func (MemberRemoved) EventName() string { return "member.removed" }
+
+const (
+	MemberRemovedReasonRemoved        = "removed"
+	MemberRemovedReasonLeft           = "left"
+	MemberRemovedReasonAccountDeleted = "account_deleted"
+)
+
+// recordMemberRemoved records MemberRemoved with the member's current email
+// and name.
+func recordMemberRemoved(ctx context.Context, q *compiled.Queries, companyID, userID int32, role string, removedBy int32, reason string) error {
+	user, err := q.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	return recordEvent(ctx, q, MemberRemoved{
+		CompanyID: companyID,
+		UserID:    userID,
+		Email:     user.Email,
+		Name:      user.Name,
+		Role:      role,
+		RemovedBy: removedBy,
+		Reason:    reason,
+	})
+}
+
+// MemberRoleChanged is recorded when an admin changes a member's role.
+type MemberRoleChanged struct {
+	CompanyID    int32  `json:"company_id"`
+	UserID       int32  `json:"user_id"`
+	Email        string `json:"email"`
+	Name         string `json:"name"`
+	Role         string `json:"role"`
+	PreviousRole string `json:"previous_role"`
+	ChangedBy    int32  `json:"changed_by"`
+}
+
+func (MemberRoleChanged) EventName() string { return "member.role_changed" }
+
+// recordMemberEvent records MemberJoined or MemberRoleChanged, depending on
+// eventType, with the member's current email and name.
+func recordMemberEvent(ctx context.Context, q *compiled.Queries, eventType string, companyID int32, event MemberEvent) error {
+	user, err := q.GetUserByID(ctx, event.UserID)
+	if err != nil {
+		return err
+	}
+
+	switch eventType {
+	case WebhookEventMemberJoined:
+		return recordEvent(ctx, q, MemberJoined{
+			CompanyID: companyID,
+			UserID:    event.UserID,
+			Email:     user.Email,
+			Name:      user.Name,
+			Role:      event.Role,
+			Source:    event.Source,
+			AddedBy:   event.ActorID,
+		})
+	case WebhookEventMemberRoleChanged:
+		return recordEvent(ctx, q, MemberRoleChanged{
+			CompanyID:    companyID,
+			UserID:       event.UserID,
+			Email:        user.Email,
+			Name:         user.Name,
+			Role:         event.Role,
+			PreviousRole: event.PreviousRole,
+			ChangedBy:    event.ActorID,
+		})
+	}
+	return fmt.Errorf("no domain event for %s", eventType)
+}
+
+// JoinRequested is recorded when a user asks to join a company.
+type JoinRequested struct {
+	CompanyID int32  `json:"company_id"`
+	RequestID int32  `json:"request_id"`
+	UserID    int32  `json:"user_id"`
+	Message   string `json:"message"`
+}
+
+func (JoinRequested) EventName() string { return "join_request.created" }
+
+// JoinRequestReviewed is recorded when an admin approves or rejects a join
+// request. Role is set for approvals.
+type JoinRequestReviewed struct {
+	CompanyID  int32  `json:"company_id"`
+	RequestID  int32  `json:"request_id"`
+	UserID     int32  `json:"user_id"`
+	Status     string `json:"status"`
+	Role       string `json:"role,omitempty"`
+	ReviewedBy int32  `json:"reviewed_by"`
+}
+
+func (JoinRequestReviewed) EventName() string { return "join_request.reviewed" }
+
+// AccountDeleted is recorded when a user deletes their account, which ends
+// all of their sessions.
+type AccountDeleted struct {
+	UserID int32 `json:"user_id"`
+}
+
+func (AccountDeleted) EventName() string { return "user.account_deleted" }
+
+// CompanySelected is recorded when a user switches their selected company.
+type CompanySelected struct {
+	UserID    int32 `json:"user_id"`
+	CompanyID int32 `json:"company_id"`
+}
+
+func (CompanySelected) EventName() string { return "company.selected" }
+
+// recordEvent writes e to the outbox using q, which should be the
+// transaction making the change, along with the client details from ctx.
+func recordEvent(ctx context.Context, q *compiled.Queries, e Event) error {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	info := requestInfoFromContext(ctx)
+	return q.InsertOutboxEvent(ctx, compiled.InsertOutboxEventParams{
+		EventType: e.EventName(),
+		Payload:   payload,
+		IpAddress: pgtype.Text{String: info.IP, Valid: info.IP != ""},
+		UserAgent: pgtype.Text{String: info.UserAgent, Valid: info.UserAgent != ""},
+	})
+}
@@ -0,0 +1,612 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"project/compiled"
+)
+
+const (
+	ExportKindUser    = "user"
+	ExportKindCompany = "company"
+
+	ExportStatusPending = "pending"
+	ExportStatusRunning = "running"
+	ExportStatusReady   = "ready"
+	ExportStatusFailed  = "failed"
+	ExportStatusExpired = "expired"
+
+	exportPollInterval = 5 * time.Second
+)
+
+var ErrExportNotFound = errors.New("export not found")
+
+// ExportService builds data export archives in the background and serves
+// them through short-lived download tokens.
+type ExportService struct {
+	queries   *compiled.Queries
+	companies *CompanyService
+	mailer    Mailer
+	apiURL    string
+	linkTTL   time.Duration
+}
+
+func NewExportService(queries *compiled.Queries, companies *CompanyService, mailer Mailer, apiURL string, linkTTL time.Duration) *ExportService {
+	return &ExportService{
+		queries:   queries,
+		companies: companies,
+		mailer:    mailer,
+		apiURL:    apiURL,
+		linkTTL:   linkTTL,
+	}
+}
+
+// RequestUserExport queues an export of everything stored about the user.
+func (s *ExportService) RequestUserExport(ctx context.Context, userID int32) (*compiled.CreateDataExportRow, error) {
+	export, err := s.queries.CreateDataExport(ctx, compiled.CreateDataExportParams{
+		Kind:        ExportKindUser,
+		RequestedBy: userID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &export, nil
+}
+
+// RequestCompanyExport queues an export of the whole company. Only admins may
+// request it.
+func (s *ExportService) RequestCompanyExport(ctx context.Context, adminID, companyID int32) (*compiled.CreateDataExportRow, error) {
+	if err := s.companies.requireAdmin(ctx, companyID, adminID); err != nil {
+		return nil, err
+	}
+
+	export, err := s.queries.CreateDataExport(ctx, compiled.CreateDataExportParams{
+		Kind:        ExportKindCompany,
+		RequestedBy: adminID,
+		CompanyID:   pgtype.Int4{Int32: companyID, Valid: true},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &export, nil
+}
+
+// GetExport returns one of the user's own exports.
+func (s *ExportService) GetExport(ctx context.Context, userID, exportID int32) (*compiled.GetDataExportRow, error) {
+	export, err := s.queries.GetDataExport(ctx, compiled.GetDataExportParams{
+		ID:          exportID,
+		RequestedBy: userID,
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrExportNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if export.Status == ExportStatusReady && time.Now().After(export.ExpiresAt.Time) {
+		export.Status = ExportStatusExpired
+	}
+	return &export, nil
+}
+
+// OpenDownload returns the archive behind a download token while the link is
+// still valid.
+func (s *ExportService) OpenDownload(ctx context.Context, token string) (*compiled.GetDataExportArchiveRow, error) {
+	export, err := s.queries.GetDataExportArchive(ctx, pgtype.Text{String: token, Valid: true})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrExportNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &export, nil
+}
+
+// DownloadURL is the gateway link for a ready export.
+func (s *ExportService) DownloadURL(token string) string {
+	return fmt.Sprintf("%s/exports/download?token=%s", s.apiURL, url.QueryEscape(token))
+}
+
+// Run processes queued exports until ctx is cancelled.
+func (s *ExportService) Run(ctx context.Context) {
+	ticker := time.NewTicker(exportPollInterval)
+	defer ticker.Stop()
+
+	for {
+		s.processPending(ctx)
+		if err := s.queries.PurgeExpiredDataExports(ctx); err != nil && ctx.Err() == nil {
+			slog.ErrorContext(ctx, "Failed to purge expired exports", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *ExportService) processPending(ctx context.Context) {
+	for ctx.Err() == nil {
+		job, err := s.queries.ClaimDataExport(ctx)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to claim export", "error", err)
+			return
+		}
+
+		var archive []byte
+		switch job.Kind {
+		case ExportKindUser:
+			archive, err = s.buildUserArchive(ctx, job.RequestedBy)
+		case ExportKindCompany:
+			archive, err = s.buildCompanyArchive(ctx, job.CompanyID.Int32)
+		default:
+			err = fmt.Errorf("unknown export kind %q", job.Kind)
+		}
+		if err != nil {
+			slog.ErrorContext(ctx, "Export failed", "export_id", job.ID, "error", err)
+			if err := s.queries.FailDataExport(ctx, compiled.FailDataExportParams{
+				Error: pgtype.Text{String: "the export could not be generated", Valid: true},
+				ID:    job.ID,
+			}); err != nil {
+				slog.ErrorContext(ctx, "Failed to mark export as failed", "export_id", job.ID, "error", err)
+			}
+			continue
+		}
+
+		token := generateToken(48)
+		expiresAt := time.Now().Add(s.linkTTL)
+		if err := s.queries.CompleteDataExport(ctx, compiled.CompleteDataExportParams{
+			Archive:       archive,
+			DownloadToken: pgtype.Text{String: token, Valid: true},
+			ExpiresAt:     pgtype.Timestamp{Time: expiresAt, Valid: true},
+			ID:            job.ID,
+		}); err != nil {
+			slog.ErrorContext(ctx, "Failed to store export", "export_id", job.ID, "error", err)
+			continue
+		}
+
+		s.notifyExportReady(ctx, job.RequestedBy, token, expiresAt)
+	}
+}
+
+func (s *ExportService) notifyExportReady(ctx context.Context, userID int32, token string, expiresAt time.Time) {
+	user, err := s.queries.GetUserByID(ctx, userID)
+	if err != nil {
+		return
+	}
+	_ = s.mailer.Send(ctx, user.Email, "Your data export is ready",
+		fmt.Sprintf("Hi %s,\n\nYour data export is ready to download: %s\n\nThis link expires on %s.",
+			user.Name, s.DownloadURL(token), expiresAt.Format("2006-01-02 15:04 MST")))
+}
+
+type exportProfile struct {
+	ID                int32      `json:"id"`
+	Email             string     `json:"email"`
+	Name              string     `json:"name"`
+	SelectedCompanyID *int32     `json:"selected_company_id"`
+	CreatedAt         *time.Time `json:"created_at"`
+}
+
+type exportMembership struct {
+	CompanyID   int32    `json:"company_id"`
+	CompanyName string   `json:"company_name"`
+	Role        string   `json:"role"`
+	IsOwner     bool     `json:"is_owner"`
+	Teams       []string `json:"teams"`
+}
+
+type exportSession struct {
+	Type   string `json:"type"`
+	Active bool   `json:"active"`
+}
+
+type exportUserInvitation struct {
+	ID          int32      `json:"id"`
+	CompanyID   int32      `json:"company_id"`
+	CompanyName string     `json:"company_name"`
+	Role        string     `json:"role"`
+	Status      string     `json:"status"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+	RespondedAt *time.Time `json:"responded_at"`
+	CreatedAt   *time.Time `json:"created_at"`
+}
+
+type exportUserJoinRequest struct {
+	ID          int32      `json:"id"`
+	CompanyID   int32      `json:"company_id"`
+	CompanyName string     `json:"company_name"`
+	Message     string     `json:"message"`
+	Status      string     `json:"status"`
+	ReviewedAt  *time.Time `json:"reviewed_at"`
+	CreatedAt   *time.Time `json:"created_at"`
+}
+
+func (s *ExportService) buildUserArchive(ctx context.Context, userID int32) ([]byte, error) {
+	user, err := s.queries.GetUserExportProfile(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	profile := exportProfile{
+		ID:        user.ID,
+		Email:     user.Email,
+		Name:      user.Name,
+		CreatedAt: optionalTime(user.CreatedAt),
+	}
+	if user.SelectedCompanyID.Valid {
+		profile.SelectedCompanyID = &user.SelectedCompanyID.Int32
+	}
+
+	companies, err := s.queries.GetUserCompanies(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	memberships := make([]exportMembership, 0, len(companies))
+	for _, c := range companies {
+		teams, err := s.queries.ListUserTeams(ctx, compiled.ListUserTeamsParams{UserID: userID, CompanyID: c.ID})
+		if err != nil {
+			return nil, err
+		}
+		names := make([]string, 0, len(teams))
+		for _, t := range teams {
+			names = append(names, t.Name)
+		}
+		memberships = append(memberships, exportMembership{
+			CompanyID:   c.ID,
+			CompanyName: c.CompanyName,
+			Role:        c.Role,
+			IsOwner:     c.OwnerID == userID,
+			Teams:       names,
+		})
+	}
+
+	// Each user has a single bearer token; there is no per-device history
+	sessions := []exportSession{{Type: "api_token", Active: user.HasSession}}
+
+	invitationRows, err := s.queries.ListUserInvitations(ctx, user.Email)
+	if err != nil {
+		return nil, err
+	}
+	invitations := make([]exportUserInvitation, 0, len(invitationRows))
+	for _, i := range invitationRows {
+		invitations = append(invitations, exportUserInvitation{
+			ID:          i.ID,
+			CompanyID:   i.CompanyID,
+			CompanyName: i.CompanyName,
+			Role:        i.Role,
+			Status:      i.Status,
+			ExpiresAt:   optionalTime(i.ExpiresAt),
+			RespondedAt: optionalTime(i.RespondedAt),
+			CreatedAt:   optionalTime(i.CreatedAt),
+		})
+	}
+
+	requestRows, err := s.queries.ListUserJoinRequests(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	joinRequests := make([]exportUserJoinRequest, 0, len(requestRows))
+	for _, r := range requestRows {
+		joinRequests = append(joinRequests, exportUserJoinRequest{
+			ID:          r.ID,
+			CompanyID:   r.CompanyID,
+			CompanyName: r.CompanyName,
+			Message:     r.Message,
+			Status:      r.Status,
+			ReviewedAt:  optionalTime(r.ReviewedAt),
+			CreatedAt:   optionalTime(r.CreatedAt),
+		})
+	}
+
+	auditRows, err := s.queries.ListUserAuditEvents(ctx, pgtype.Int4{Int32: userID, Valid: true})
+	if err != nil {
+		return nil, err
+	}
+	activity := make([]exportAuditEvent, 0, len(auditRows))
+	for _, a := range auditRows {
+		event := exportAuditEvent{
+			Action:       a.Action,
+			ActorID:      optionalInt(a.ActorID),
+			TargetUserID: optionalInt(a.TargetUserID),
+			CompanyID:    optionalInt(a.CompanyID),
+			Metadata:     json.RawMessage(a.Metadata),
+			CreatedAt:    optionalTime(a.CreatedAt),
+		}
+		// The client details belong to whoever acted, which for changes made
+		// to the user by an admin, or failed sign-ins, is someone else
+		if a.ActorID.Valid && a.ActorID.Int32 == userID {
+			event.IPAddress = a.IpAddress.String
+			event.UserAgent = a.UserAgent.String
+		}
+		activity = append(activity, event)
+	}
+
+	return buildArchive(map[string]any{
+		"profile.json":       profile,
+		"memberships.json":   memberships,
+		"sessions.json":      sessions,
+		"invitations.json":   invitations,
+		"join_requests.json": joinRequests,
+		"activity.json":      activity,
+	})
+}
+
+type exportAuditEvent struct {
+	Action       string          `json:"action"`
+	ActorID      *int32          `json:"actor_id"`
+	TargetUserID *int32          `json:"target_user_id"`
+	CompanyID    *int32          `json:"company_id"`
+	IPAddress    string          `json:"ip_address"`
+	UserAgent    string          `json:"user_agent"`
+	Metadata     json.RawMessage `json:"metadata"`
+	CreatedAt    *time.Time      `json:"created_at"`
+}
+
+type exportCompany struct {
+	ID              int32           `json:"id"`
+	Name            string          `json:"name"`
+	Description     string          `json:"description"`
+	Website         string          `json:"website"`
+	OwnerID         int32           `json:"owner_id"`
+	ParentCompanyID *int32          `json:"parent_company_id"`
+	CreatedAt       *time.Time      `json:"created_at"`
+	ArchivedAt      *time.Time      `json:"archived_at"`
+	Plan            string          `json:"plan"`
+	Settings        CompanySettings `json:"settings"`
+}
+
+type exportMember struct {
+	UserID int32    `json:"user_id"`
+	Name   string   `json:"name"`
+	Email  string   `json:"email"`
+	Role   string   `json:"role"`
+	Teams  []string `json:"teams"`
+}
+
+type exportTeam struct {
+	ID           int32      `json:"id"`
+	Name         string     `json:"name"`
+	ParentTeamID *int32     `json:"parent_team_id"`
+	MemberCount  int64      `json:"member_count"`
+	CreatedAt    *time.Time `json:"created_at"`
+}
+
+type exportInvitation struct {
+	ID          int32      `json:"id"`
+	Email       string     `json:"email"`
+	Name        string     `json:"name"`
+	Role        string     `json:"role"`
+	Status      string     `json:"status"`
+	InvitedBy   *int32     `json:"invited_by"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+	RespondedAt *time.Time `json:"responded_at"`
+	CreatedAt   *time.Time `json:"created_at"`
+}
+
+type exportJoinLink struct {
+	ID          int32      `json:"id"`
+	Role        string     `json:"role"`
+	MaxUses     *int32     `json:"max_uses"`
+	UseCount    int32      `json:"use_count"`
+	EmailDomain string     `json:"email_domain"`
+	CreatedBy   *int32     `json:"created_by"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+	DisabledAt  *time.Time `json:"disabled_at"`
+	CreatedAt   *time.Time `json:"created_at"`
+}
+
+type exportJoinRequest struct {
+	ID         int32      `json:"id"`
+	UserID     int32      `json:"user_id"`
+	Message    string     `json:"message"`
+	Status     string     `json:"status"`
+	ReviewedBy *int32     `json:"reviewed_by"`
+	ReviewedAt *time.Time `json:"reviewed_at"`
+	CreatedAt  *time.Time `json:"created_at"`
+}
+
+type exportDomain struct {
+	ID         int32      `json:"id"`
+	Domain     string     `json:"domain"`
+	JoinRole   string     `json:"join_role"`
+	VerifiedAt *time.Time `json:"verified_at"`
+	CreatedAt  *time.Time `json:"created_at"`
+}
+
+func (s *ExportService) buildCompanyArchive(ctx context.Context, companyID int32) ([]byte, error) {
+	c, err := s.queries.GetCompanyByID(ctx, companyID)
+	if err != nil {
+		return nil, err
+	}
+	subscription, err := s.companies.GetCompanySubscription(ctx, companyID)
+	if err != nil {
+		return nil, err
+	}
+	settings, err := s.companies.CompanySettings(ctx, companyID)
+	if err != nil {
+		return nil, err
+	}
+
+	company := exportCompany{
+		ID:          c.ID,
+		Name:        c.CompanyName,
+		Description: c.Description,
+		Website:     c.Website,
+		OwnerID:     c.OwnerID,
+		CreatedAt:   optionalTime(c.CreatedAt),
+		ArchivedAt:  optionalTime(c.ArchivedAt),
+		Plan:        subscription.Plan.Code,
+		Settings:    settings,
+	}
+	if c.ParentCompanyID.Valid {
+		company.ParentCompanyID = &c.ParentCompanyID.Int32
+	}
+
+	memberRows, err := s.queries.GetCompanyMembers(ctx, companyID)
+	if err != nil {
+		return nil, err
+	}
+	teamsByUser, err := s.companies.GetCompanyTeamMemberships(ctx, companyID)
+	if err != nil {
+		return nil, err
+	}
+	members := make([]exportMember, 0, len(memberRows))
+	for _, m := range memberRows {
+		teams := make([]string, 0, len(teamsByUser[m.ID]))
+		for _, t := range teamsByUser[m.ID] {
+			teams = append(teams, t.TeamName)
+		}
+		members = append(members, exportMember{UserID: m.ID, Name: m.Name, Email: m.Email, Role: m.Role, Teams: teams})
+	}
+
+	teamRows, err := s.queries.ListCompanyTeams(ctx, companyID)
+	if err != nil {
+		return nil, err
+	}
+	teams := make([]exportTeam, 0, len(teamRows))
+	for _, t := range teamRows {
+		team := exportTeam{ID: t.ID, Name: t.Name, MemberCount: t.MemberCount, CreatedAt: optionalTime(t.CreatedAt)}
+		if t.ParentTeamID.Valid {
+			team.ParentTeamID = &t.ParentTeamID.Int32
+		}
+		teams = append(teams, team)
+	}
+
+	// Tokens are credentials and stay out of the archive
+	invitationRows, err := s.queries.ListCompanyInvitations(ctx, companyID)
+	if err != nil {
+		return nil, err
+	}
+	invitations := make([]exportInvitation, 0, len(invitationRows))
+	for _, i := range invitationRows {
+		invitations = append(invitations, exportInvitation{
+			ID:          i.ID,
+			Email:       i.Email,
+			Name:        i.Name,
+			Role:        i.Role,
+			Status:      i.Status,
+			InvitedBy:   optionalInt(i.InvitedBy),
+			ExpiresAt:   optionalTime(i.ExpiresAt),
+			RespondedAt: optionalTime(i.RespondedAt),
+			CreatedAt:   optionalTime(i.CreatedAt),
+		})
+	}
+
+	linkRows, err := s.queries.ListCompanyJoinLinks(ctx, companyID)
+	if err != nil {
+		return nil, err
+	}
+	joinLinks := make([]exportJoinLink, 0, len(linkRows))
+	for _, l := range linkRows {
+		link := exportJoinLink{
+			ID:          l.ID,
+			Role:        l.Role,
+			MaxUses:     optionalInt(l.MaxUses),
+			UseCount:    l.UseCount,
+			EmailDomain: l.EmailDomain.String,
+			CreatedBy:   optionalInt(l.CreatedBy),
+			ExpiresAt:   optionalTime(l.ExpiresAt),
+			DisabledAt:  optionalTime(l.DisabledAt),
+			CreatedAt:   optionalTime(l.CreatedAt),
+		}
+		joinLinks = append(joinLinks, link)
+	}
+
+	requestRows, err := s.queries.ListCompanyJoinRequests(ctx, companyID)
+	if err != nil {
+		return nil, err
+	}
+	joinRequests := make([]exportJoinRequest, 0, len(requestRows))
+	for _, r := range requestRows {
+		request := exportJoinRequest{
+			ID:         r.ID,
+			UserID:     r.UserID,
+			Message:    r.Message,
+			Status:     r.Status,
+			ReviewedBy: optionalInt(r.ReviewedBy),
+			ReviewedAt: optionalTime(r.ReviewedAt),
+			CreatedAt:  optionalTime(r.CreatedAt),
+		}
+		joinRequests = append(joinRequests, request)
+	}
+
+	domainRows, err := s.queries.ListCompanyDomains(ctx, companyID)
+	if err != nil {
+		return nil, err
+	}
+	domains := make([]exportDomain, 0, len(domainRows))
+	for _, d := range domainRows {
+		domains = append(domains, exportDomain{
+			ID:         d.ID,
+			Domain:     d.Domain,
+			JoinRole:   d.JoinRole,
+			VerifiedAt: optionalTime(d.VerifiedAt),
+			CreatedAt:  optionalTime(d.CreatedAt),
+		})
+	}
+
+	return buildArchive(map[string]any{
+		"company.json":       company,
+		"members.json":       members,
+		"teams.json":         teams,
+		"invitations.json":   invitations,
+		"join_links.json":    joinLinks,
+		"join_requests.json": joinRequests,
+		"domains.json":       domains,
+	})
+}
+
+// buildArchive writes each value as an indented JSON file into a ZIP.
+func buildArchive(files map[string]any) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+
+	for _, name := range slices.Sorted(maps.Keys(files)) {
+		w, err := zw.Create(name)
+		if err != nil {
+			return nil, err
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(files[name]); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func optionalTime(ts pgtype.Timestamp) *time.Time {
+	if !ts.Valid {
+		return nil
+	}
+	return &ts.Time
+}
+
+func optionalInt(v pgtype.Int4) *int32 {
+	if !v.Valid {
+		return nil
+	}
+	return &v.Int32
+}
@@ -0,0 +1,73 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestUserArchiveOnlyHasOwnClientDetails(t *testing.T) {
+	pool, queries := testDB(t)
+	companies := newTestCompanyService(pool, queries, nil)
+	s := NewExportService(queries, companies, noopMailer{}, "http://api.test", time.Hour)
+	ctx := context.Background()
+
+	admin := newTestUser(t, queries, "example.com")
+	user := newTestUser(t, queries, "example.com")
+	id := time.Now().UnixNano()
+	entries := []struct {
+		meta  EventMeta
+		entry AuditEntry
+	}{
+		{
+			EventMeta{ID: id, OccurredAt: time.Now().UTC(), Request: RequestInfo{IP: "203.0.113.1", UserAgent: "user-agent"}},
+			AuditEntry{Action: AuditUserLoggedIn, ActorID: user.ID},
+		},
+		{
+			EventMeta{ID: id + 1, OccurredAt: time.Now().UTC(), Request: RequestInfo{IP: "198.51.100.2", UserAgent: "admin-agent"}},
+			AuditEntry{Action: AuditMemberRoleChanged, ActorID: admin.ID, TargetUserID: user.ID},
+		},
+	}
+	for _, e := range entries {
+		if err := recordAudit(ctx, queries, e.meta, e.entry); err != nil {
+			t.Fatalf("recordAudit: %v", err)
+		}
+	}
+
+	archive, err := s.buildUserArchive(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("buildUserArchive: %v", err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		t.Fatalf("open archive: %v", err)
+	}
+	f, err := zr.Open("activity.json")
+	if err != nil {
+		t.Fatalf("open activity.json: %v", err)
+	}
+	defer f.Close()
+	var activity []exportAuditEvent
+	if err := json.NewDecoder(f).Decode(&activity); err != nil {
+		t.Fatalf("decode activity.json: %v", err)
+	}
+
+	if len(activity) != 2 {
+		t.Fatalf("got %d events, want 2", len(activity))
+	}
+	for _, e := range activity {
+		switch e.Action {
+		case AuditUserLoggedIn:
+			if e.IPAddress != "203.0.113.1" || e.UserAgent != "user-agent" {
+				t.Errorf("own login lost its client details: %+v", e)
+			}
+		case AuditMemberRoleChanged:
+			if e.IPAddress != "" || e.UserAgent != "" {
+				t.Errorf("admin's client details exported: %+v", e)
+			}
+		}
+	}
+}
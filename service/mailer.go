@@ -0,0 +1,42 @@
+package service
+
+import (
+	"context"
+
+	"github.com/resend/resend-go/v2"
+)
+
+const mailFrom = "noreply@mg.lavorus.com"
+
+type Mailer interface {
+	Send(ctx context.Context, to, subject, text string) error
+}
+
+// NewMailer returns a Resend-backed mailer, or a mailer that drops every
+// message when no API key is configured (local development).
+func NewMailer(resendAPIKey string) Mailer {
+	if resendAPIKey == "" {
+		return noopMailer{}
+	}
+	return &resendMailer{client: resend.NewClient(resendAPIKey)}
+}
+
+type resendMailer struct {
+	client *resend.Client
+}
+
+func (m *resendMailer) Send(ctx context.Context, to, subject, text string) error {
+	_, err := m.client.Emails.SendWithContext(ctx, &resend.SendEmailRequest{
+		From:    mailFrom,
+		To:      []string{to},
+		Subject: subject,
+		Text:    text,
+	})
+	return err
+}
+
+type noopMailer struct{}
+
+func (noopMailer) Send(ctx context.Context, to, subject, text string) error {
+	return nil
+}
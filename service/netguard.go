@@ -0,0 +1,97 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"syscall"
+	"time"
+)
+
+var ErrDestinationNotPublic = errors.New("destination is not a public address")
+
+// nonPublicPrefixes are special-purpose ranges that netip does not classify
+// as private, loopback or link-local.
+var nonPublicPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+	netip.MustParsePrefix("64:ff9b:1::/48"),
+}
+
+// isPublicAddr reports whether addr is a globally routable unicast address.
+func isPublicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() {
+		return false
+	}
+	for _, prefix := range nonPublicPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// checkPublicHost rejects hosts that are obviously internal: IP literals
+// outside public ranges and localhost names. Hostnames are checked again
+// after resolution by the dialer, which is what actually enforces the rule.
+func checkPublicHost(host string) error {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return ErrDestinationNotPublic
+	}
+	if addr, err := netip.ParseAddr(strings.Trim(host, "[]")); err == nil && !isPublicAddr(addr) {
+		return ErrDestinationNotPublic
+	}
+	return nil
+}
+
+// publicDialer returns a dialer that refuses to connect to non-public
+// addresses. The check runs on the resolved address of every connection,
+// so names that resolve, or later re-resolve, to internal addresses are
+// refused too.
+func publicDialer(timeout time.Duration) *net.Dialer {
+	return &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			addr, err := netip.ParseAddr(host)
+			if err != nil {
+				return err
+			}
+			if !isPublicAddr(addr) {
+				return fmt.Errorf("%w: %s", ErrDestinationNotPublic, addr)
+			}
+			return nil
+		},
+	}
+}
+
+// publicHTTPClient returns a client for requests to customer-supplied URLs.
+// It only connects to public addresses, ignores proxy settings, which would
+// bypass the check, and does not follow redirects.
+func publicHTTPClient(timeout time.Duration) *http.Client {
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext:           publicDialer(timeout).DialContext,
+			TLSHandshakeTimeout:   timeout,
+			ResponseHeaderTimeout: timeout,
+			MaxIdleConnsPerHost:   2,
+			IdleConnTimeout:       90 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
@@ -0,0 +1,93 @@
+package service
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func TestIsPublicAddr(t *testing.T) {
+	tests := []struct {
+		addr   string
+		public bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"127.0.0.1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"100.64.0.1", false},
+		{"0.0.0.0", false},
+		{"255.255.255.255", false},
+		{"::1", false},
+		{"fd00::1", false},
+		{"fe80::1", false},
+		{"::ffff:127.0.0.1", false},
+		{"64:ff9b::a9fe:a9fe", false},
+	}
+	for _, tt := range tests {
+		if got := isPublicAddr(netip.MustParseAddr(tt.addr)); got != tt.public {
+			t.Errorf("isPublicAddr(%s) = %v, want %v", tt.addr, got, tt.public)
+		}
+	}
+}
+
+func TestCheckPublicHost(t *testing.T) {
+	for _, host := range []string{"localhost", "api.localhost", "127.0.0.1", "[::1]", "169.254.169.254"} {
+		if err := checkPublicHost(host); !errors.Is(err, ErrDestinationNotPublic) {
+			t.Errorf("checkPublicHost(%q) = %v, want ErrDestinationNotPublic", host, err)
+		}
+	}
+	for _, host := range []string{"example.com", "93.184.216.34"} {
+		if err := checkPublicHost(host); err != nil {
+			t.Errorf("checkPublicHost(%q) = %v, want nil", host, err)
+		}
+	}
+}
+
+func TestPublicHTTPClientRefusesLoopback(t *testing.T) {
+	called := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer srv.Close()
+
+	_, err := publicHTTPClient(time.Second).Get(srv.URL)
+	if !errors.Is(err, ErrDestinationNotPublic) {
+		t.Fatalf("Get(%s) = %v, want ErrDestinationNotPublic", srv.URL, err)
+	}
+	if called {
+		t.Error("request reached the loopback server")
+	}
+}
+
+func TestPublicHTTPClientDoesNotFollowRedirects(t *testing.T) {
+	followed := false
+	mux := http.NewServeMux()
+	mux.HandleFunc("/hook", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/internal", http.StatusFound)
+	})
+	mux.HandleFunc("/internal", func(w http.ResponseWriter, r *http.Request) {
+		followed = true
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	// Keep the redirect policy but allow the loopback test server
+	client := publicHTTPClient(time.Second)
+	client.Transport = http.DefaultTransport
+
+	resp, err := client.Get(srv.URL + "/hook")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound || followed {
+		t.Fatalf("status = %d, followed = %v; want 302 without following", resp.StatusCode, followed)
+	}
+}
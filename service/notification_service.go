@@ -0,0 +1,422 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"project/compiled"
+)
+
+const (
+	NotificationInvitationReceived  = "invitation.received"
+	NotificationMemberRoleChanged   = "member.role_changed"
+	NotificationMemberRemoved       = "member.removed"
+	NotificationJoinRequestReceived = "join_request.received"
+	NotificationJoinRequestReviewed = "join_request.reviewed"
+
+	defaultNotificationPageSize = 20
+	maxNotificationPageSize     = 100
+	notificationRetention       = 90 * 24 * time.Hour
+)
+
+// NotificationPreference says how a user wants to receive one notification
+// type.
+type NotificationPreference struct {
+	Type  string
+	InApp bool
+	Email bool
+}
+
+// DefaultNotificationPreferences apply to types a user has not configured.
+// Invitations are emailed by the invitation flow itself, so their
+// notification email is off by default.
+var DefaultNotificationPreferences = []NotificationPreference{
+	{Type: NotificationInvitationReceived, InApp: true, Email: false},
+	{Type: NotificationMemberRoleChanged, InApp: true, Email: true},
+	{Type: NotificationMemberRemoved, InApp: true, Email: true},
+	{Type: NotificationJoinRequestReceived, InApp: true, Email: true},
+	{Type: NotificationJoinRequestReviewed, InApp: true, Email: true},
+}
+
+var ErrNotificationTypeUnknown = errors.New("unknown notification type")
+
+type NotificationListOptions struct {
+	PageSize   int32
+	PageToken  string
+	UnreadOnly bool
+}
+
+type NotificationPage struct {
+	Notifications []compiled.ListNotificationsRow
+	NextPageToken string
+}
+
+type notificationPageToken struct {
+	UnreadOnly bool  `json:"u"`
+	BeforeID   int64 `json:"b"`
+}
+
+// notification is one message to a user, before preferences are applied.
+type notification struct {
+	Type      string
+	CompanyID int32
+	Title     string
+	Body      string
+	Data      map[string]any
+}
+
+// NotificationService keeps each user's notification inbox and emails the
+// notification types the user asked for by email. Notifications are created
+// from domain events on the EventBus.
+type NotificationService struct {
+	pool    *pgxpool.Pool
+	queries *compiled.Queries
+	mailer  Mailer
+	appURL  string
+}
+
+func NewNotificationService(pool *pgxpool.Pool, queries *compiled.Queries, mailer Mailer, appURL string) *NotificationService {
+	return &NotificationService{
+		pool:    pool,
+		queries: queries,
+		mailer:  mailer,
+		appURL:  appURL,
+	}
+}
+
+// ListNotifications returns one page of the user's inbox, newest first.
+func (s *NotificationService) ListNotifications(ctx context.Context, userID int32, opts NotificationListOptions) (*NotificationPage, error) {
+	if opts.PageSize < 0 {
+		return nil, ErrInvalidPageSize
+	}
+	pageSize := opts.PageSize
+	if pageSize == 0 {
+		pageSize = defaultNotificationPageSize
+	}
+	pageSize = min(pageSize, maxNotificationPageSize)
+
+	var before notificationPageToken
+	if opts.PageToken != "" {
+		if err := decodePageToken(opts.PageToken, &before); err != nil || before.UnreadOnly != opts.UnreadOnly {
+			return nil, ErrInvalidPageToken
+		}
+	}
+
+	rows, err := s.queries.ListNotifications(ctx, compiled.ListNotificationsParams{
+		UserID:     userID,
+		UnreadOnly: opts.UnreadOnly,
+		BeforeID:   pgtype.Int8{Int64: before.BeforeID, Valid: opts.PageToken != ""},
+		PageLimit:  pageSize + 1,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	page := &NotificationPage{Notifications: rows}
+	if len(rows) > int(pageSize) {
+		page.Notifications = rows[:pageSize]
+		last := page.Notifications[pageSize-1]
+		page.NextPageToken = encodePageToken(notificationPageToken{UnreadOnly: opts.UnreadOnly, BeforeID: last.ID})
+	}
+	return page, nil
+}
+
+func (s *NotificationService) UnreadCount(ctx context.Context, userID int32) (int64, error) {
+	return s.queries.CountUnreadNotifications(ctx, userID)
+}
+
+// MarkRead marks the given notifications, or all of them, as read and
+// returns how many were unread. IDs of other users' notifications are
+// ignored.
+func (s *NotificationService) MarkRead(ctx context.Context, userID int32, ids []int64, all bool) (int64, error) {
+	if all {
+		return s.queries.MarkAllNotificationsRead(ctx, userID)
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	return s.queries.MarkNotificationsRead(ctx, compiled.MarkNotificationsReadParams{
+		UserID: userID,
+		Ids:    ids,
+	})
+}
+
+// Preferences returns the user's preference for every notification type.
+func (s *NotificationService) Preferences(ctx context.Context, userID int32) ([]NotificationPreference, error) {
+	rows, err := s.queries.ListNotificationPreferences(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	prefs := slices.Clone(DefaultNotificationPreferences)
+	for i, pref := range prefs {
+		for _, row := range rows {
+			if row.Type == pref.Type {
+				prefs[i] = NotificationPreference{Type: row.Type, InApp: row.InApp, Email: row.Email}
+			}
+		}
+	}
+	return prefs, nil
+}
+
+// UpdatePreferences stores the given preferences; types left out keep
+// their current setting.
+func (s *NotificationService) UpdatePreferences(ctx context.Context, userID int32, prefs []NotificationPreference) ([]NotificationPreference, error) {
+	for _, pref := range prefs {
+		if !isNotificationType(pref.Type) {
+			return nil, fmt.Errorf("%w: %s", ErrNotificationTypeUnknown, pref.Type)
+		}
+	}
+
+	err := runInTx(ctx, s.pool, s.queries, func(q *compiled.Queries) error {
+		for _, pref := range prefs {
+			if err := q.UpsertNotificationPreference(ctx, compiled.UpsertNotificationPreferenceParams{
+				UserID: userID,
+				Type:   pref.Type,
+				InApp:  pref.InApp,
+				Email:  pref.Email,
+			}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.Preferences(ctx, userID)
+}
+
+// Subscribe creates notifications from membership events on the bus.
+func (s *NotificationService) Subscribe(bus *EventBus) {
+	Subscribe(bus, "notifications", s.onMemberInvited)
+	Subscribe(bus, "notifications", s.onMemberRoleChanged)
+	Subscribe(bus, "notifications", s.onMemberRemoved)
+	Subscribe(bus, "notifications", s.onJoinRequested)
+	Subscribe(bus, "notifications", s.onJoinRequestReviewed)
+}
+
+// onMemberInvited notifies invitees who already have an account.
+func (s *NotificationService) onMemberInvited(ctx context.Context, meta EventMeta, e MemberInvited) error {
+	user, err := s.queries.FindUserByEmail(ctx, e.Email)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	company, err := s.queries.GetCompanyByID(ctx, e.CompanyID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return s.notify(ctx, meta, user.ID, user.Email, user.Name, notification{
+		Type:      NotificationInvitationReceived,
+		CompanyID: e.CompanyID,
+		Title:     fmt.Sprintf("You have been invited to join %s", company.CompanyName),
+		Body:      fmt.Sprintf("You have been invited to join %s as %s. Check your email for the invitation link.", company.CompanyName, e.Role),
+		Data:      map[string]any{"invitation_id": e.InvitationID, "role": e.Role},
+	})
+}
+
+func (s *NotificationService) onMemberRoleChanged(ctx context.Context, meta EventMeta, e MemberRoleChanged) error {
+	user, err := s.queries.GetUserByID(ctx, e.UserID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	company, err := s.queries.GetCompanyByID(ctx, e.CompanyID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return s.notify(ctx, meta, user.ID, user.Email, user.Name, notification{
+		Type:      NotificationMemberRoleChanged,
+		CompanyID: e.CompanyID,
+		Title:     fmt.Sprintf("Your role in %s changed", company.CompanyName),
+		Body:      fmt.Sprintf("You are now %s in %s (previously %s).", e.Role, company.CompanyName, e.PreviousRole),
+		Data:      map[string]any{"role": e.Role, "previous_role": e.PreviousRole},
+	})
+}
+
+// onMemberRemoved notifies members removed by an admin; members who left
+// on their own need no notice.
+func (s *NotificationService) onMemberRemoved(ctx context.Context, meta EventMeta, e MemberRemoved) error {
+	if e.Reason != MemberRemovedReasonRemoved {
+		return nil
+	}
+	user, err := s.queries.GetUserByID(ctx, e.UserID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	company, err := s.queries.GetCompanyByID(ctx, e.CompanyID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return s.notify(ctx, meta, user.ID, user.Email, user.Name, notification{
+		Type:      NotificationMemberRemoved,
+		CompanyID: e.CompanyID,
+		Title:     fmt.Sprintf("You were removed from %s", company.CompanyName),
+		Body:      fmt.Sprintf("An admin of %s removed you from the company.", company.CompanyName),
+		Data:      map[string]any{"role": e.Role},
+	})
+}
+
+// onJoinRequested notifies every admin of the company.
+func (s *NotificationService) onJoinRequested(ctx context.Context, meta EventMeta, e JoinRequested) error {
+	requester, err := s.queries.GetUserByID(ctx, e.UserID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	company, err := s.queries.GetCompanyByID(ctx, e.CompanyID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	admins, err := s.queries.ListCompanyAdmins(ctx, e.CompanyID)
+	if err != nil {
+		return err
+	}
+
+	body := fmt.Sprintf("%s (%s) asked to join %s.", requester.Name, requester.Email, company.CompanyName)
+	if e.Message != "" {
+		body += fmt.Sprintf("\n\nTheir message:\n%s", e.Message)
+	}
+	n := notification{
+		Type:      NotificationJoinRequestReceived,
+		CompanyID: e.CompanyID,
+		Title:     fmt.Sprintf("New request to join %s", company.CompanyName),
+		Body:      body,
+		Data:      map[string]any{"request_id": e.RequestID, "user_id": e.UserID},
+	}
+	for _, admin := range admins {
+		if err := s.notify(ctx, meta, admin.ID, admin.Email, admin.Name, n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// onJoinRequestReviewed tells the requester whether they were let in.
+func (s *NotificationService) onJoinRequestReviewed(ctx context.Context, meta EventMeta, e JoinRequestReviewed) error {
+	requester, err := s.queries.GetUserByID(ctx, e.UserID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	company, err := s.queries.GetCompanyByID(ctx, e.CompanyID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	n := notification{
+		Type:      NotificationJoinRequestReviewed,
+		CompanyID: e.CompanyID,
+		Title:     fmt.Sprintf("Your request to join %s was declined", company.CompanyName),
+		Body:      fmt.Sprintf("An admin of %s declined your request to join.", company.CompanyName),
+		Data:      map[string]any{"request_id": e.RequestID, "status": e.Status},
+	}
+	if e.Status == JoinRequestStatusApproved {
+		n.Title = fmt.Sprintf("Your request to join %s was approved", company.CompanyName)
+		n.Body = fmt.Sprintf("You are now a member of %s as %s.", company.CompanyName, e.Role)
+		n.Data["role"] = e.Role
+	}
+	return s.notify(ctx, meta, requester.ID, requester.Email, requester.Name, n)
+}
+
+// notify delivers n to the user as their preferences ask. The notification
+// row also records emailed-only notifications, so a redelivered event is
+// neither shown nor emailed twice. Emails are best effort and not retried.
+func (s *NotificationService) notify(ctx context.Context, meta EventMeta, userID int32, email, name string, n notification) error {
+	pref, err := s.preference(ctx, userID, n.Type)
+	if err != nil {
+		return err
+	}
+	if !pref.InApp && !pref.Email {
+		return nil
+	}
+
+	data, err := json.Marshal(n.Data)
+	if err != nil {
+		return err
+	}
+	id, err := s.queries.CreateNotification(ctx, compiled.CreateNotificationParams{
+		UserID:        userID,
+		Type:          n.Type,
+		CompanyID:     pgtype.Int4{Int32: n.CompanyID, Valid: n.CompanyID != 0},
+		SourceEventID: meta.ID,
+		Title:         n.Title,
+		Body:          n.Body,
+		Data:          data,
+		InApp:         pref.InApp,
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if !pref.Email {
+		return nil
+	}
+	text := fmt.Sprintf("Hi %s,\n\n%s\n\nOpen %s to see your notifications.", name, n.Body, s.appURL)
+	if err := s.mailer.Send(ctx, email, n.Title, text); err != nil {
+		slog.ErrorContext(ctx, "Failed to email notification", "notification_id", id, "error", err)
+		return nil
+	}
+	return s.queries.MarkNotificationEmailed(ctx, id)
+}
+
+func (s *NotificationService) preference(ctx context.Context, userID int32, notificationType string) (NotificationPreference, error) {
+	prefs, err := s.Preferences(ctx, userID)
+	if err != nil {
+		return NotificationPreference{}, err
+	}
+	for _, pref := range prefs {
+		if pref.Type == notificationType {
+			return pref, nil
+		}
+	}
+	return NotificationPreference{}, fmt.Errorf("%w: %s", ErrNotificationTypeUnknown, notificationType)
+}
+
+func isNotificationType(notificationType string) bool {
+	return slices.ContainsFunc(DefaultNotificationPreferences, func(p NotificationPreference) bool {
+		return p.Type == notificationType
+	})
+}
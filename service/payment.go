@@ -0,0 +1,82 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var ErrPaymentFailed = errors.New("payment provider rejected the request")
+
+// PaymentProvider is the billing backend that owns customers and recurring
+// charges. Plan limits are stored locally; the provider only needs to know
+// which plan a customer pays for.
+type PaymentProvider interface {
+	CreateCustomer(ctx context.Context, companyID int32, email string) (string, error)
+	CreateSubscription(ctx context.Context, customerID, planCode string) (ProviderSubscription, error)
+	CancelSubscription(ctx context.Context, subscriptionID string) error
+}
+
+type ProviderSubscription struct {
+	ID               string
+	CurrentPeriodEnd time.Time
+}
+
+// FakePaymentProvider keeps customers and subscriptions in memory so the
+// billing flow can run offline. Set Decline to make every new subscription
+// fail as if the card was declined.
+type FakePaymentProvider struct {
+	mu            sync.Mutex
+	nextID        int
+	customers     map[string]int32
+	subscriptions map[string]string
+
+	Decline bool
+}
+
+func NewFakePaymentProvider() *FakePaymentProvider {
+	return &FakePaymentProvider{
+		customers:     make(map[string]int32),
+		subscriptions: make(map[string]string),
+	}
+}
+
+func (p *FakePaymentProvider) CreateCustomer(ctx context.Context, companyID int32, email string) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.nextID++
+	id := fmt.Sprintf("cus_fake_%d", p.nextID)
+	p.customers[id] = companyID
+	return id, nil
+}
+
+func (p *FakePaymentProvider) CreateSubscription(ctx context.Context, customerID, planCode string) (ProviderSubscription, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.Decline {
+		return ProviderSubscription{}, ErrPaymentFailed
+	}
+	if _, ok := p.customers[customerID]; !ok {
+		return ProviderSubscription{}, fmt.Errorf("unknown customer %q", customerID)
+	}
+
+	p.nextID++
+	id := fmt.Sprintf("sub_fake_%d", p.nextID)
+	p.subscriptions[id] = planCode
+	return ProviderSubscription{
+		ID:               id,
+		CurrentPeriodEnd: time.Now().AddDate(0, 1, 0),
+	}, nil
+}
+
+func (p *FakePaymentProvider) CancelSubscription(ctx context.Context, subscriptionID string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	delete(p.subscriptions, subscriptionID)
+	return nil
+}
@@ -0,0 +1,274 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"project/compiled"
+)
+
+const (
+	RealtimeMemberJoined      = "member.joined"
+	RealtimeMemberLeft        = "member.left"
+	RealtimeMemberRemoved     = "member.removed"
+	RealtimeMemberRoleChanged = "member.role_changed"
+	// RealtimeSessionRevoked tells a user's streams that a session of theirs
+	// ended. Watchers check whether their own session is still valid.
+	RealtimeSessionRevoked = "session.revoked"
+
+	realtimeChannel        = "realtime_events"
+	realtimeWatcherBuffer  = 64
+	realtimeReconnectDelay = 5 * time.Second
+)
+
+// RealtimeEvent is a change pushed to connected clients. ID is the outbox
+// entry it was published from.
+type RealtimeEvent struct {
+	ID         int64          `json:"id"`
+	Type       string         `json:"type"`
+	CompanyID  int32          `json:"company_id,omitempty"`
+	UserID     int32          `json:"user_id"`
+	OccurredAt time.Time      `json:"occurred_at"`
+	Data       map[string]any `json:"data,omitempty"`
+}
+
+type realtimeWatcher struct {
+	userID int32
+	events chan RealtimeEvent
+}
+
+// RealtimeHub pushes membership and session changes to clients watching
+// them. Events from the bus are broadcast with Postgres NOTIFY, so every
+// server instance delivers them to its own watchers regardless of which
+// instance dispatched the outbox entry.
+//
+// Delivery is best effort: a watcher that falls behind, or that is connected
+// when the listener loses its connection, has its channel closed and should
+// reconnect and reload its state.
+type RealtimeHub struct {
+	pool     *pgxpool.Pool
+	queries  *compiled.Queries
+	mu       sync.Mutex
+	watchers map[*realtimeWatcher]struct{}
+	// stopped is set once Run returns; later watchers are closed at once
+	stopped bool
+	// revokeHooks run on every instance for each session revocation, even
+	// when nobody is watching
+	revokeHooks []func(userID int32)
+}
+
+func NewRealtimeHub(pool *pgxpool.Pool, queries *compiled.Queries) *RealtimeHub {
+	return &RealtimeHub{
+		pool:     pool,
+		queries:  queries,
+		watchers: make(map[*realtimeWatcher]struct{}),
+	}
+}
+
+// Watch returns the events visible to userID: membership changes in their
+// current companies and their own memberships, and their session
+// revocations. The channel is closed when the watcher is dropped; call stop
+// when done watching.
+func (h *RealtimeHub) Watch(userID int32) (events <-chan RealtimeEvent, stop func()) {
+	w := &realtimeWatcher{userID: userID, events: make(chan RealtimeEvent, realtimeWatcherBuffer)}
+
+	h.mu.Lock()
+	if h.stopped {
+		close(w.events)
+	} else {
+		h.watchers[w] = struct{}{}
+	}
+	h.mu.Unlock()
+
+	return w.events, func() { h.drop(w) }
+}
+
+// OnSessionRevoked registers fn to be called with the user whose session
+// ended, on every server instance. Callers use it to drop cached sessions.
+func (h *RealtimeHub) OnSessionRevoked(fn func(userID int32)) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.revokeHooks = append(h.revokeHooks, fn)
+}
+
+// Subscribe publishes membership and session events from the bus.
+func (h *RealtimeHub) Subscribe(bus *EventBus) {
+	Subscribe(bus, "realtime", func(ctx context.Context, meta EventMeta, e MemberJoined) error {
+		return h.publish(ctx, meta, RealtimeEvent{
+			Type:      RealtimeMemberJoined,
+			CompanyID: e.CompanyID,
+			UserID:    e.UserID,
+			Data:      map[string]any{"role": e.Role, "source": e.Source},
+		})
+	})
+	Subscribe(bus, "realtime", func(ctx context.Context, meta EventMeta, e MemberRemoved) error {
+		eventType := RealtimeMemberLeft
+		if e.Reason == MemberRemovedReasonRemoved {
+			eventType = RealtimeMemberRemoved
+		}
+		return h.publish(ctx, meta, RealtimeEvent{
+			Type:      eventType,
+			CompanyID: e.CompanyID,
+			UserID:    e.UserID,
+			Data:      map[string]any{"role": e.Role, "reason": e.Reason},
+		})
+	})
+	Subscribe(bus, "realtime", func(ctx context.Context, meta EventMeta, e MemberRoleChanged) error {
+		return h.publish(ctx, meta, RealtimeEvent{
+			Type:      RealtimeMemberRoleChanged,
+			CompanyID: e.CompanyID,
+			UserID:    e.UserID,
+			Data:      map[string]any{"role": e.Role, "previous_role": e.PreviousRole},
+		})
+	})
+	// Signing in replaces the user's token, ending their other sessions
+	Subscribe(bus, "realtime", func(ctx context.Context, meta EventMeta, e UserLoggedIn) error {
+		return h.publish(ctx, meta, RealtimeEvent{
+			Type:   RealtimeSessionRevoked,
+			UserID: e.UserID,
+			Data:   map[string]any{"reason": "signed_in_elsewhere"},
+		})
+	})
+	Subscribe(bus, "realtime", func(ctx context.Context, meta EventMeta, e AccountDeleted) error {
+		return h.publish(ctx, meta, RealtimeEvent{
+			Type:   RealtimeSessionRevoked,
+			UserID: e.UserID,
+			Data:   map[string]any{"reason": "account_deleted"},
+		})
+	})
+}
+
+func (h *RealtimeHub) publish(ctx context.Context, meta EventMeta, event RealtimeEvent) error {
+	event.ID = meta.ID
+	event.OccurredAt = meta.OccurredAt.UTC()
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return h.queries.NotifyRealtimeEvent(ctx, string(payload))
+}
+
+// Run listens for published events and fans them out to local watchers
+// until ctx is cancelled. It then closes every watcher, so open streams end
+// and do not hold up shutdown.
+func (h *RealtimeHub) Run(ctx context.Context) {
+	defer h.stop()
+	for {
+		err := h.listen(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		slog.WarnContext(ctx, "Realtime listener stopped, reconnecting", "error", err)
+		// Events published while reconnecting are lost, so watchers start over
+		h.dropAll()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(realtimeReconnectDelay):
+		}
+	}
+}
+
+func (h *RealtimeHub) listen(ctx context.Context) error {
+	conn, err := h.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	// The connection stays in LISTEN mode, so it must not go back to the pool
+	pgConn := conn.Hijack()
+	defer pgConn.Close(context.Background())
+
+	if _, err := pgConn.Exec(ctx, "LISTEN "+realtimeChannel); err != nil {
+		return err
+	}
+
+	for {
+		n, err := pgConn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+
+		var event RealtimeEvent
+		if err := json.Unmarshal([]byte(n.Payload), &event); err != nil {
+			slog.ErrorContext(ctx, "Dropping malformed realtime event", "error", err)
+			continue
+		}
+		h.dispatch(ctx, event)
+	}
+}
+
+// dispatch delivers event to the watchers allowed to see it. Company events
+// go to the company's current members and to the member concerned, who may
+// just have left.
+func (h *RealtimeHub) dispatch(ctx context.Context, event RealtimeEvent) {
+	h.mu.Lock()
+	watching := len(h.watchers) > 0
+	var hooks []func(int32)
+	if event.Type == RealtimeSessionRevoked {
+		hooks = slices.Clone(h.revokeHooks)
+	}
+	h.mu.Unlock()
+	for _, fn := range hooks {
+		fn(event.UserID)
+	}
+	if !watching {
+		return
+	}
+
+	recipients := []int32{event.UserID}
+	if event.CompanyID != 0 {
+		members, err := h.queries.ListCompanyMemberIDs(ctx, event.CompanyID)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to load members for realtime event", "event_id", event.ID, "error", err)
+			return
+		}
+		recipients = append(recipients, members...)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for w := range h.watchers {
+		if !slices.Contains(recipients, w.userID) {
+			continue
+		}
+		select {
+		case w.events <- event:
+		default:
+			// Too far behind; the client reconnects and reloads
+			delete(h.watchers, w)
+			close(w.events)
+		}
+	}
+}
+
+func (h *RealtimeHub) drop(w *realtimeWatcher) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.watchers[w]; ok {
+		delete(h.watchers, w)
+		close(w.events)
+	}
+}
+
+func (h *RealtimeHub) stop() {
+	h.mu.Lock()
+	h.stopped = true
+	h.mu.Unlock()
+	h.dropAll()
+}
+
+func (h *RealtimeHub) dropAll() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for w := range h.watchers {
+		delete(h.watchers, w)
+		close(w.events)
+	}
+}
@@ -0,0 +1,36 @@
+package service
+
+import (
+	"context"
+	"testing"
+)
+
+func TestRealtimeHubStopClosesWatchers(t *testing.T) {
+	h := NewRealtimeHub(nil, nil)
+	events, stop := h.Watch(1)
+	defer stop()
+
+	h.stop()
+	if _, ok := <-events; ok {
+		t.Fatal("watcher still open after the hub stopped")
+	}
+
+	// Streams opened during shutdown end straight away
+	late, stopLate := h.Watch(2)
+	defer stopLate()
+	if _, ok := <-late; ok {
+		t.Fatal("watcher opened after the hub stopped is not closed")
+	}
+}
+
+func TestRealtimeHubRunsRevokeHooksWithoutWatchers(t *testing.T) {
+	h := NewRealtimeHub(nil, nil)
+	var revoked []int32
+	h.OnSessionRevoked(func(userID int32) { revoked = append(revoked, userID) })
+
+	h.dispatch(context.Background(), RealtimeEvent{Type: RealtimeMemberJoined, CompanyID: 1, UserID: 7})
+	h.dispatch(context.Background(), RealtimeEvent{Type: RealtimeSessionRevoked, UserID: 7})
+	if len(revoked) != 1 || revoked[0] != 7 {
+		t.Fatalf("revoked = %v, want [7]", revoked)
+	}
+}
@@ -0,0 +1,105 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-migrate/migrate/v4"
+	_ "github.com/golang-migrate/migrate/v4/database/postgres"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"project/compiled"
+	"project/database/migration"
+)
+
+// Tests that need Postgres run against TEST_DATABASE_URL and are skipped
+// without it. The database is migrated once per run; every test creates its
+// own users and companies, so tests can share it and run in parallel.
+var (
+	migrateOnce sync.Once
+	migrateErr  error
+)
+
+func testDB(t *testing.T) (*pgxpool.Pool, *compiled.Queries) {
+	t.Helper()
+
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	migrateOnce.Do(func() {
+		src, err := iofs.New(migration.FS, "sql")
+		if err != nil {
+			migrateErr = err
+			return
+		}
+		m, err := migrate.NewWithSourceInstance("iofs", src, url)
+		if err != nil {
+			migrateErr = err
+			return
+		}
+		if err := m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+			migrateErr = err
+		}
+	})
+	if migrateErr != nil {
+		t.Fatalf("migrate test database: %v", migrateErr)
+	}
+
+	pool, err := pgxpool.New(context.Background(), url)
+	if err != nil {
+		t.Fatalf("open test database: %v", err)
+	}
+	t.Cleanup(pool.Close)
+	return pool, compiled.New(pool)
+}
+
+// newTestCompanyService returns a CompanyService with a mailer that drops
+// every message and no DNS resolver.
+func newTestCompanyService(pool *pgxpool.Pool, queries *compiled.Queries, payments PaymentProvider) *CompanyService {
+	return NewCompanyService(pool, queries, noopMailer{}, payments, nil, "http://app.test", 7*24*time.Hour, 30*24*time.Hour)
+}
+
+// newTestUser creates a user with a unique email below domain.
+func newTestUser(t *testing.T, queries *compiled.Queries, domain string) compiled.CreateUserRow {
+	t.Helper()
+
+	user, err := queries.CreateUser(context.Background(), compiled.CreateUserParams{
+		Email: "user-" + generateToken(12) + "@" + domain,
+		Name:  "Test User",
+	})
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	return user
+}
+
+// newTestCompany creates a company owned and administered by ownerID.
+func newTestCompany(t *testing.T, s *CompanyService, ownerID int32) int32 {
+	t.Helper()
+
+	company, err := s.CreateCompany(context.Background(), ownerID, "Company "+generateToken(8))
+	if err != nil {
+		t.Fatalf("create company: %v", err)
+	}
+	return company.ID
+}
+
+// addTestMember adds userID to the company with role, bypassing invitations.
+func addTestMember(t *testing.T, queries *compiled.Queries, companyID, userID int32, role string) {
+	t.Helper()
+
+	if _, err := queries.AddUserToCompany(context.Background(), compiled.AddUserToCompanyParams{
+		CompanyID: companyID,
+		UserID:    userID,
+		Role:      role,
+	}); err != nil {
+		t.Fatalf("add member: %v", err)
+	}
+}
@@ -0,0 +1,25 @@
+package service
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"project/compiled"
+)
+
+// runInTx executes fn with queries bound to a single transaction, committing
+// only when fn returns nil.
+func runInTx(ctx context.Context, pool *pgxpool.Pool, queries *compiled.Queries, fn func(q *compiled.Queries) error) error {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := fn(queries.WithTx(tx)); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
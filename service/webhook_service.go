@@ -0,0 +1,501 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"project/compiled"
+)
+
+const (
+	WebhookEventMemberJoined      = "member.joined"
+	WebhookEventMemberLeft        = "member.left"
+	WebhookEventMemberRemoved     = "member.removed"
+	WebhookEventMemberRoleChanged = "member.role_changed"
+	WebhookEventTest              = "webhook.test"
+
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliverySucceeded = "succeeded"
+	WebhookDeliveryFailed    = "failed"
+
+	// Receivers verify X-Lavorus-Signature, formatted "t=<unix>,v1=<hex>",
+	// as HMAC-SHA256 of "<unix>.<body>" keyed with the endpoint secret.
+	WebhookSignatureHeader = "X-Lavorus-Signature"
+	WebhookEventHeader     = "X-Lavorus-Event"
+	WebhookDeliveryHeader  = "X-Lavorus-Delivery"
+
+	webhookPollInterval   = 5 * time.Second
+	webhookBatchSize      = 20
+	webhookMaxAttempts    = 10
+	webhookBaseBackoff    = 30 * time.Second
+	webhookMaxBackoff     = 6 * time.Hour
+	webhookRequestTimeout = 10 * time.Second
+	webhookDeliveryLimit  = 100
+	// Payloads carry member emails, so the log is kept no longer than the
+	// default anonymization grace period
+	webhookLogRetention = 30 * 24 * time.Hour
+)
+
+// WebhookEventTypes are the event types endpoints can subscribe to.
+var WebhookEventTypes = []string{
+	WebhookEventMemberJoined,
+	WebhookEventMemberLeft,
+	WebhookEventMemberRemoved,
+	WebhookEventMemberRoleChanged,
+}
+
+var (
+	ErrWebhookNotFound     = errors.New("webhook not found")
+	ErrWebhookURLInvalid   = errors.New("webhook url must be an absolute http or https url")
+	ErrWebhookURLNotPublic = errors.New("webhook url must point to a public address")
+	ErrWebhookEventUnknown = errors.New("unknown webhook event type")
+)
+
+// WebhookEvent is the JSON body posted to endpoints.
+type WebhookEvent struct {
+	ID        string    `json:"id"`
+	Type      string    `json:"type"`
+	CompanyID int32     `json:"company_id"`
+	CreatedAt time.Time `json:"created_at"`
+	Data      any       `json:"data"`
+}
+
+// MemberEvent is the data of member.* events. Source says how a member
+// joined or left; ActorID is the admin who acted, when it was not the member.
+type MemberEvent struct {
+	UserID       int32  `json:"user_id"`
+	Email        string `json:"email"`
+	Name         string `json:"name"`
+	Role         string `json:"role"`
+	PreviousRole string `json:"previous_role,omitempty"`
+	Source       string `json:"source,omitempty"`
+	ActorID      int32  `json:"actor_id,omitempty"`
+}
+
+// WebhookService manages company webhook endpoints and delivers queued
+// events with retries. Endpoints are customer-supplied, so deliveries only
+// connect to public addresses and do not follow redirects.
+type WebhookService struct {
+	pool      *pgxpool.Pool
+	queries   *compiled.Queries
+	companies *CompanyService
+	client    *http.Client
+}
+
+func NewWebhookService(pool *pgxpool.Pool, queries *compiled.Queries, companies *CompanyService) *WebhookService {
+	return &WebhookService{
+		pool:      pool,
+		queries:   queries,
+		companies: companies,
+		client:    publicHTTPClient(webhookRequestTimeout),
+	}
+}
+
+// CreateWebhook registers an endpoint for the company. An empty eventTypes
+// subscribes to every event type. The returned endpoint carries the signing
+// secret.
+func (s *WebhookService) CreateWebhook(ctx context.Context, adminID, companyID int32, endpointURL string, eventTypes []string) (*compiled.WebhookEndpoint, error) {
+	if err := s.companies.requireAdmin(ctx, companyID, adminID); err != nil {
+		return nil, err
+	}
+	if _, err := s.companies.getWritableCompany(ctx, companyID); err != nil {
+		return nil, err
+	}
+	if err := validateWebhook(endpointURL, eventTypes); err != nil {
+		return nil, err
+	}
+
+	endpoint, err := s.queries.CreateWebhookEndpoint(ctx, compiled.CreateWebhookEndpointParams{
+		CompanyID:  companyID,
+		Url:        endpointURL,
+		Secret:     "whsec_" + generateToken(48),
+		EventTypes: normalizeEventTypes(eventTypes),
+		CreatedBy:  pgtype.Int4{Int32: adminID, Valid: true},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &endpoint, nil
+}
+
+func (s *WebhookService) ListWebhooks(ctx context.Context, adminID, companyID int32) ([]compiled.WebhookEndpoint, error) {
+	if err := s.companies.requireAdmin(ctx, companyID, adminID); err != nil {
+		return nil, err
+	}
+	return s.queries.ListWebhookEndpoints(ctx, companyID)
+}
+
+// UpdateWebhook replaces the endpoint's URL, subscriptions and enabled flag.
+// Disabling an endpoint fails its pending deliveries.
+func (s *WebhookService) UpdateWebhook(ctx context.Context, adminID, companyID, webhookID int32, endpointURL string, eventTypes []string, enabled bool) (*compiled.WebhookEndpoint, error) {
+	if err := s.companies.requireAdmin(ctx, companyID, adminID); err != nil {
+		return nil, err
+	}
+	if _, err := s.companies.getWritableCompany(ctx, companyID); err != nil {
+		return nil, err
+	}
+	if err := validateWebhook(endpointURL, eventTypes); err != nil {
+		return nil, err
+	}
+
+	var endpoint compiled.WebhookEndpoint
+	err := runInTx(ctx, s.pool, s.queries, func(q *compiled.Queries) error {
+		var err error
+		endpoint, err = q.UpdateWebhookEndpoint(ctx, compiled.UpdateWebhookEndpointParams{
+			ID:         webhookID,
+			CompanyID:  companyID,
+			Url:        endpointURL,
+			EventTypes: normalizeEventTypes(eventTypes),
+			Enabled:    enabled,
+		})
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrWebhookNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		if enabled {
+			return nil
+		}
+		return q.FailPendingWebhookDeliveries(ctx, compiled.FailPendingWebhookDeliveriesParams{
+			EndpointID: webhookID,
+			Reason:     "endpoint disabled",
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &endpoint, nil
+}
+
+// DeleteWebhook removes the endpoint together with its delivery log.
+func (s *WebhookService) DeleteWebhook(ctx context.Context, adminID, companyID, webhookID int32) error {
+	if err := s.companies.requireAdmin(ctx, companyID, adminID); err != nil {
+		return err
+	}
+	if _, err := s.companies.getWritableCompany(ctx, companyID); err != nil {
+		return err
+	}
+
+	rows, err := s.queries.DeleteWebhookEndpoint(ctx, compiled.DeleteWebhookEndpointParams{
+		ID:        webhookID,
+		CompanyID: companyID,
+	})
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrWebhookNotFound
+	}
+	return nil
+}
+
+// ListDeliveries returns the most recent deliveries to the endpoint.
+func (s *WebhookService) ListDeliveries(ctx context.Context, adminID, companyID, webhookID int32) ([]compiled.WebhookDelivery, error) {
+	if err := s.companies.requireAdmin(ctx, companyID, adminID); err != nil {
+		return nil, err
+	}
+	if _, err := s.getWebhook(ctx, companyID, webhookID); err != nil {
+		return nil, err
+	}
+
+	return s.queries.ListWebhookDeliveries(ctx, compiled.ListWebhookDeliveriesParams{
+		EndpointID: webhookID,
+		Limit:      webhookDeliveryLimit,
+	})
+}
+
+// SendTestEvent queues a webhook.test event for the endpoint and makes the
+// first attempt right away, so the caller sees the receiver's response.
+// Failed attempts are retried like any other delivery. The delivery is
+// created already leased, so the worker leaves it alone during the attempt.
+func (s *WebhookService) SendTestEvent(ctx context.Context, adminID, companyID, webhookID int32) (*compiled.WebhookDelivery, error) {
+	if err := s.companies.requireAdmin(ctx, companyID, adminID); err != nil {
+		return nil, err
+	}
+	if _, err := s.companies.getWritableCompany(ctx, companyID); err != nil {
+		return nil, err
+	}
+	endpoint, err := s.getWebhook(ctx, companyID, webhookID)
+	if err != nil {
+		return nil, err
+	}
+
+	event := newWebhookEvent(companyID, WebhookEventTest, map[string]int32{"webhook_id": webhookID})
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return nil, err
+	}
+
+	delivery, err := s.queries.CreateWebhookDelivery(ctx, compiled.CreateWebhookDeliveryParams{
+		EndpointID: endpoint.ID,
+		EventID:    event.ID,
+		EventType:  event.Type,
+		Payload:    payload,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.deliver(ctx, compiled.ClaimWebhookDeliveriesRow{
+		ID:        delivery.ID,
+		EventID:   delivery.EventID,
+		EventType: delivery.EventType,
+		Payload:   delivery.Payload,
+		Attempts:  delivery.Attempts,
+		Url:       endpoint.Url,
+		Secret:    endpoint.Secret,
+	})
+}
+
+// Run delivers due webhooks until ctx is cancelled.
+func (s *WebhookService) Run(ctx context.Context) {
+	ticker := time.NewTicker(webhookPollInterval)
+	defer ticker.Stop()
+
+	var lastPurge time.Time
+	for {
+		s.deliverDue(ctx)
+		if time.Since(lastPurge) >= time.Hour {
+			lastPurge = time.Now()
+			if err := s.queries.PurgeWebhookDeliveries(ctx, pgtype.Interval{Microseconds: webhookLogRetention.Microseconds(), Valid: true}); err != nil && ctx.Err() == nil {
+				slog.ErrorContext(ctx, "Failed to purge webhook deliveries", "error", err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *WebhookService) deliverDue(ctx context.Context) {
+	for ctx.Err() == nil {
+		batch, err := s.queries.ClaimWebhookDeliveries(ctx, webhookBatchSize)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to claim webhook deliveries", "error", err)
+			return
+		}
+		if len(batch) == 0 {
+			return
+		}
+
+		// A slow receiver only holds up its own delivery
+		var wg sync.WaitGroup
+		for _, d := range batch {
+			wg.Go(func() {
+				if _, err := s.deliver(ctx, d); err != nil {
+					slog.ErrorContext(ctx, "Failed to record webhook delivery", "delivery_id", d.ID, "error", err)
+				}
+			})
+		}
+		wg.Wait()
+	}
+}
+
+// deliver makes one attempt and records its outcome, scheduling a retry with
+// exponential backoff until the attempts run out.
+func (s *WebhookService) deliver(ctx context.Context, d compiled.ClaimWebhookDeliveriesRow) (*compiled.WebhookDelivery, error) {
+	responseStatus, err := s.post(ctx, d)
+
+	params := compiled.RecordWebhookAttemptParams{
+		Status:         WebhookDeliverySucceeded,
+		ResponseStatus: pgtype.Int4{Int32: int32(responseStatus), Valid: responseStatus != 0},
+		ID:             d.ID,
+		RetryAfter:     pgtype.Interval{Valid: true},
+	}
+	if err != nil {
+		// The log is shown to admins; do not reveal what a name resolved to
+		if errors.Is(err, ErrDestinationNotPublic) {
+			err = ErrDestinationNotPublic
+		}
+		params.LastError = pgtype.Text{String: err.Error(), Valid: true}
+		attempts := d.Attempts + 1
+		if attempts >= webhookMaxAttempts {
+			params.Status = WebhookDeliveryFailed
+		} else {
+			params.Status = WebhookDeliveryPending
+			params.RetryAfter.Microseconds = webhookBackoff(attempts).Microseconds()
+		}
+	}
+
+	delivery, err := s.queries.RecordWebhookAttempt(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+// post sends the signed payload and returns the response status code, or 0
+// when no response was received.
+func (s *WebhookService) post(ctx context.Context, d compiled.ClaimWebhookDeliveriesRow) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.Url, bytes.NewReader(d.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Lavorus-Webhooks/1.0")
+	req.Header.Set(WebhookEventHeader, d.EventType)
+	req.Header.Set(WebhookDeliveryHeader, d.EventID)
+	req.Header.Set(WebhookSignatureHeader, SignWebhookPayload(d.Secret, time.Now(), d.Payload))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("receiver responded with status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+func (s *WebhookService) getWebhook(ctx context.Context, companyID, webhookID int32) (compiled.WebhookEndpoint, error) {
+	endpoint, err := s.queries.GetWebhookEndpoint(ctx, compiled.GetWebhookEndpointParams{
+		ID:        webhookID,
+		CompanyID: companyID,
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return endpoint, ErrWebhookNotFound
+	}
+	return endpoint, err
+}
+
+// SignWebhookPayload returns the X-Lavorus-Signature header value for body.
+func SignWebhookPayload(secret string, timestamp time.Time, body []byte) string {
+	ts := strconv.FormatInt(timestamp.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "t=" + ts + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Subscribe delivers membership changes recorded on the event bus as
+// member.* webhooks. The event ID is derived from the outbox entry, so a
+// redelivered change reaches receivers with the same X-Lavorus-Delivery
+// value.
+func (s *WebhookService) Subscribe(bus *EventBus) {
+	Subscribe(bus, "webhooks", func(ctx context.Context, meta EventMeta, e MemberJoined) error {
+		return s.enqueueMemberEvent(ctx, meta, e.CompanyID, WebhookEventMemberJoined, MemberEvent{
+			UserID:  e.UserID,
+			Email:   e.Email,
+			Name:    e.Name,
+			Role:    e.Role,
+			Source:  e.Source,
+			ActorID: e.AddedBy,
+		})
+	})
+	Subscribe(bus, "webhooks", func(ctx context.Context, meta EventMeta, e MemberRoleChanged) error {
+		return s.enqueueMemberEvent(ctx, meta, e.CompanyID, WebhookEventMemberRoleChanged, MemberEvent{
+			UserID:       e.UserID,
+			Email:        e.Email,
+			Name:         e.Name,
+			Role:         e.Role,
+			PreviousRole: e.PreviousRole,
+			ActorID:      e.ChangedBy,
+		})
+	})
+	Subscribe(bus, "webhooks", func(ctx context.Context, meta EventMeta, e MemberRemoved) error {
+		data := MemberEvent{UserID: e.UserID, Email: e.Email, Name: e.Name, Role: e.Role}
+		eventType := WebhookEventMemberLeft
+		switch e.Reason {
+		case MemberRemovedReasonRemoved:
+			eventType = WebhookEventMemberRemoved
+			data.ActorID = e.RemovedBy
+		case MemberRemovedReasonAccountDeleted:
+			data.Source = e.Reason
+		}
+		return s.enqueueMemberEvent(ctx, meta, e.CompanyID, eventType, data)
+	})
+}
+
+func (s *WebhookService) enqueueMemberEvent(ctx context.Context, meta EventMeta, companyID int32, eventType string, data MemberEvent) error {
+	return enqueueWebhookEvent(ctx, s.queries, WebhookEvent{
+		ID:        fmt.Sprintf("evt_outbox_%d", meta.ID),
+		Type:      eventType,
+		CompanyID: companyID,
+		CreatedAt: meta.OccurredAt.UTC(),
+		Data:      data,
+	})
+}
+
+// enqueueWebhookEvent queues the event for every subscribed endpoint of the
+// company.
+func enqueueWebhookEvent(ctx context.Context, q *compiled.Queries, event WebhookEvent) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	_, err = q.EnqueueWebhookEvent(ctx, compiled.EnqueueWebhookEventParams{
+		EventID:   event.ID,
+		EventType: event.Type,
+		Payload:   payload,
+		CompanyID: event.CompanyID,
+	})
+	return err
+}
+
+func newWebhookEvent(companyID int32, eventType string, data any) WebhookEvent {
+	return WebhookEvent{
+		ID:        "evt_" + generateToken(24),
+		Type:      eventType,
+		CompanyID: companyID,
+		CreatedAt: time.Now().UTC(),
+		Data:      data,
+	}
+}
+
+// webhookBackoff is the wait after the given number of failed attempts.
+func webhookBackoff(attempts int32) time.Duration {
+	return min(webhookBaseBackoff<<(attempts-1), webhookMaxBackoff)
+}
+
+func validateWebhook(endpointURL string, eventTypes []string) error {
+	u, err := url.Parse(endpointURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ErrWebhookURLInvalid
+	}
+	// Deliveries only connect to public addresses; this catches the obvious
+	// cases up front
+	if checkPublicHost(u.Hostname()) != nil {
+		return ErrWebhookURLNotPublic
+	}
+	for _, t := range eventTypes {
+		if !slices.Contains(WebhookEventTypes, t) {
+			return fmt.Errorf("%w: %s", ErrWebhookEventUnknown, t)
+		}
+	}
+	return nil
+}
+
+func normalizeEventTypes(eventTypes []string) []string {
+	if len(eventTypes) == 0 {
+		return []string{}
+	}
+	return slices.Compact(slices.Sorted(slices.Values(eventTypes)))
+}
@@ -0,0 +1,235 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"project/compiled"
+)
+
+// newTestReceiver starts a local webhook receiver that answers with the
+// given status codes in turn, repeating the last one, and checks every
+// request's signature against secret.
+func newTestReceiver(t *testing.T, secret string, statuses ...int) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(calls.Add(1))
+		body, _ := io.ReadAll(r.Body)
+
+		sig := r.Header.Get(WebhookSignatureHeader)
+		ts, _, ok := strings.Cut(strings.TrimPrefix(sig, "t="), ",")
+		unix, err := strconv.ParseInt(ts, 10, 64)
+		if !ok || err != nil {
+			t.Errorf("malformed signature header %q", sig)
+		} else if want := SignWebhookPayload(secret, time.Unix(unix, 0), body); !hmac.Equal([]byte(sig), []byte(want)) {
+			t.Errorf("signature = %q, want %q", sig, want)
+		}
+		if r.Header.Get(WebhookEventHeader) == "" || r.Header.Get(WebhookDeliveryHeader) == "" {
+			t.Errorf("missing event or delivery header: %v", r.Header)
+		}
+
+		w.WriteHeader(statuses[min(n, len(statuses))-1])
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &calls
+}
+
+func TestWebhookPostSignsPayload(t *testing.T) {
+	srv, calls := newTestReceiver(t, "whsec_test", http.StatusNoContent, http.StatusInternalServerError)
+	s := &WebhookService{client: srv.Client()}
+	d := compiled.ClaimWebhookDeliveriesRow{
+		EventID:   "evt_1",
+		EventType: WebhookEventTest,
+		Payload:   []byte(`{"id":"evt_1"}`),
+		Url:       srv.URL,
+		Secret:    "whsec_test",
+	}
+
+	if code, err := s.post(context.Background(), d); err != nil || code != http.StatusNoContent {
+		t.Fatalf("post = %d, %v; want 204", code, err)
+	}
+	if code, err := s.post(context.Background(), d); err == nil || code != http.StatusInternalServerError {
+		t.Fatalf("post = %d, %v; want 500 with an error", code, err)
+	}
+	if calls.Load() != 2 {
+		t.Errorf("receiver got %d calls, want 2", calls.Load())
+	}
+}
+
+func TestWebhookBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int32
+		want     time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{10, 4*time.Hour + 16*time.Minute},
+		{20, webhookMaxBackoff},
+	}
+	for _, tt := range tests {
+		if got := webhookBackoff(tt.attempts); got != tt.want {
+			t.Errorf("webhookBackoff(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestValidateWebhookRejectsInternalURLs(t *testing.T) {
+	for _, u := range []string{"http://localhost:8080/hook", "http://127.0.0.1/hook", "http://169.254.169.254/latest/meta-data", "http://[::1]/hook"} {
+		if err := validateWebhook(u, nil); !errors.Is(err, ErrWebhookURLNotPublic) {
+			t.Errorf("validateWebhook(%q) = %v, want ErrWebhookURLNotPublic", u, err)
+		}
+	}
+	if err := validateWebhook("https://hooks.example.com/lavorus", nil); err != nil {
+		t.Errorf("validateWebhook(public) = %v", err)
+	}
+}
+
+func TestSendTestEventRetriesWithBackoff(t *testing.T) {
+	pool, queries := testDB(t)
+	companies := newTestCompanyService(pool, queries, nil)
+	ctx := context.Background()
+
+	owner := newTestUser(t, queries, "example.com")
+	companyID := newTestCompany(t, companies, owner.ID)
+
+	srv, calls := newTestReceiver(t, "whsec_test", http.StatusInternalServerError, http.StatusOK)
+	s := NewWebhookService(pool, queries, companies)
+	// The receiver runs on loopback, which the production client refuses
+	s.client = srv.Client()
+
+	// Insert directly; validateWebhook refuses loopback URLs
+	endpoint, err := queries.CreateWebhookEndpoint(ctx, compiled.CreateWebhookEndpointParams{
+		CompanyID:  companyID,
+		Url:        srv.URL,
+		Secret:     "whsec_test",
+		EventTypes: []string{},
+		CreatedBy:  pgtype.Int4{Int32: owner.ID, Valid: true},
+	})
+	if err != nil {
+		t.Fatalf("create endpoint: %v", err)
+	}
+
+	delivery, err := s.SendTestEvent(ctx, owner.ID, companyID, endpoint.ID)
+	if err != nil {
+		t.Fatalf("SendTestEvent: %v", err)
+	}
+	if delivery.Status != WebhookDeliveryPending || delivery.Attempts != 1 || delivery.ResponseStatus.Int32 != http.StatusInternalServerError {
+		t.Fatalf("delivery after failed attempt = %+v", delivery)
+	}
+	if wait := delivery.NextAttemptAt.Time.Sub(delivery.LastAttemptAt.Time); wait < webhookBackoff(1)-time.Second || wait > webhookBackoff(1)+time.Second {
+		t.Errorf("retry scheduled after %v, want %v", wait, webhookBackoff(1))
+	}
+
+	// The worker would make the retry once it is due
+	retried, err := s.deliver(ctx, compiled.ClaimWebhookDeliveriesRow{
+		ID:        delivery.ID,
+		EventID:   delivery.EventID,
+		EventType: delivery.EventType,
+		Payload:   delivery.Payload,
+		Attempts:  delivery.Attempts,
+		Url:       endpoint.Url,
+		Secret:    endpoint.Secret,
+	})
+	if err != nil {
+		t.Fatalf("deliver: %v", err)
+	}
+	if retried.Status != WebhookDeliverySucceeded || retried.Attempts != 2 || !retried.CompletedAt.Valid {
+		t.Fatalf("delivery after retry = %+v", retried)
+	}
+	if calls.Load() != 2 {
+		t.Errorf("receiver got %d calls, want 2", calls.Load())
+	}
+}
+
+func TestSendTestEventIsNotClaimedByWorker(t *testing.T) {
+	pool, queries := testDB(t)
+	companies := newTestCompanyService(pool, queries, nil)
+	ctx := context.Background()
+
+	owner := newTestUser(t, queries, "example.com")
+	companyID := newTestCompany(t, companies, owner.ID)
+
+	s := NewWebhookService(pool, queries, companies)
+	var claimed []int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Run the worker's claim while the synchronous attempt is in flight
+		rows, err := queries.ClaimWebhookDeliveries(ctx, 1000)
+		if err != nil {
+			t.Errorf("ClaimWebhookDeliveries: %v", err)
+		}
+		for _, row := range rows {
+			claimed = append(claimed, row.ID)
+		}
+	}))
+	defer srv.Close()
+	s.client = srv.Client()
+
+	endpoint, err := queries.CreateWebhookEndpoint(ctx, compiled.CreateWebhookEndpointParams{
+		CompanyID:  companyID,
+		Url:        srv.URL,
+		Secret:     "whsec_test",
+		EventTypes: []string{},
+		CreatedBy:  pgtype.Int4{Int32: owner.ID, Valid: true},
+	})
+	if err != nil {
+		t.Fatalf("create endpoint: %v", err)
+	}
+
+	delivery, err := s.SendTestEvent(ctx, owner.ID, companyID, endpoint.ID)
+	if err != nil {
+		t.Fatalf("SendTestEvent: %v", err)
+	}
+	for _, id := range claimed {
+		if id == delivery.ID {
+			t.Fatal("worker claimed the test delivery during its synchronous attempt")
+		}
+	}
+	if delivery.Attempts != 1 {
+		t.Errorf("attempts = %d, want 1", delivery.Attempts)
+	}
+}
+
+func TestArchivedCompanyWebhooksAreReadOnly(t *testing.T) {
+	pool, queries := testDB(t)
+	companies := newTestCompanyService(pool, queries, nil)
+	ctx := context.Background()
+
+	owner := newTestUser(t, queries, "example.com")
+	companyID := newTestCompany(t, companies, owner.ID)
+	s := NewWebhookService(pool, queries, companies)
+
+	endpoint, err := queries.CreateWebhookEndpoint(ctx, compiled.CreateWebhookEndpointParams{
+		CompanyID:  companyID,
+		Url:        "https://hooks.example.com/lavorus",
+		Secret:     "whsec_test",
+		EventTypes: []string{},
+		CreatedBy:  pgtype.Int4{Int32: owner.ID, Valid: true},
+	})
+	if err != nil {
+		t.Fatalf("create endpoint: %v", err)
+	}
+	if err := companies.SetCompanyArchived(ctx, owner.ID, companyID, true); err != nil {
+		t.Fatalf("SetCompanyArchived: %v", err)
+	}
+
+	if _, err := s.SendTestEvent(ctx, owner.ID, companyID, endpoint.ID); !errors.Is(err, ErrCompanyArchived) {
+		t.Errorf("SendTestEvent = %v, want ErrCompanyArchived", err)
+	}
+	if err := s.DeleteWebhook(ctx, owner.ID, companyID, endpoint.ID); !errors.Is(err, ErrCompanyArchived) {
+		t.Errorf("DeleteWebhook = %v, want ErrCompanyArchived", err)
+	}
+}